@@ -0,0 +1,217 @@
+package cache
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Entry is a cached investigation result
+type Entry struct {
+	Key        string    `json:"key"`
+	TargetHash string    `json:"target_hash"`
+	Value      []byte    `json:"value"`
+	StoredAt   time.Time `json:"stored_at"`
+}
+
+// Age returns how long ago the entry was stored
+func (e *Entry) Age() time.Duration {
+	return time.Since(e.StoredAt)
+}
+
+// Options configures a Cache
+type Options struct {
+	Capacity int           // maximum number of in-memory entries
+	TTL      time.Duration // entries older than this are never served
+	Dir      string        // optional on-disk copy of the entries; empty disables it
+}
+
+// Stats summarizes cache usage
+type Stats struct {
+	Entries  int    `json:"entries"`
+	Capacity int    `json:"capacity"`
+	Hits     uint64 `json:"hits"`
+	Misses   uint64 `json:"misses"`
+	Disk     bool   `json:"disk_enabled"`
+}
+
+// Cache is an in-memory LRU of investigation results with an optional
+// on-disk copy that survives restarts. The disk holds exactly the entries in
+// memory, so both tiers are bounded by the same capacity.
+type Cache struct {
+	capacity int
+	ttl      time.Duration
+	disk     *diskStore
+
+	mu     sync.Mutex
+	order  *list.List
+	items  map[string]*list.Element
+	hits   uint64
+	misses uint64
+}
+
+// New creates a cache from options
+func New(opts Options) (*Cache, error) {
+	if opts.Capacity <= 0 {
+		opts.Capacity = 1000
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = time.Hour
+	}
+
+	c := &Cache{
+		capacity: opts.Capacity,
+		ttl:      opts.TTL,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+
+	if opts.Dir != "" {
+		disk, err := newDiskStore(opts.Dir)
+		if err != nil {
+			return nil, err
+		}
+		c.disk = disk
+		c.load()
+	}
+
+	return c, nil
+}
+
+// Get returns the entry for key if it is younger than both the cache TTL and
+// maxAge. A zero maxAge applies the TTL only.
+func (c *Cache) Get(key string, maxAge time.Duration) (*Entry, bool) {
+	limit := c.ttl
+	if maxAge > 0 && maxAge < limit {
+		limit = maxAge
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.lookup(key)
+	if entry == nil || entry.Age() > limit {
+		// Expired by TTL is gone for everyone; too old for this caller
+		// only is left in place for less demanding requests.
+		if entry != nil && entry.Age() > c.ttl {
+			c.remove(key)
+		}
+		c.misses++
+		return nil, false
+	}
+
+	c.hits++
+	return entry, true
+}
+
+// Set stores value under key, evicting the least recently used entry when
+// the cache is full.
+func (c *Cache) Set(key, targetHash string, value []byte) {
+	entry := &Entry{
+		Key:        key,
+		TargetHash: targetHash,
+		Value:      value,
+		StoredAt:   time.Now(),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.insert(entry)
+	if c.disk != nil {
+		c.disk.write(entry)
+	}
+}
+
+// PurgeTarget removes every entry for the given target hash, whatever
+// module set it was investigated with.
+func (c *Cache) PurgeTarget(targetHash string) int {
+	return c.purge(func(e *Entry) bool { return e.TargetHash == targetHash })
+}
+
+// PurgeOlderThan removes every entry stored more than age ago
+func (c *Cache) PurgeOlderThan(age time.Duration) int {
+	return c.purge(func(e *Entry) bool { return e.Age() > age })
+}
+
+// Stats returns current cache counters
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return Stats{
+		Entries:  c.order.Len(),
+		Capacity: c.capacity,
+		Hits:     c.hits,
+		Misses:   c.misses,
+		Disk:     c.disk != nil,
+	}
+}
+
+// load fills memory from the disk tier, newest entries first, and deletes
+// files that are expired or no longer fit
+func (c *Cache) load() {
+	entries := c.disk.load()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].StoredAt.After(entries[j].StoredAt) })
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if i >= c.capacity || entry.Age() > c.ttl {
+			c.disk.remove(entry.Key)
+			continue
+		}
+		c.insert(entry)
+	}
+}
+
+// lookup finds key in memory. Callers must hold c.mu.
+func (c *Cache) lookup(key string) *Entry {
+	elem, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*Entry)
+}
+
+// insert adds entry to the LRU, dropping the least recently used entries
+// from both tiers once over capacity. Callers must hold c.mu.
+func (c *Cache) insert(entry *Entry) {
+	if elem, ok := c.items[entry.Key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[entry.Key] = c.order.PushFront(entry)
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.remove(oldest.Value.(*Entry).Key)
+	}
+}
+
+// remove drops key from both tiers. Callers must hold c.mu.
+func (c *Cache) remove(key string) {
+	if elem, ok := c.items[key]; ok {
+		c.order.Remove(elem)
+		delete(c.items, key)
+	}
+	if c.disk != nil {
+		c.disk.remove(key)
+	}
+}
+
+func (c *Cache) purge(match func(*Entry) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	purged := 0
+	for key, elem := range c.items {
+		if match(elem.Value.(*Entry)) {
+			c.remove(key)
+			purged++
+		}
+	}
+	return purged
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKeyIncludesScanData(t *testing.T) {
+	plain := Key("@Eve", []string{"b", "A"}, nil)
+	if plain != Key("eve", []string{"a", "b"}, map[string]interface{}{}) {
+		t.Error("empty scan data changed the key")
+	}
+
+	withData := Key("eve", []string{"a", "b"}, map[string]interface{}{"x": 1.0, "y": "z"})
+	if withData == plain {
+		t.Error("scan data did not change the key")
+	}
+	if withData != Key("eve", []string{"a", "b"}, map[string]interface{}{"y": "z", "x": 1.0}) {
+		t.Error("equal scan data produced different keys")
+	}
+	if withData == Key("eve", []string{"a", "b"}, map[string]interface{}{"x": 2.0, "y": "z"}) {
+		t.Error("different scan data produced the same key")
+	}
+}
+
+func countFiles(t *testing.T, dir string) int {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(matches)
+}
+
+func TestDiskTierBoundedByCapacity(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(Options{Capacity: 2, TTL: time.Hour, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Set("k1", "h1", []byte(`{"n":1}`))
+	c.Set("k2", "h2", []byte(`{"n":2}`))
+	c.Set("k3", "h3", []byte(`{"n":3}`))
+	if n := countFiles(t, dir); n != 2 {
+		t.Errorf("%d files on disk after eviction, want 2", n)
+	}
+	if _, ok := c.Get("k1", 0); ok {
+		t.Error("evicted entry was served")
+	}
+
+	// A restart with a smaller capacity keeps only the newest entries
+	reopened, err := New(Options{Capacity: 1, TTL: time.Hour, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Get("k3", 0); !ok {
+		t.Error("newest entry was not restored")
+	}
+	if _, ok := reopened.Get("k2", 0); ok {
+		t.Error("entry over capacity was restored")
+	}
+	if n := countFiles(t, dir); n != 1 {
+		t.Errorf("%d files on disk after reload, want 1", n)
+	}
+}
+
+func TestPurgeRemovesFiles(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(Options{Capacity: 10, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("k1", "h1", []byte(`{}`))
+	c.Set("k2", "h1", []byte(`{}`))
+	c.Set("k3", "h2", []byte(`{}`))
+
+	if purged := c.PurgeTarget("h1"); purged != 2 {
+		t.Errorf("purged %d entries, want 2", purged)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "k3.json")); err != nil {
+		t.Errorf("unrelated entry lost: %v", err)
+	}
+	if n := countFiles(t, dir); n != 1 {
+		t.Errorf("%d files on disk after purge, want 1", n)
+	}
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// diskStore persists entries as one JSON file per key. It is only touched
+// while the owning Cache holds its lock.
+type diskStore struct {
+	dir string
+}
+
+func newDiskStore(dir string) (*diskStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &diskStore{dir: dir}, nil
+}
+
+func (d *diskStore) path(key string) string {
+	return filepath.Join(d.dir, key+".json")
+}
+
+func (d *diskStore) read(key string) *Entry {
+	data, err := os.ReadFile(d.path(key))
+	if err != nil {
+		return nil
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Key != key {
+		return nil
+	}
+	return &entry
+}
+
+func (d *diskStore) write(entry *Entry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Failed to encode cache entry: %v", err)
+		return
+	}
+
+	// Write to a temporary file first so readers never see a partial entry
+	tmp, err := os.CreateTemp(d.dir, entry.Key+".*.tmp")
+	if err != nil {
+		log.Printf("Failed to write cache entry: %v", err)
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), d.path(entry.Key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("Failed to write cache entry: %v", err)
+	}
+}
+
+func (d *diskStore) remove(key string) {
+	os.Remove(d.path(key))
+}
+
+// load returns every readable stored entry and deletes the rest
+func (d *diskStore) load() []*Entry {
+	files, err := os.ReadDir(d.dir)
+	if err != nil {
+		log.Printf("Failed to list cache directory: %v", err)
+		return nil
+	}
+
+	var entries []*Entry
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		// Unreadable files would never be served, so drop them
+		key := strings.TrimSuffix(name, ".json")
+		if entry := d.read(key); entry != nil {
+			entries = append(entries, entry)
+		} else {
+			d.remove(key)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// NormalizeTarget canonicalizes a target so that trivially different
+// spellings of the same username share one cache entry.
+func NormalizeTarget(target string) string {
+	target = strings.TrimSpace(target)
+	target = strings.TrimPrefix(target, "@")
+	return strings.ToLower(target)
+}
+
+// HashTarget returns the SHA-256 hex digest of the normalized target. Cache
+// keys, file names and the purge index use the hash, but the cached reply
+// itself still names the target, so disk entries are not anonymous.
+func HashTarget(target string) string {
+	sum := sha256.Sum256([]byte(NormalizeTarget(target)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Key derives the cache key for a target investigated with a set of modules
+// and optional scan data. Module order and case do not matter; an empty set
+// means "all modules". Scan data is folded in as canonical JSON (sorted map
+// keys) so requests differing only in scan data never share a result.
+func Key(target string, modules []string, scanData map[string]interface{}) string {
+	seen := make(map[string]bool, len(modules))
+	normalized := make([]string, 0, len(modules))
+	for _, module := range modules {
+		module = strings.ToLower(strings.TrimSpace(module))
+		if module == "" || seen[module] {
+			continue
+		}
+		seen[module] = true
+		normalized = append(normalized, module)
+	}
+	sort.Strings(normalized)
+
+	material := HashTarget(target) + "|" + strings.Join(normalized, ",")
+	if len(scanData) > 0 {
+		// Scan data arrives decoded from JSON, so it always encodes again
+		canonical, _ := json.Marshal(scanData)
+		material += "|" + string(canonical)
+	}
+
+	sum := sha256.Sum256([]byte(material))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,180 @@
+// Package certs serves the API's TLS certificate and client CA pool from
+// files on disk and reloads them when they are rotated.
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Store holds the current certificate and client CA pool
+type Store struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTime   time.Time // newest modification time across the files
+}
+
+// Load reads the certificate, its key and, if clientCAFile is set, the CA
+// bundle used to verify client certificates
+func Load(certFile, keyFile, clientCAFile string) (*Store, error) {
+	s := &Store{certFile: certFile, keyFile: keyFile, clientCAFile: clientCAFile}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload re-reads the files. On error the current certificate stays in use.
+func (s *Store) Reload() error {
+	modTime, err := s.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return fmt.Errorf("failed to parse TLS certificate: %w", err)
+		}
+	}
+
+	var clientCAs *x509.CertPool
+	if s.clientCAFile != "" {
+		pem, err := os.ReadFile(s.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", s.clientCAFile)
+		}
+	}
+
+	s.mu.Lock()
+	s.cert = &cert
+	s.clientCAs = clientCAs
+	s.modTime = modTime
+	s.mu.Unlock()
+	return nil
+}
+
+// Watch polls the files every interval and reloads them when any changes,
+// until ctx is done
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var rejected time.Time // modification time of the last unusable files, reported once
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		modTime, err := s.latestModTime()
+		if err != nil {
+			continue
+		}
+
+		s.mu.RLock()
+		changed := !modTime.Equal(s.modTime) && !modTime.Equal(rejected)
+		s.mu.RUnlock()
+
+		if changed {
+			if err := s.Reload(); err != nil {
+				log.Printf("⚠️ Keeping previous TLS certificate: %v", err)
+				rejected = modTime
+				continue
+			}
+			log.Printf("🔄 Reloaded TLS certificate from %s", s.certFile)
+		}
+	}
+}
+
+// TLSConfig returns a server configuration that always uses the latest
+// certificate and client CA pool. It offers HTTP/2 and HTTP/1.1 over ALPN.
+func (s *Store) TLSConfig(clientAuth tls.ClientAuthType) *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	// The per-connection config replaces base entirely, so it must carry
+	// everything base negotiates
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return &tls.Config{
+			MinVersion:   base.MinVersion,
+			NextProtos:   base.NextProtos,
+			Certificates: []tls.Certificate{*s.cert},
+			ClientAuth:   clientAuth,
+			ClientCAs:    s.clientCAs,
+		}, nil
+	}
+	return base
+}
+
+// NotAfter returns when the current certificate expires
+func (s *Store) NotAfter() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cert.Leaf.NotAfter
+}
+
+// CheckExpiry fails when the current certificate expires within window
+func (s *Store) CheckExpiry(window time.Duration) error {
+	notAfter := s.NotAfter()
+	if remaining := time.Until(notAfter); remaining < window {
+		if remaining <= 0 {
+			return fmt.Errorf("certificate expired at %s", notAfter.Format(time.RFC3339))
+		}
+		return fmt.Errorf("certificate expires at %s", notAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// ClientAuthType maps "none", "optional" and "require" to the tls setting
+func ClientAuthType(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "optional":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return tls.NoClientCert, errors.New("client auth must be none, optional or require")
+}
+
+func (s *Store) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{s.certFile, s.keyFile, s.clientCAFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,196 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate for name, valid until
+// notAfter, to certFile and keyFile
+func writeCert(t *testing.T, certFile, keyFile, name string, notAfter time.Time) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// served returns the common name of the certificate the store hands out
+func served(t *testing.T, s *Store) string {
+	t.Helper()
+	cfg, err := s.TLSConfig(tls.NoClientCert).GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestWatchReloadsRotatedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCert(t, certFile, keyFile, "first", time.Now().Add(48*time.Hour))
+
+	store, err := Load(certFile, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go store.Watch(ctx, 10*time.Millisecond)
+
+	// A later modification time marks the rotation even on coarse clocks
+	writeCert(t, certFile, keyFile, "second", time.Now().Add(48*time.Hour))
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for served(t, store) != "second" {
+		if time.Now().After(deadline) {
+			t.Fatal("rotated certificate was not picked up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Unusable files are rejected and the current certificate stays
+	if err := os.WriteFile(certFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Reload(); err == nil {
+		t.Error("reload accepted a broken certificate")
+	}
+	if name := served(t, store); name != "second" {
+		t.Errorf("serving %q after a failed reload, want second", name)
+	}
+}
+
+func TestCheckExpiry(t *testing.T) {
+	tests := []struct {
+		name     string
+		notAfter time.Duration
+		window   time.Duration
+		want     string // error substring; empty means no error
+	}{
+		{"valid", 72 * time.Hour, 24 * time.Hour, ""},
+		{"within window", 12 * time.Hour, 24 * time.Hour, "expires at"},
+		{"expired", -time.Minute, 24 * time.Hour, "expired at"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+			writeCert(t, certFile, keyFile, "api", time.Now().Add(tt.notAfter))
+
+			store, err := Load(certFile, keyFile, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = store.CheckExpiry(tt.window)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("error %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTLSConfigNegotiatesHTTP2(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCert(t, certFile, keyFile, "localhost", time.Now().Add(48*time.Hour))
+	store, err := Load(certFile, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", store.TLSConfig(tls.NoClientCert))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	pool := x509.NewCertPool()
+	pem, _ := os.ReadFile(certFile)
+	pool.AppendCertsFromPEM(pem)
+	conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{
+		RootCAs:    pool,
+		ServerName: "localhost",
+		NextProtos: []string{"h2", "http/1.1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if proto := conn.ConnectionState().NegotiatedProtocol; proto != "h2" {
+		t.Errorf("negotiated %q, want h2", proto)
+	}
+}
+
+func TestClientAuthType(t *testing.T) {
+	for mode, want := range map[string]tls.ClientAuthType{
+		"":         tls.NoClientCert,
+		"none":     tls.NoClientCert,
+		"optional": tls.VerifyClientCertIfGiven,
+		"require":  tls.RequireAndVerifyClientCert,
+	} {
+		if got, err := ClientAuthType(mode); err != nil || got != want {
+			t.Errorf("%q: %v, %v; want %v", mode, got, err, want)
+		}
+	}
+	if _, err := ClientAuthType("always"); err == nil {
+		t.Error("accepted an unknown mode")
+	}
+}
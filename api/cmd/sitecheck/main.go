@@ -0,0 +1,90 @@
+// Command sitecheck replays recorded fixtures through the username scanner
+// and reports broken site definitions. With -record it refreshes the
+// fixtures from the live sites first.
+//
+//	go run ./cmd/sitecheck -sites ../muscle/websites.json
+//	go run ./cmd/sitecheck -record -site GitHub
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"osint-api/scanner"
+	"osint-api/scanner/selftest"
+)
+
+func main() {
+	sitesFile := flag.String("sites", "../muscle/websites.json", "site definitions file")
+	fixturesDir := flag.String("fixtures", "scanner/testdata/fixtures", "recorded fixtures directory")
+	record := flag.Bool("record", false, "record fresh fixtures from the live sites before replaying")
+	only := flag.String("site", "", "limit to one site by name")
+	flag.Parse()
+
+	sites, err := scanner.LoadSites(*sitesFile)
+	if err != nil {
+		log.Fatalf("Failed to load sites: %v", err)
+	}
+
+	if *only != "" {
+		var selected []scanner.Site
+		for _, site := range sites {
+			if strings.EqualFold(site.Name, *only) {
+				selected = append(selected, site)
+			}
+		}
+		if len(selected) == 0 {
+			log.Fatalf("No site named %q in %s", *only, *sitesFile)
+		}
+		sites = selected
+	}
+
+	ctx := context.Background()
+
+	if *record {
+		client := &http.Client{Timeout: 15 * time.Second}
+		for _, site := range sites {
+			for _, username := range []string{site.UsernameClaimed, site.UsernameUnclaimed} {
+				if username == "" {
+					continue
+				}
+				fixture, err := selftest.Record(ctx, client, site, username)
+				if err != nil {
+					log.Printf("⚠️ %s: failed to record %q: %v", site.Name, username, err)
+					continue
+				}
+				if err := selftest.SaveFixture(selftest.FixturePath(*fixturesDir, site, username), fixture); err != nil {
+					log.Fatalf("Failed to save fixture: %v", err)
+				}
+				log.Printf("📼 %s: recorded %q (status %d)", site.Name, username, fixture.StatusCode)
+			}
+		}
+	}
+
+	report := selftest.Run(ctx, sites, *fixturesDir)
+	for _, site := range report.Sites {
+		icon := "✅"
+		switch site.Status {
+		case selftest.StatusBroken:
+			icon = "❌"
+		case selftest.StatusMissingFixtures, selftest.StatusUntested:
+			icon = "⚪"
+		}
+		fmt.Printf("%s %-20s %s\n", icon, site.Site, site.Status)
+		for _, problem := range site.Problems {
+			fmt.Printf("     - %s\n", problem)
+		}
+	}
+
+	broken := report.Broken()
+	fmt.Printf("\n%d sites checked, %d broken, %d without fixtures\n", len(report.Sites), len(broken), len(report.Missing()))
+	if len(broken) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,408 @@
+// Package config loads the API configuration from defaults, an optional YAML
+// file, .env files and the process environment, in increasing order of
+// precedence.
+//
+// Each field names the environment variables it is read from (the first one
+// set wins), its YAML key and its default. Fields tagged secret:"true" are
+// redacted when the configuration is dumped.
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Config is the complete API configuration
+type Config struct {
+	Environment string `yaml:"environment" env:"ENVIRONMENT" default:"development"`
+	LogLevel    string `yaml:"log_level" env:"LOG_LEVEL" default:"INFO"`
+
+	Server      ServerConfig      `yaml:"server"`
+	TLS         TLSConfig         `yaml:"tls"`
+	Orchestra   OrchestraConfig   `yaml:"orchestra"`
+	Brain       BrainConfig       `yaml:"brain"`
+	Cache       CacheConfig       `yaml:"cache"`
+	Sites       SitesConfig       `yaml:"sites"`
+	Scanner     ScannerConfig     `yaml:"scanner"`
+	Operations  OperationsConfig  `yaml:"operations"`
+	Cases       CasesConfig       `yaml:"cases"`
+	Schedules   SchedulesConfig   `yaml:"schedules"`
+	Watchlists  WatchlistsConfig  `yaml:"watchlists"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Health      HealthConfig      `yaml:"health"`
+	Tracing     TracingConfig     `yaml:"tracing"`
+}
+
+// ServerConfig configures the HTTP listener
+type ServerConfig struct {
+	Host            string        `yaml:"host" env:"API_HOST,HOST"` // empty listens on every interface
+	Port            int           `yaml:"port" env:"PORT,API_PORT" default:"8080"`
+	MaxRequests     int           `yaml:"max_requests" env:"API_MAX_REQUESTS" default:"100"` // concurrent requests; 0 is unlimited
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"30s"`
+	MetricsPort     int           `yaml:"metrics_port" env:"PROMETHEUS_PORT,METRICS_PORT" default:"9090"` // /metrics listener; 0 disables it
+}
+
+// Addr returns the listen address
+func (s ServerConfig) Addr() string {
+	return fmt.Sprintf("%s:%d", s.Host, s.Port)
+}
+
+// MetricsAddr returns the metrics listen address
+func (s ServerConfig) MetricsAddr() string {
+	return fmt.Sprintf("%s:%d", s.Host, s.MetricsPort)
+}
+
+// TLSConfig enables HTTPS and, optionally, client certificate authentication
+type TLSConfig struct {
+	CertFile       string        `yaml:"cert_file" env:"TLS_CERT_FILE"` // empty serves plain HTTP
+	KeyFile        string        `yaml:"key_file" env:"TLS_KEY_FILE"`
+	ReloadInterval time.Duration `yaml:"reload_interval" env:"TLS_RELOAD_INTERVAL" default:"1m"`
+	RedirectAddr   string        `yaml:"redirect_addr" env:"TLS_REDIRECT_ADDR"` // plain HTTP listener that redirects to HTTPS
+
+	ClientCAFile string `yaml:"client_ca_file" env:"TLS_CLIENT_CA_FILE"`
+	ClientAuth   string `yaml:"client_auth" env:"TLS_CLIENT_AUTH" default:"none"` // none, optional or require
+	// Certificate common name or DNS name -> identity, "id" or "id:admin"
+	ClientIdentities map[string]string `yaml:"client_identities" env:"TLS_CLIENT_IDENTITIES"`
+}
+
+// Enabled reports whether the server should speak HTTPS
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// OrchestraConfig configures the ZMQ link to orchestra
+type OrchestraConfig struct {
+	Addr    string        `yaml:"addr" env:"ORCHESTRA_ADDR,ORCHESTRATOR_URL,API_ORCHESTRATOR_URL" default:"tcp://localhost:5558"`
+	Timeout time.Duration `yaml:"timeout" env:"API_TIMEOUT" default:"25s"` // wait for an orchestra reply
+
+	// Circuit breaker: after BreakerFailures consecutive failed calls, fail
+	// fast for BreakerCooldown, then close again after BreakerProbes
+	// successful trial calls. Zero failures disables the breaker.
+	BreakerFailures int           `yaml:"breaker_failures" env:"ORCHESTRA_BREAKER_FAILURES" default:"5"`
+	BreakerCooldown time.Duration `yaml:"breaker_cooldown" env:"ORCHESTRA_BREAKER_COOLDOWN" default:"30s"`
+	BreakerProbes   int           `yaml:"breaker_probes" env:"ORCHESTRA_BREAKER_PROBES" default:"1"`
+
+	// CURVE keys, Z85 encoded or read from ZMQ certificate files. Setting the
+	// server key turns CURVE on; it is mandatory in production.
+	CurveServerKey     string `yaml:"curve_server_key" env:"ORCHESTRA_CURVE_SERVER_KEY"`
+	CurveServerKeyFile string `yaml:"curve_server_key_file" env:"ORCHESTRA_CURVE_SERVER_KEY_FILE"`
+	CurvePublicKey     string `yaml:"curve_public_key" env:"ORCHESTRA_CURVE_PUBLIC_KEY"`
+	CurveSecretKey     string `yaml:"curve_secret_key" env:"ORCHESTRA_CURVE_SECRET_KEY" secret:"true"`
+	CurveKeyFile       string `yaml:"curve_key_file" env:"ORCHESTRA_CURVE_KEY_FILE"` // the API's secret certificate
+}
+
+// CurveEnabled reports whether the orchestra link uses CURVE
+func (o OrchestraConfig) CurveEnabled() bool {
+	return o.CurveServerKey != "" || o.CurveServerKeyFile != ""
+}
+
+// BrainConfig locates the brain service for health checks
+type BrainConfig struct {
+	URL string `yaml:"url" env:"BRAIN_URL"`
+}
+
+// CacheConfig configures the investigation result cache
+type CacheConfig struct {
+	Capacity int           `yaml:"capacity" env:"CACHE_CAPACITY" default:"1000"` // 0 disables the cache
+	TTL      time.Duration `yaml:"ttl" env:"CACHE_TTL" default:"1h"`
+	Dir      string        `yaml:"dir" env:"CACHE_DIR"`
+}
+
+// SitesConfig locates the username scanner site definitions
+type SitesConfig struct {
+	File           string        `yaml:"file" env:"SITES_FILE" default:"../muscle/websites.json"`
+	ReloadInterval time.Duration `yaml:"reload_interval" env:"SITES_RELOAD_INTERVAL" default:"10s"`
+}
+
+// ScannerConfig configures the in-process username scanner
+type ScannerConfig struct {
+	Concurrency int  `yaml:"concurrency" env:"SCANNER_CONCURRENCY" default:"10"`
+	IncludeNSFW bool `yaml:"include_nsfw" env:"SCANNER_INCLUDE_NSFW" default:"false"`
+}
+
+// MaxOperationAttempts bounds operations.max_attempts, so a failing
+// operation cannot keep retrying for days
+const MaxOperationAttempts = 20
+
+// OperationsConfig configures operation persistence
+type OperationsConfig struct {
+	Dir string `yaml:"dir" env:"OPERATIONS_DIR"` // empty keeps operations in memory only
+
+	// Failed attempts with a temporary cause are retried after an
+	// exponential backoff with jitter, up to MaxAttempts in total
+	MaxAttempts     int           `yaml:"max_attempts" env:"OPERATION_MAX_ATTEMPTS" default:"3"` // at most MaxOperationAttempts
+	RetryBackoff    time.Duration `yaml:"retry_backoff" env:"OPERATION_RETRY_BACKOFF" default:"2s"`
+	RetryMaxBackoff time.Duration `yaml:"retry_max_backoff" env:"OPERATION_RETRY_MAX_BACKOFF" default:"1m"`
+
+	// How the list target filter matches: "plain" substring search, or
+	// "hashed" exact matches of the normalized target only
+	TargetSearch string `yaml:"target_search" env:"OPERATION_TARGET_SEARCH" default:"plain"`
+}
+
+// CasesConfig configures case persistence
+type CasesConfig struct {
+	Dir string `yaml:"dir" env:"CASES_DIR"` // empty keeps cases in memory only
+}
+
+// SchedulesConfig configures scheduled investigations
+type SchedulesConfig struct {
+	Dir      string        `yaml:"dir" env:"SCHEDULES_DIR"`                         // empty keeps schedules in memory only
+	Interval time.Duration `yaml:"interval" env:"SCHEDULER_INTERVAL" default:"15s"` // how often due runs are started
+}
+
+// WatchlistsConfig configures watchlists and the notifiers their alerts
+// fire. A notifier is enabled by setting its destination.
+type WatchlistsConfig struct {
+	Dir string `yaml:"dir" env:"WATCHLISTS_DIR"` // watchlists and alerts; empty keeps them in memory only
+
+	WebhookURL      string        `yaml:"webhook_url" env:"ALERT_WEBHOOK_URL" secret:"true"`             // alert posted as JSON; the path may hold a token
+	SlackWebhookURL string        `yaml:"slack_webhook_url" env:"ALERT_SLACK_WEBHOOK_URL" secret:"true"` // Slack-compatible incoming webhook
+	SMTPAddr        string        `yaml:"smtp_addr" env:"ALERT_SMTP_ADDR"`                               // host:port of a relay needing no auth or TLS
+	SMTPFrom        string        `yaml:"smtp_from" env:"ALERT_SMTP_FROM" default:"osint-alerts@localhost"`
+	SMTPTo          string        `yaml:"smtp_to" env:"ALERT_SMTP_TO"` // comma separated recipients
+	NotifyTimeout   time.Duration `yaml:"notify_timeout" env:"ALERT_NOTIFY_TIMEOUT" default:"10s"`
+}
+
+// IdempotencyConfig configures Idempotency-Key handling for POST requests
+type IdempotencyConfig struct {
+	TTL      time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL" default:"24h"`             // how long responses are replayed; 0 disables
+	Capacity int           `yaml:"capacity" env:"IDEMPOTENCY_CAPACITY" default:"10000"` // keys remembered; the oldest are forgotten first
+	Dir      string        `yaml:"dir" env:"IDEMPOTENCY_DIR"`                           // empty keeps records in memory only
+}
+
+// HealthConfig configures background dependency checks
+type HealthConfig struct {
+	Interval time.Duration `yaml:"interval" env:"HEALTH_CHECK_INTERVAL" default:"15s"`
+	Timeout  time.Duration `yaml:"timeout" env:"HEALTH_CHECK_TIMEOUT" default:"2s"`
+}
+
+// TracingConfig configures OpenTelemetry export
+type TracingConfig struct {
+	Exporter     string `yaml:"exporter" env:"OTEL_TRACES_EXPORTER" default:"none"`
+	OTLPEndpoint string `yaml:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT,OTEL_EXPORTER_OTLP_ENDPOINT"`
+	OTLPHeaders  string `yaml:"otlp_headers" env:"OTEL_EXPORTER_OTLP_TRACES_HEADERS,OTEL_EXPORTER_OTLP_HEADERS" secret:"true"`
+}
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate normalizes the configuration and checks it for problems
+func (c *Config) Validate() error {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	switch c.Environment {
+	case "development", "test", "staging", "production":
+	default:
+		addf("environment %q must be development, test, staging or production", c.Environment)
+	}
+
+	c.LogLevel = strings.ToUpper(c.LogLevel)
+	switch c.LogLevel {
+	case "DEBUG", "INFO", "WARN", "WARNING", "ERROR", "CRITICAL":
+	default:
+		addf("log_level %q must be DEBUG, INFO, WARN or ERROR", c.LogLevel)
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		addf("server.port %d out of range", c.Server.Port)
+	}
+	if c.Server.MetricsPort < 0 || c.Server.MetricsPort > 65535 {
+		addf("server.metrics_port %d out of range", c.Server.MetricsPort)
+	} else if c.Server.MetricsPort == c.Server.Port {
+		addf("server.metrics_port must differ from server.port")
+	}
+	if c.Server.MaxRequests < 0 {
+		addf("server.max_requests must not be negative")
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		addf("server.shutdown_timeout must be positive")
+	}
+
+	c.TLS.ClientAuth = strings.ToLower(c.TLS.ClientAuth)
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		addf("tls.cert_file and tls.key_file must be set together")
+	}
+	if c.TLS.ReloadInterval <= 0 {
+		addf("tls.reload_interval must be positive")
+	}
+	switch c.TLS.ClientAuth {
+	case "none":
+		if len(c.TLS.ClientIdentities) > 0 {
+			addf("tls.client_identities requires tls.client_auth optional or require")
+		}
+	case "optional", "require":
+		if c.TLS.ClientCAFile == "" {
+			addf("tls.client_auth %s requires tls.client_ca_file", c.TLS.ClientAuth)
+		}
+	default:
+		addf("tls.client_auth %q must be none, optional or require", c.TLS.ClientAuth)
+	}
+	if !c.TLS.Enabled() && (c.TLS.ClientCAFile != "" || c.TLS.RedirectAddr != "") {
+		addf("tls.client_ca_file and tls.redirect_addr require tls.cert_file")
+	}
+
+	// docker-compose passes "orchestra:5558" without a transport
+	if c.Orchestra.Addr != "" && !strings.Contains(c.Orchestra.Addr, "://") {
+		c.Orchestra.Addr = "tcp://" + c.Orchestra.Addr
+	}
+	if err := validateEndpoint(c.Orchestra.Addr); err != nil {
+		addf("orchestra.addr: %v", err)
+	}
+	if c.Orchestra.Timeout <= 0 {
+		addf("orchestra.timeout must be positive")
+	}
+	if c.Orchestra.BreakerFailures < 0 {
+		addf("orchestra.breaker_failures must not be negative")
+	}
+	if c.Orchestra.BreakerCooldown <= 0 || c.Orchestra.BreakerProbes < 1 {
+		addf("orchestra.breaker_cooldown must be positive and orchestra.breaker_probes at least 1")
+	}
+	c.validateCurve(addf)
+
+	if c.Brain.URL != "" {
+		if !strings.Contains(c.Brain.URL, "://") {
+			c.Brain.URL = "tcp://" + c.Brain.URL
+		}
+		if err := validateEndpoint(c.Brain.URL); err != nil {
+			addf("brain.url: %v", err)
+		}
+	}
+
+	if c.Cache.Capacity < 0 {
+		addf("cache.capacity must not be negative")
+	}
+	if c.Cache.TTL <= 0 {
+		addf("cache.ttl must be positive")
+	}
+	if c.Sites.ReloadInterval <= 0 {
+		addf("sites.reload_interval must be positive")
+	}
+	if c.Scanner.Concurrency < 0 {
+		addf("scanner.concurrency must not be negative")
+	}
+	if c.Operations.MaxAttempts < 1 || c.Operations.MaxAttempts > MaxOperationAttempts {
+		addf("operations.max_attempts must be between 1 and %d", MaxOperationAttempts)
+	}
+	if c.Operations.RetryBackoff <= 0 || c.Operations.RetryMaxBackoff < c.Operations.RetryBackoff {
+		addf("operations.retry_backoff must be positive and no more than operations.retry_max_backoff")
+	}
+	c.Operations.TargetSearch = strings.ToLower(c.Operations.TargetSearch)
+	if c.Operations.TargetSearch != "plain" && c.Operations.TargetSearch != "hashed" {
+		addf("operations.target_search %q must be plain or hashed", c.Operations.TargetSearch)
+	}
+	if c.Schedules.Interval <= 0 {
+		addf("schedules.interval must be positive")
+	}
+	if err := validateWebhook(c.Watchlists.WebhookURL); err != nil {
+		addf("watchlists.webhook_url: %v", err)
+	}
+	if err := validateWebhook(c.Watchlists.SlackWebhookURL); err != nil {
+		addf("watchlists.slack_webhook_url: %v", err)
+	}
+	if c.Watchlists.SMTPAddr != "" && strings.TrimSpace(c.Watchlists.SMTPTo) == "" {
+		addf("watchlists.smtp_to is required when watchlists.smtp_addr is set")
+	}
+	if c.Watchlists.NotifyTimeout <= 0 {
+		addf("watchlists.notify_timeout must be positive")
+	}
+	if c.Idempotency.TTL < 0 {
+		addf("idempotency.ttl must not be negative")
+	}
+	if c.Idempotency.Capacity < 0 {
+		addf("idempotency.capacity must not be negative")
+	}
+	if c.Health.Interval <= 0 || c.Health.Timeout <= 0 {
+		addf("health.interval and health.timeout must be positive")
+	}
+
+	c.Tracing.Exporter = strings.ToLower(c.Tracing.Exporter)
+	switch c.Tracing.Exporter {
+	case "", "none", "stdout", "console", "otlp":
+	default:
+		addf("tracing.exporter %q must be otlp, stdout or none", c.Tracing.Exporter)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+var z85Key = regexp.MustCompile(`^[0-9a-zA-Z.:+=^!/*?&<>()\[\]{}@%$#-]{40}$`)
+
+func (c *Config) validateCurve(addf func(string, ...interface{})) {
+	o := c.Orchestra
+	if !o.CurveEnabled() {
+		if c.Environment == "production" {
+			addf("orchestra CURVE keys are required in production")
+		}
+		if o.CurvePublicKey != "" || o.CurveSecretKey != "" || o.CurveKeyFile != "" {
+			addf("orchestra CURVE client keys are set but the server key is not")
+		}
+		return
+	}
+
+	if o.CurveServerKey != "" && o.CurveServerKeyFile != "" {
+		addf("set orchestra.curve_server_key or orchestra.curve_server_key_file, not both")
+	}
+	if o.CurveKeyFile == "" && (o.CurvePublicKey == "" || o.CurveSecretKey == "") {
+		addf("orchestra CURVE needs curve_key_file or both curve_public_key and curve_secret_key")
+	}
+	if o.CurveKeyFile != "" && (o.CurvePublicKey != "" || o.CurveSecretKey != "") {
+		addf("set orchestra.curve_key_file or the curve key pair, not both")
+	}
+	for _, key := range []struct{ name, value string }{
+		{"curve_server_key", o.CurveServerKey},
+		{"curve_public_key", o.CurvePublicKey},
+		{"curve_secret_key", o.CurveSecretKey},
+	} {
+		if key.value != "" && !z85Key.MatchString(key.value) {
+			addf("orchestra.%s must be a 40 character Z85 key", key.name)
+		}
+	}
+}
+
+// validateWebhook checks an optional http or https URL
+func validateWebhook(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http or https URL", raw)
+	}
+	return nil
+}
+
+// validateEndpoint checks a ZMQ endpoint such as tcp://host:port
+func validateEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "tcp":
+		if u.Port() == "" {
+			return fmt.Errorf("%q has no port", endpoint)
+		}
+	case "ipc", "inproc":
+	default:
+		return fmt.Errorf("%q must use tcp://, ipc:// or inproc://", endpoint)
+	}
+	return nil
+}
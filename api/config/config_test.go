@@ -0,0 +1,213 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// write creates a file in a fresh temporary directory
+func write(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := write(t, "config.yaml", "server:\n  port: 8100\n  max_requests: 7\ncache:\n  ttl: 2h\n")
+	envFile := write(t, ".env", "# comment\nexport PORT=8200\nCACHE_TTL=\"90\"\n")
+
+	tests := []struct {
+		name    string
+		opts    Options
+		port    int
+		maxReqs int
+		ttl     time.Duration
+	}{
+		{"defaults", Options{}, 8080, 100, time.Hour},
+		{"file over default", Options{File: file}, 8100, 7, 2 * time.Hour},
+		{"env file over file", Options{File: file, EnvFiles: []string{envFile}}, 8200, 7, 90 * time.Second},
+		{"environment over env file", Options{File: file, EnvFiles: []string{envFile}, Environ: []string{"PORT=8300"}}, 8300, 7, 90 * time.Second},
+		{"first variable name wins", Options{Environ: []string{"API_PORT=8300", "PORT=8400"}}, 8400, 100, time.Hour},
+		{"empty variables are unset", Options{File: file, Environ: []string{"PORT="}}, 8100, 7, 2 * time.Hour},
+		{"missing env files are skipped", Options{EnvFiles: []string{filepath.Join(t.TempDir(), "none")}}, 8080, 100, time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.opts.Environ == nil {
+				tt.opts.Environ = []string{}
+			}
+			cfg, err := Load(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Server.Port != tt.port || cfg.Server.MaxRequests != tt.maxReqs || cfg.Cache.TTL != tt.ttl {
+				t.Errorf("port %d, max_requests %d, ttl %s; want %d, %d, %s",
+					cfg.Server.Port, cfg.Server.MaxRequests, cfg.Cache.TTL, tt.port, tt.maxReqs, tt.ttl)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsBadInput(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"unknown yaml key", Options{File: write(t, "c.yaml", "server:\n  prot: 1\n")}, "prot"},
+		{"bad env line", Options{EnvFiles: []string{write(t, ".env", "NOT A PAIR\n")}}, ".env:1"},
+		{"bad duration", Options{Environ: []string{"CACHE_TTL=soon"}}, "CACHE_TTL"},
+		{"bad map", Options{Environ: []string{"TLS_CLIENT_IDENTITIES=nokey"}}, "TLS_CLIENT_IDENTITIES"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.opts.Environ == nil {
+				tt.opts.Environ = []string{}
+			}
+			_, err := Load(tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	key := strings.Repeat("a", 40)
+	tests := []struct {
+		name  string
+		env   []string
+		want  string // substring of the only problem; empty means valid
+		check func(*testing.T, *Config)
+	}{
+		{name: "defaults are valid"},
+		{name: "compose orchestra address gains tcp", env: []string{"ORCHESTRA_ADDR=orchestra:5558"},
+			check: func(t *testing.T, c *Config) {
+				if c.Orchestra.Addr != "tcp://orchestra:5558" {
+					t.Errorf("orchestra.addr %q", c.Orchestra.Addr)
+				}
+			}},
+		{name: "environment", env: []string{"ENVIRONMENT=prod"}, want: "environment"},
+		{name: "log level", env: []string{"LOG_LEVEL=loud"}, want: "log_level"},
+		{name: "port", env: []string{"PORT=70000"}, want: "server.port"},
+		{name: "metrics port clash", env: []string{"PORT=9090"}, want: "metrics_port must differ"},
+		{name: "cert without key", env: []string{"TLS_CERT_FILE=cert.pem"}, want: "set together"},
+		{name: "client auth without ca", env: []string{"TLS_CERT_FILE=c", "TLS_KEY_FILE=k", "TLS_CLIENT_AUTH=require"}, want: "client_ca_file"},
+		{name: "identities without client auth", env: []string{"TLS_CLIENT_IDENTITIES=cn=id"}, want: "client_identities"},
+		{name: "orchestra endpoint", env: []string{"ORCHESTRA_ADDR=http://orchestra:5558"}, want: "orchestra.addr"},
+		{name: "production without curve", env: []string{"ENVIRONMENT=production"}, want: "required in production"},
+		{name: "curve key not z85", env: []string{"ORCHESTRA_CURVE_SERVER_KEY=short", "ORCHESTRA_CURVE_PUBLIC_KEY=" + key, "ORCHESTRA_CURVE_SECRET_KEY=" + key}, want: "Z85"},
+		{name: "max attempts", env: []string{"OPERATION_MAX_ATTEMPTS=1000"}, want: "max_attempts"},
+		{name: "target search", env: []string{"OPERATION_TARGET_SEARCH=fuzzy"}, want: "target_search"},
+		{name: "webhook scheme", env: []string{"ALERT_WEBHOOK_URL=ftp://hooks.example"}, want: "webhook_url"},
+		{name: "smtp without recipients", env: []string{"ALERT_SMTP_ADDR=localhost:25"}, want: "smtp_to"},
+		{name: "tracing exporter", env: []string{"OTEL_TRACES_EXPORTER=jaeger"}, want: "tracing.exporter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(Options{Environ: append([]string{}, tt.env...)})
+			if tt.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tt.check != nil {
+					tt.check(t, cfg)
+				}
+				return
+			}
+
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("error %v, want a ValidationError", err)
+			}
+			if len(invalid.Problems) != 1 || !strings.Contains(invalid.Problems[0], tt.want) {
+				t.Errorf("problems %q, want one mentioning %q", invalid.Problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	key := strings.Repeat("b", 40)
+	cfg, err := Load(Options{Environ: []string{
+		"ORCHESTRA_CURVE_SERVER_KEY=" + key,
+		"ORCHESTRA_CURVE_PUBLIC_KEY=" + key,
+		"ORCHESTRA_CURVE_SECRET_KEY=" + key,
+		"OTEL_EXPORTER_OTLP_HEADERS=authorization=Bearer abc",
+		"ALERT_WEBHOOK_URL=https://hooks.internal.example/osint/tok3n",
+		"ALERT_SLACK_WEBHOOK_URL=https://hooks.slack.com/services/T000/B000/XXXX",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redacted := cfg.Redacted()
+	if redacted.Orchestra.CurveSecretKey != "[REDACTED]" || redacted.Tracing.OTLPHeaders != "[REDACTED]" {
+		t.Errorf("secrets not redacted: %+v %+v", redacted.Orchestra, redacted.Tracing)
+	}
+	if redacted.Watchlists.WebhookURL != "[REDACTED]" || redacted.Watchlists.SlackWebhookURL != "[REDACTED]" {
+		t.Errorf("webhook URLs not redacted: %+v", redacted.Watchlists)
+	}
+	if redacted.Orchestra.CurvePublicKey != key {
+		t.Errorf("public key redacted: %q", redacted.Orchestra.CurvePublicKey)
+	}
+	if cfg.Orchestra.CurveSecretKey != key {
+		t.Error("Redacted modified the original configuration")
+	}
+
+	var dump strings.Builder
+	if err := cfg.Dump(&dump); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(dump.String(), "Bearer abc") || strings.Contains(dump.String(), "tok3n") ||
+		strings.Contains(dump.String(), "B000/XXXX") || !strings.Contains(dump.String(), "[REDACTED]") {
+		t.Errorf("dump leaks secrets:\n%s", dump.String())
+	}
+}
+
+func TestValidateCurve(t *testing.T) {
+	key := strings.Repeat("c", 40)
+	tests := []struct {
+		name string
+		env  []string
+		want string // substring of the only problem; empty means valid
+	}{
+		{"off outside production", nil, ""},
+		{"production refuses plaintext", []string{"ENVIRONMENT=production"}, "required in production"},
+		{"production with inline keys", []string{"ENVIRONMENT=production", "ORCHESTRA_CURVE_SERVER_KEY=" + key,
+			"ORCHESTRA_CURVE_PUBLIC_KEY=" + key, "ORCHESTRA_CURVE_SECRET_KEY=" + key}, ""},
+		{"production with certificate files", []string{"ENVIRONMENT=production", "ORCHESTRA_CURVE_SERVER_KEY_FILE=orchestra.key",
+			"ORCHESTRA_CURVE_KEY_FILE=api.key_secret"}, ""},
+		{"client keys without server key", []string{"ORCHESTRA_CURVE_PUBLIC_KEY=" + key}, "server key is not"},
+		{"server key twice", []string{"ORCHESTRA_CURVE_SERVER_KEY=" + key, "ORCHESTRA_CURVE_SERVER_KEY_FILE=orchestra.key",
+			"ORCHESTRA_CURVE_KEY_FILE=api.key_secret"}, "not both"},
+		{"missing secret key", []string{"ORCHESTRA_CURVE_SERVER_KEY=" + key, "ORCHESTRA_CURVE_PUBLIC_KEY=" + key}, "needs curve_key_file"},
+		{"key file and pair", []string{"ORCHESTRA_CURVE_SERVER_KEY=" + key, "ORCHESTRA_CURVE_KEY_FILE=api.key_secret",
+			"ORCHESTRA_CURVE_PUBLIC_KEY=" + key, "ORCHESTRA_CURVE_SECRET_KEY=" + key}, "not both"},
+		{"secret key not z85", []string{"ORCHESTRA_CURVE_SERVER_KEY=" + key, "ORCHESTRA_CURVE_PUBLIC_KEY=" + key,
+			"ORCHESTRA_CURVE_SECRET_KEY=" + strings.Repeat("~", 40)}, "curve_secret_key must be"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(Options{Environ: append([]string{}, tt.env...)})
+			if tt.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) || len(invalid.Problems) != 1 || !strings.Contains(invalid.Problems[0], tt.want) {
+				t.Errorf("error %v, want only a problem mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"io"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[REDACTED]"
+
+// Redacted returns a copy of the configuration with every non-empty secret
+// replaced by a placeholder
+func (c *Config) Redacted() *Config {
+	clone := *c
+	walk(reflect.ValueOf(&clone).Elem(), func(field reflect.Value, tag reflect.StructTag) error {
+		if tag.Get("secret") == "true" && field.Kind() == reflect.String && field.String() != "" {
+			field.SetString(redacted)
+		}
+		return nil
+	})
+	return &clone
+}
+
+// Dump writes the redacted configuration as YAML, in the same shape the
+// config file accepts
+func (c *Config) Dump(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Options selects the configuration sources
+type Options struct {
+	File     string   // optional YAML file
+	EnvFiles []string // .env files; missing files are skipped, later files win
+	Environ  []string // KEY=VALUE pairs standing in for the process environment; nil reads os.Environ
+}
+
+// Load builds the configuration. Precedence, lowest first: field defaults,
+// the YAML file, .env files, the process environment. Empty variables are
+// treated as unset.
+func Load(opts Options) (*Config, error) {
+	cfg := &Config{}
+	if err := walk(reflect.ValueOf(cfg).Elem(), func(field reflect.Value, tag reflect.StructTag) error {
+		if value, ok := tag.Lookup("default"); ok {
+			return set(field, value)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("invalid default: %w", err)
+	}
+
+	if opts.File != "" {
+		data, err := os.ReadFile(opts.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		// An empty file decodes as io.EOF and leaves the defaults alone
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", opts.File, err)
+		}
+	}
+
+	env := make(map[string]string)
+	for _, path := range opts.EnvFiles {
+		values, err := ReadEnvFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range values {
+			env[key] = value
+		}
+	}
+	environ := opts.Environ
+	if environ == nil {
+		environ = os.Environ()
+	}
+	for _, kv := range environ {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			env[key] = value
+		}
+	}
+
+	if err := walk(reflect.ValueOf(cfg).Elem(), func(field reflect.Value, tag reflect.StructTag) error {
+		for _, name := range strings.Split(tag.Get("env"), ",") {
+			// docker-compose passes unset variables through as empty strings
+			if value := env[name]; value != "" {
+				if err := set(field, value); err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+				return nil
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("invalid environment: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// ReadEnvFile parses KEY=VALUE lines. Blank lines and # comments are
+// ignored, an "export " prefix is allowed and values may be quoted.
+func ReadEnvFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNo)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+	return values, scanner.Err()
+}
+
+// walk calls fn for every leaf field of a (nested) config struct
+func walk(v reflect.Value, fn func(field reflect.Value, tag reflect.StructTag) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := walk(field, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(field, t.Field(i).Tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set parses value into field. Durations also accept a bare number of
+// seconds, as used by the existing .env files; maps are written as
+// key=value,key=value.
+func set(field reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+
+	if field.Type() == durationType {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			field.SetInt(int64(time.Duration(seconds) * time.Second))
+			return nil
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Map:
+		if field.Type().Key().Kind() != reflect.String || field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported config field type %s", field.Type())
+		}
+		m := reflect.MakeMap(field.Type())
+		for _, pair := range strings.Split(value, ",") {
+			key, val, ok := strings.Cut(pair, "=")
+			if !ok || strings.TrimSpace(key) == "" {
+				return fmt.Errorf("expected key=value, got %q", pair)
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(key)), reflect.ValueOf(strings.TrimSpace(val)))
+		}
+		field.Set(m)
+	default:
+		return fmt.Errorf("unsupported config field type %s", field.Type())
+	}
+	return nil
+}
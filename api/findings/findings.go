@@ -0,0 +1,235 @@
+// Package findings extracts comparable findings from operation results and
+// diffs two runs against the same target.
+package findings
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Finding is one thing an investigation turned up, such as an account on a
+// site or a page scrapy crawled
+type Finding struct {
+	Key    string                 `json:"key"`            // identity across runs, e.g. "username_scan:github"
+	Source string                 `json:"source"`         // module or tool that reported it
+	Value  string                 `json:"value"`          // URL, name or other short description
+	Data   map[string]interface{} `json:"data,omitempty"` // details compared between runs
+	// Sampled marks a finding from a list the reporter cut short, so its
+	// absence from another run proves nothing
+	Sampled bool `json:"sampled,omitempty"`
+}
+
+// Change is a finding present in both runs with different details
+type Change struct {
+	Key    string   `json:"key"`
+	Source string   `json:"source"`
+	Fields []string `json:"fields"` // changed detail names; "value" when Value changed
+	Before Finding  `json:"before"`
+	After  Finding  `json:"after"`
+}
+
+// Diff is what changed between two runs
+type Diff struct {
+	Added     []Finding `json:"added"`
+	Removed   []Finding `json:"removed"`
+	Changed   []Change  `json:"changed"`
+	Unchanged int       `json:"unchanged"`
+	// Incomplete names the sources sampled in either run; their findings
+	// are never reported as added or removed
+	Incomplete []string `json:"incomplete"`
+}
+
+// identityFields name the item field used as a finding's identity, in order
+// of preference
+var identityFields = []string{"url", "id", "name", "value"}
+
+// volatileFields differ on every run and are not compared
+var volatileFields = map[string]bool{
+	"timestamp":        true,
+	"checked_at":       true,
+	"crawled_at":       true,
+	"response_time_ms": true,
+}
+
+// Extract returns the findings in an operation's results, sorted by key.
+// It understands local module replies (accounts found by each module under
+// "results"), scrapy findings from an orchestra correlation report and a
+// plain top-level "findings" list. Older orchestra reports list only the
+// first few scrapy pages; those findings are marked Sampled. SpiderFoot
+// reports only a findings count, so it contributes no findings.
+func Extract(results map[string]interface{}) []Finding {
+	var found []Finding
+
+	if modules, ok := results["results"].(map[string]interface{}); ok {
+		for name, result := range modules {
+			module, _ := result.(map[string]interface{})
+			details, _ := module["details"].([]interface{})
+			for _, detail := range details {
+				entry, ok := detail.(map[string]interface{})
+				if !ok || entry["found"] != true {
+					continue
+				}
+				site, _ := entry["website_name"].(string)
+				url, _ := entry["url"].(string)
+				found = append(found, Finding{
+					Key:    name + ":" + strings.ToLower(site),
+					Source: name,
+					Value:  url,
+					Data:   map[string]interface{}{"site": site, "url": url},
+				})
+			}
+		}
+	}
+
+	if scrapy, ok := results["scrapy"].(map[string]interface{}); ok {
+		items, _ := scrapy["findings"].([]interface{})
+		pages, _ := scrapy["pages_crawled"].(float64)
+		listed := fromList("scrapy", items)
+		if int(pages) > len(items) {
+			for i := range listed {
+				listed[i].Sampled = true
+			}
+		}
+		found = append(found, listed...)
+	}
+	if items, ok := results["findings"].([]interface{}); ok {
+		found = append(found, fromList("findings", items)...)
+	}
+
+	return dedupe(found)
+}
+
+// fromList turns the items of a findings list into findings. Strings are
+// their own identity; objects are identified by their first identity field,
+// or by a hash of their contents.
+func fromList(source string, items []interface{}) []Finding {
+	found := make([]Finding, 0, len(items))
+	for _, item := range items {
+		switch item := item.(type) {
+		case string:
+			found = append(found, Finding{Key: source + ":" + strings.ToLower(item), Source: source, Value: item})
+		case map[string]interface{}:
+			data := make(map[string]interface{}, len(item))
+			for field, value := range item {
+				if !volatileFields[field] {
+					data[field] = value
+				}
+			}
+			value := ""
+			for _, field := range identityFields {
+				if v, ok := item[field]; ok && v != nil && v != "" {
+					value = fmt.Sprint(v)
+					break
+				}
+			}
+			identity := strings.ToLower(value)
+			if identity == "" {
+				encoded, _ := json.Marshal(data) // map keys are sorted
+				sum := sha256.Sum256(encoded)
+				identity = hex.EncodeToString(sum[:8])
+			}
+			found = append(found, Finding{Key: source + ":" + identity, Source: source, Value: value, Data: data})
+		default:
+			if item != nil {
+				value := fmt.Sprint(item)
+				found = append(found, Finding{Key: source + ":" + value, Source: source, Value: value})
+			}
+		}
+	}
+	return found
+}
+
+// dedupe sorts findings by key and keeps the first of each key
+func dedupe(found []Finding) []Finding {
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Key < found[j].Key })
+	unique := found[:0]
+	for i, finding := range found {
+		if i > 0 && finding.Key == found[i-1].Key {
+			continue
+		}
+		unique = append(unique, finding)
+	}
+	return unique
+}
+
+// Compare diffs the findings of a later run, after, against an earlier one,
+// before. Both must be sorted by key, as Extract returns them. Findings of a
+// source sampled in either run are only compared when both runs list them.
+func Compare(before, after []Finding) Diff {
+	diff := Diff{Added: []Finding{}, Removed: []Finding{}, Changed: []Change{}, Incomplete: []string{}}
+
+	sampled := make(map[string]bool)
+	for _, list := range [][]Finding{before, after} {
+		for _, finding := range list {
+			if finding.Sampled && !sampled[finding.Source] {
+				sampled[finding.Source] = true
+				diff.Incomplete = append(diff.Incomplete, finding.Source)
+			}
+		}
+	}
+	sort.Strings(diff.Incomplete)
+
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		switch {
+		case j == len(after) || (i < len(before) && before[i].Key < after[j].Key):
+			if !sampled[before[i].Source] {
+				diff.Removed = append(diff.Removed, before[i])
+			}
+			i++
+		case i == len(before) || after[j].Key < before[i].Key:
+			if !sampled[after[j].Source] {
+				diff.Added = append(diff.Added, after[j])
+			}
+			j++
+		default:
+			if fields := changedFields(before[i], after[j]); len(fields) > 0 {
+				diff.Changed = append(diff.Changed, Change{
+					Key:    after[j].Key,
+					Source: after[j].Source,
+					Fields: fields,
+					Before: before[i],
+					After:  after[j],
+				})
+			} else {
+				diff.Unchanged++
+			}
+			i++
+			j++
+		}
+	}
+	return diff
+}
+
+// changedFields names the details that differ between two versions of a
+// finding, sorted
+func changedFields(before, after Finding) []string {
+	var fields []string
+	if before.Value != after.Value {
+		fields = append(fields, "value")
+	}
+	for field, value := range after.Data {
+		if old, ok := before.Data[field]; !ok || !reflect.DeepEqual(old, value) {
+			fields = append(fields, field)
+		}
+	}
+	for field := range before.Data {
+		if _, ok := after.Data[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	unique := fields[:0]
+	for i, field := range fields {
+		if i == 0 || field != fields[i-1] {
+			unique = append(unique, field)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,95 @@
+package findings
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func decode(t *testing.T, data string) map[string]interface{} {
+	t.Helper()
+	var results map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &results); err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+func TestExtract(t *testing.T) {
+	results := decode(t, `{
+		"results": {"username_scan": {"found_count": 1, "details": [
+			{"website_name": "GitHub", "url": "https://github.com/eve", "found": true, "response_time_ms": 80},
+			{"website_name": "GitLab", "url": "https://gitlab.com/eve", "found": false}
+		]}},
+		"scrapy": {"findings": [{"url": "https://eve.example", "title": "Eve"}, {"title": "no url"}]},
+		"findings": ["breach", "breach"]
+	}`)
+
+	found := Extract(results)
+	var keys []string
+	for _, finding := range found {
+		keys = append(keys, finding.Key)
+	}
+	// The item without an identity field is keyed by a hash of its contents
+	want := []string{"findings:breach", "scrapy:", "scrapy:https://eve.example", "username_scan:github"}
+	if len(keys) != len(want) {
+		t.Fatalf("keys %v, want %v", keys, want)
+	}
+	for i := range want {
+		if !strings.HasPrefix(keys[i], want[i]) || (i != 1 && keys[i] != want[i]) {
+			t.Errorf("key %d = %q, want %q", i, keys[i], want[i])
+		}
+	}
+	if len(keys[1]) != len("scrapy:")+16 {
+		t.Errorf("hashed key %q", keys[1])
+	}
+	if found[3].Value != "https://github.com/eve" || found[3].Data["site"] != "GitHub" {
+		t.Errorf("account finding %+v", found[3])
+	}
+}
+
+func TestCompare(t *testing.T) {
+	before := Extract(decode(t, `{"findings": ["a", "b", {"id": 7, "status": "open"}]}`))
+	after := Extract(decode(t, `{"findings": ["b", "c", {"id": 7, "status": "closed", "timestamp": "now"}]}`))
+
+	diff := Compare(before, after)
+	if len(diff.Added) != 1 || diff.Added[0].Value != "c" {
+		t.Errorf("added %v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Value != "a" {
+		t.Errorf("removed %v", diff.Removed)
+	}
+	if len(diff.Changed) != 1 || len(diff.Changed[0].Fields) != 1 || diff.Changed[0].Fields[0] != "status" {
+		t.Errorf("changed %v", diff.Changed)
+	}
+	if diff.Unchanged != 1 {
+		t.Errorf("unchanged %d, want 1", diff.Unchanged)
+	}
+}
+
+func TestCompareSampled(t *testing.T) {
+	// An older orchestra report listing 2 of 40 crawled pages
+	before := Extract(decode(t, `{
+		"scrapy": {"pages_crawled": 40, "findings": [{"url": "https://a.example"}, {"url": "https://b.example"}]},
+		"findings": ["breach"]
+	}`))
+	after := Extract(decode(t, `{
+		"scrapy": {"pages_crawled": 3, "findings": [{"url": "https://b.example", "title": "B"}, {"url": "https://c.example"}, {"url": "https://d.example"}]},
+		"findings": ["leak"]
+	}`))
+	if last := len(before) - 1; !before[last].Sampled || after[len(after)-1].Sampled || before[0].Sampled {
+		t.Fatalf("sampled flags: before %+v, after %+v", before, after)
+	}
+
+	diff := Compare(before, after)
+	if len(diff.Incomplete) != 1 || diff.Incomplete[0] != "scrapy" {
+		t.Errorf("incomplete %v, want [scrapy]", diff.Incomplete)
+	}
+	if len(diff.Added) != 1 || diff.Added[0].Value != "leak" || len(diff.Removed) != 1 || diff.Removed[0].Value != "breach" {
+		t.Errorf("added %v, removed %v; want only the plain findings", diff.Added, diff.Removed)
+	}
+	// Pages listed in both runs are still compared
+	if len(diff.Changed) != 1 || diff.Changed[0].Key != "scrapy:https://b.example" {
+		t.Errorf("changed %v", diff.Changed)
+	}
+}
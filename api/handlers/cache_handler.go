@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"osint-api/cache"
+	"osint-api/handlers/middleware"
+)
+
+// CacheHandler exposes administration of the investigation result cache
+type CacheHandler struct {
+	Cache *cache.Cache
+}
+
+// GetCacheStats returns cache occupancy and hit counters
+func (h *CacheHandler) GetCacheStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.Cache == nil {
+		h.sendError(w, "Result cache is disabled", http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"cache":     h.Cache.Stats(),
+		"timestamp": time.Now(),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// PurgeCache removes cached results for a target and/or older than a given age
+func (h *CacheHandler) PurgeCache(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.Cache == nil {
+		h.sendError(w, "Result cache is disabled", http.StatusNotFound)
+		return
+	}
+
+	target := r.URL.Query().Get("target")
+	olderThanStr := r.URL.Query().Get("older_than")
+	if target == "" && olderThanStr == "" {
+		h.sendError(w, "target or older_than is required", http.StatusBadRequest)
+		return
+	}
+
+	var olderThan time.Duration
+	if olderThanStr != "" {
+		var err error
+		if olderThan, err = time.ParseDuration(olderThanStr); err != nil {
+			h.sendError(w, "Invalid duration format", http.StatusBadRequest)
+			return
+		}
+	}
+
+	purged := 0
+	if target != "" {
+		purged += h.Cache.PurgeTarget(cache.HashTarget(target))
+	}
+	if olderThanStr != "" {
+		purged += h.Cache.PurgeOlderThan(olderThan)
+	}
+
+	response := map[string]interface{}{
+		"purged_count": purged,
+		"remaining":    h.Cache.Stats().Entries,
+		"timestamp":    time.Now(),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// sendError sends a standardized error response
+func (h *CacheHandler) sendError(w http.ResponseWriter, message string, statusCode int) {
+	errorResponse := map[string]interface{}{
+		"error":       message,
+		"status":      "error",
+		"status_code": statusCode,
+		"request_id":  w.Header().Get(middleware.RequestIDHeader),
+		"timestamp":   time.Now(),
+	}
+
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(errorResponse)
+}
@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"osint-api/handlers/middleware"
+	"osint-api/store"
+
+	"github.com/gorilla/mux"
+)
+
+// Case statuses
+var caseStatuses = map[string]bool{"open": true, "closed": true, "archived": true}
+
+// Case groups the operations analysts run for one investigation
+type Case struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Members     []string  `json:"members,omitempty"` // API key IDs working the case
+	Status      string    `json:"status"`            // open, closed, archived
+	Owner       string    `json:"owner,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// caseUpdate is the body of create and update requests. Omitted fields are
+// left unchanged on update.
+type caseUpdate struct {
+	Name        *string   `json:"name"`
+	Description *string   `json:"description"`
+	Members     *[]string `json:"members"`
+	Status      *string   `json:"status"`
+}
+
+// CaseHandler manages cases and which operations belong to them.
+// Lock order: h.mu before Ops.mu.
+type CaseHandler struct {
+	Ops   *OpsHandler
+	Store *store.Collection // optional; nil keeps cases in memory only
+	cases map[string]*Case
+	mu    sync.RWMutex
+}
+
+// NewCaseHandler creates a case handler for the operations of ops
+func NewCaseHandler(ops *OpsHandler) *CaseHandler {
+	return &CaseHandler{Ops: ops, cases: make(map[string]*Case)}
+}
+
+// Restore loads cases from the store
+func (h *CaseHandler) Restore() (int, error) {
+	if h.Store == nil {
+		return 0, nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	err := h.Store.Each(func(id string, data []byte) error {
+		var c Case
+		if err := json.Unmarshal(data, &c); err != nil {
+			log.Printf("⚠️ Skipping unreadable stored case %s: %v", id, err)
+			return nil
+		}
+		h.cases[c.ID] = &c
+		return nil
+	})
+	return len(h.cases), err
+}
+
+// ListCases returns cases, newest first, optionally filtered by status,
+// member and a name substring (q)
+func (h *CaseHandler) ListCases(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	statusFilter := r.URL.Query().Get("status")
+	memberFilter := r.URL.Query().Get("member")
+	nameFilter := strings.ToLower(r.URL.Query().Get("q"))
+
+	h.mu.RLock()
+	result := make([]Case, 0)
+	for _, c := range h.cases {
+		if statusFilter != "" && c.Status != statusFilter {
+			continue
+		}
+		if memberFilter != "" && !containsString(c.Members, memberFilter) {
+			continue
+		}
+		if nameFilter != "" && !strings.Contains(strings.ToLower(c.Name), nameFilter) {
+			continue
+		}
+		result = append(result, *c)
+	}
+	h.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.After(result[j].CreatedAt) })
+
+	response := map[string]interface{}{
+		"cases":     result,
+		"total":     len(result),
+		"timestamp": time.Now(),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetCase returns a case with the number of operations attached to it. The
+// operations themselves are listed by GET /operations?case=.
+func (h *CaseHandler) GetCase(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	h.mu.RLock()
+	c, ok := h.cases[mux.Vars(r)["id"]]
+	var snapshot Case
+	if ok {
+		snapshot = *c
+	}
+	h.mu.RUnlock()
+
+	if !ok {
+		h.sendError(w, "Case not found", http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"case":            snapshot,
+		"operation_count": h.Ops.countByCase(snapshot.ID),
+		"timestamp":       time.Now(),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// CreateCase opens a new case
+func (h *CaseHandler) CreateCase(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var update caseUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		h.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if update.Name == nil {
+		h.sendError(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	c := &Case{
+		ID:        fmt.Sprintf("case_%d_%s", now.Unix(), randomString(6)),
+		Status:    "open",
+		Owner:     ownerFromContext(r.Context()),
+		CreatedAt: now,
+	}
+	if err := c.apply(update); err != nil {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	h.cases[c.ID] = c
+	h.persist(c)
+	snapshot := *c
+	h.mu.Unlock()
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// UpdateCase changes the fields present in the request body
+func (h *CaseHandler) UpdateCase(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var update caseUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		h.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	c, ok := h.cases[mux.Vars(r)["id"]]
+	if !ok {
+		h.mu.Unlock()
+		h.sendError(w, "Case not found", http.StatusNotFound)
+		return
+	}
+	updated := *c
+	if err := updated.apply(update); err != nil {
+		h.mu.Unlock()
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	*c = updated
+	h.persist(c)
+	h.mu.Unlock()
+
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteCase removes a case and detaches its operations, which are kept
+func (h *CaseHandler) DeleteCase(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+
+	h.mu.Lock()
+	_, ok := h.cases[id]
+	detached := 0
+	if ok {
+		delete(h.cases, id)
+		h.unpersist(id)
+		detached = h.Ops.detachCase(id)
+	}
+	h.mu.Unlock()
+
+	if !ok {
+		h.sendError(w, "Case not found", http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"id":                  id,
+		"status":              "deleted",
+		"detached_operations": detached,
+		"deleted_at":          time.Now(),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// AttachOperation adds an operation to the case named in the body,
+// moving it out of any previous case
+func (h *CaseHandler) AttachOperation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		CaseID string `json:"case_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.CaseID == "" {
+		h.sendError(w, "case_id is required", http.StatusBadRequest)
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if _, ok := h.cases[request.CaseID]; !ok {
+		h.sendError(w, "Case not found", http.StatusNotFound)
+		return
+	}
+	h.setCase(w, mux.Vars(r)["id"], request.CaseID)
+}
+
+// DetachOperation removes an operation from its case
+func (h *CaseHandler) DetachOperation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	h.setCase(w, mux.Vars(r)["id"], "")
+}
+
+// setCase moves an operation and writes the response. Callers must hold h.mu.
+func (h *CaseHandler) setCase(w http.ResponseWriter, operationID, caseID string) {
+	if !h.Ops.setCase(operationID, caseID) {
+		h.sendError(w, "Operation not found", http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"operation_id": operationID,
+		"case_id":      caseID,
+		"timestamp":    time.Now(),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// apply validates and copies the fields present in update
+func (c *Case) apply(update caseUpdate) error {
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		if name == "" || len(name) > 200 {
+			return fmt.Errorf("name must be 1 to 200 characters")
+		}
+		c.Name = name
+	}
+	if update.Description != nil {
+		c.Description = strings.TrimSpace(*update.Description)
+	}
+	if update.Members != nil {
+		c.Members = normalizeMembers(*update.Members)
+	}
+	if update.Status != nil {
+		if !caseStatuses[*update.Status] {
+			return fmt.Errorf("status must be open, closed or archived")
+		}
+		c.Status = *update.Status
+	}
+	c.UpdatedAt = time.Now()
+	return nil
+}
+
+// normalizeMembers trims member IDs and drops blanks and duplicates
+func normalizeMembers(members []string) []string {
+	seen := make(map[string]bool, len(members))
+	normalized := make([]string, 0, len(members))
+	for _, member := range members {
+		member = strings.TrimSpace(member)
+		if member == "" || seen[member] {
+			continue
+		}
+		seen[member] = true
+		normalized = append(normalized, member)
+	}
+	return normalized
+}
+
+func containsString(values []string, want string) bool {
+	for _, value := range values {
+		if value == want {
+			return true
+		}
+	}
+	return false
+}
+
+// persist writes a case to the store, if any. Callers must hold h.mu.
+func (h *CaseHandler) persist(c *Case) {
+	if h.Store == nil {
+		return
+	}
+	if err := h.Store.Put(c.ID, c); err != nil {
+		log.Printf("⚠️ Failed to persist case %s: %v", c.ID, err)
+	}
+}
+
+// unpersist removes a case from the store, if any. Callers must hold h.mu.
+func (h *CaseHandler) unpersist(id string) {
+	if h.Store == nil {
+		return
+	}
+	if err := h.Store.Delete(id); err != nil {
+		log.Printf("⚠️ Failed to delete stored case %s: %v", id, err)
+	}
+}
+
+// sendError sends a standardized error response
+func (h *CaseHandler) sendError(w http.ResponseWriter, message string, statusCode int) {
+	errorResponse := map[string]interface{}{
+		"error":       message,
+		"status":      "error",
+		"status_code": statusCode,
+		"request_id":  w.Header().Get(middleware.RequestIDHeader),
+		"timestamp":   time.Now(),
+	}
+
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(errorResponse)
+}
@@ -6,20 +6,26 @@ import (
 	"runtime"
 	"time"
 
-	zmq "github.com/pebbe/zmq4"
+	"osint-api/health"
+	"osint-api/version"
 )
 
+// HealthHandler serves liveness, readiness and detailed health. Dependency
+// checks run in the background on Monitor; requests only read cached results.
 type HealthHandler struct {
-	ZmqSocket *zmq.Socket
+	Monitor *health.Monitor
 }
 
 type HealthResponse struct {
-	Status     string                 `json:"status"`
-	Timestamp  time.Time              `json:"timestamp"`
-	Version    string                 `json:"version"`
-	System     SystemInfo             `json:"system"`
-	Components map[string]string      `json:"components"`
-	Uptime     string                 `json:"uptime"`
+	Status     string            `json:"status"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Version    string            `json:"version"`
+	Build      version.Info      `json:"build"`
+	Peers      []version.Peer    `json:"peers,omitempty"`
+	System     SystemInfo        `json:"system"`
+	Components map[string]string `json:"components"`
+	Checks     []health.Result   `json:"checks"`
+	Uptime     string            `json:"uptime"`
 }
 
 type SystemInfo struct {
@@ -29,28 +35,19 @@ type SystemInfo struct {
 	NumCPU       int    `json:"num_cpu"`
 }
 
+// HealthCheck reports every dependency check. Failing critical checks make
+// the API unhealthy (503); failing optional ones only degrade it.
 func (h *HealthHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Check ZMQ connection health
-	zmqStatus := "healthy"
-	if h.ZmqSocket == nil {
-		zmqStatus = "disconnected"
-	} else {
-		// Test ZMQ connection by sending a ping
-		testMsg := map[string]interface{}{
-			"action":  "ping",
-			"timeout": 1,
-		}
-		if _, err := h.ZmqSocket.SendMessage(testMsg); err != nil {
-			zmqStatus = "unhealthy"
-		}
-	}
+	report := h.Monitor.Report()
 
 	response := HealthResponse{
-		Status:    "healthy",
+		Status:    report.Status,
 		Timestamp: time.Now().UTC(),
-		Version:   "1.0.0",
+		Version:   version.Version,
+		Build:     version.Get(),
+		Peers:     version.Peers(),
 		System: SystemInfo{
 			GoVersion:    runtime.Version(),
 			OS:           runtime.GOOS,
@@ -58,32 +55,73 @@ func (h *HealthHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 			NumCPU:       runtime.NumCPU(),
 		},
 		Components: map[string]string{
-			"zmq_connection": zmqStatus,
-			"http_server":    "healthy",
-			"memory":         "healthy",
+			"http_server": health.StatusHealthy,
 		},
+		Checks: report.Checks,
 		Uptime: getUptime(),
 	}
+	for _, check := range report.Checks {
+		response.Components[check.Name] = check.Status
+	}
+
+	if report.Status == health.StatusUnhealthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// LiveCheck reports that the process is up and serving. It never consults
+// dependencies, so an orchestra outage does not get the API restarted.
+func (h *HealthHandler) LiveCheck(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
-	// If ZMQ is unhealthy, overall status is degraded
-	if zmqStatus != "healthy" {
-		response.Status = "degraded"
-		response.Components["zmq_connection"] = zmqStatus
+	response := map[string]interface{}{
+		"status":    "alive",
+		"uptime":    getUptime(),
+		"timestamp": time.Now().UTC(),
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
+// ReadyCheck reports whether the API can serve investigations: every
+// critical check must have passed on its latest run
 func (h *HealthHandler) ReadyCheck(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	report := h.Monitor.Report()
+
+	services := map[string]bool{
+		"http_listening": true,
+	}
+	for _, check := range report.Checks {
+		services[check.Name] = check.Status == health.StatusHealthy
+	}
+
+	status := "ready"
+	if !report.Ready {
+		status = "not_ready"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
 	response := map[string]interface{}{
-		"status":    "ready",
+		"status":    status,
+		"timestamp": time.Now().UTC(),
+		"services":  services,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// VersionInfo reports the API build and the versions peers reported in the
+// latest handshake
+func (h *HealthHandler) VersionInfo(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	response := map[string]interface{}{
+		"api":       version.Get(),
+		"peers":     version.Peers(),
 		"timestamp": time.Now().UTC(),
-		"services": map[string]bool{
-			"zmq_connected": h.ZmqSocket != nil,
-			"http_listening": true,
-		},
 	}
 
 	json.NewEncoder(w).Encode(response)
@@ -93,11 +131,11 @@ func (h *HealthHandler) StatsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	stats := map[string]interface{}{
-		"timestamp": time.Now().UTC(),
-		"memory":    getMemoryStats(),
+		"timestamp":  time.Now().UTC(),
+		"memory":     getMemoryStats(),
 		"goroutines": runtime.NumGoroutine(),
 		"system": map[string]interface{}{
-			"cpu_cores": runtime.NumCPU(),
+			"cpu_cores":  runtime.NumCPU(),
 			"go_version": runtime.Version(),
 		},
 	}
@@ -106,17 +144,16 @@ func (h *HealthHandler) StatsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func getUptime() string {
-	// This would be implemented to track actual process uptime
-	return "0h5m" // Example
+	return health.Uptime().Round(time.Second).String()
 }
 
 func getMemoryStats() map[string]interface{} {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 	return map[string]interface{}{
-		"allocated":      m.Alloc,
-		"total_alloc":    m.TotalAlloc,
-		"system":         m.Sys,
+		"allocated":         m.Alloc,
+		"total_alloc":       m.TotalAlloc,
+		"system":            m.Sys,
 		"garbage_collector": m.NumGC,
 	}
 }
@@ -4,30 +4,50 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
-	"os"
 
-	zmq "github.com/pebbe/zmq4"
+	"osint-api/cache"
+	"osint-api/handlers/middleware"
+	"osint-api/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// IntelHandler runs investigations synchronously. Work is scheduled through
+// Ops so that identical in-flight investigations are coalesced.
 type IntelHandler struct {
-	ZmqSocket *zmq.Socket
+	Ops   *OpsHandler
+	Cache *cache.Cache // optional; nil disables result caching
 }
 
 type IntelRequest struct {
-	Target      string                 `json:"target"`
-	ScanData    map[string]interface{} `json:"scan_data"`
-	OperationID string                 `json:"operation_id"`
-	Priority    string                 `json:"priority"` // low, medium, high
+	Target       string                 `json:"target"`
+	ScanData     map[string]interface{} `json:"scan_data"`
+	OperationID  string                 `json:"operation_id"`
+	Priority     string                 `json:"priority"` // low, medium, high
+	Modules      []string               `json:"modules,omitempty"`
+	Tags         []string               `json:"tags,omitempty"`
+	MaxAge       string                 `json:"max_age,omitempty"` // oldest acceptable cached result, e.g. "15m"
+	ForceRefresh bool                   `json:"force_refresh,omitempty"`
 }
 
+// Cache status values reported in the X-Cache response header
+const (
+	cacheHit    = "HIT"
+	cacheMiss   = "MISS"
+	cacheBypass = "BYPASS"
+)
+
 type IntelResponse struct {
-	OperationID  string                 `json:"operation_id"`
-	Target       string                 `json:"target"`
-	Status       string                 `json:"status"`
-	Results      map[string]interface{} `json:"results"`
-	Timestamps   Timestamps             `json:"timestamps"`
-	RiskAssessment *RiskAssessment      `json:"risk_assessment,omitempty"`
+	OperationID    string                 `json:"operation_id"`
+	Target         string                 `json:"target"`
+	Status         string                 `json:"status"`
+	Results        map[string]interface{} `json:"results"`
+	Timestamps     Timestamps             `json:"timestamps"`
+	RiskAssessment *RiskAssessment        `json:"risk_assessment,omitempty"`
 }
 
 type Timestamps struct {
@@ -37,10 +57,10 @@ type Timestamps struct {
 }
 
 type RiskAssessment struct {
-	Score         float64  `json:"score"`
-	Level         string   `json:"level"`
-	Factors       []string `json:"factors"`
-	Confidence    float64  `json:"confidence"`
+	Score           float64  `json:"score"`
+	Level           string   `json:"level"`
+	Factors         []string `json:"factors"`
+	Confidence      float64  `json:"confidence"`
 	Recommendations []string `json:"recommendations"`
 }
 
@@ -59,6 +79,23 @@ func (h *IntelHandler) HandleIntelRequest(w http.ResponseWriter, r *http.Request
 		h.sendError(w, "Target is required", http.StatusBadRequest)
 		return
 	}
+	if err := validateModules(req.Modules); err != nil {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	middleware.SetLogTarget(r.Context(), req.Target)
+
+	ctx, span := tracing.Tracer().Start(r.Context(), "intel.investigate", trace.WithAttributes(
+		attribute.String("target.hash", cache.HashTarget(req.Target)),
+		attribute.StringSlice("intel.modules", req.Modules),
+	))
+	defer span.End()
+
+	maxAge, err := parseMaxAge(req.MaxAge)
+	if err != nil {
+		h.sendError(w, "Invalid max_age duration", http.StatusBadRequest)
+		return
+	}
 
 	// Set operation ID if not provided
 	if req.OperationID == "" {
@@ -70,39 +107,55 @@ func (h *IntelHandler) HandleIntelRequest(w http.ResponseWriter, r *http.Request
 		req.Priority = "medium"
 	}
 
-	// Prepare message for Orchestra
-	message := map[string]interface{}{
-		"action":       "investigate",
-		"target":       req.Target,
-		"scan_data":    req.ScanData,
-		"operation_id": req.OperationID,
-		"priority":     req.Priority,
-		"timestamp":    time.Now(),
+	// Serve a recent result for the same target and modules if we have one
+	key := cache.Key(req.Target, req.Modules, req.ScanData)
+	entry, cacheStatus := h.lookupCache(key, maxAge, req.ForceRefresh)
+	span.SetAttributes(attribute.String("intel.cache", cacheStatus))
+	w.Header().Set("X-Cache", cacheStatus)
+	if entry != nil {
+		w.Header().Set("Age", strconv.Itoa(int(entry.Age().Seconds())))
+		w.WriteHeader(http.StatusOK)
+		w.Write(entry.Value)
+		return
 	}
 
-	// Send to Orchestra
-	if _, err := h.ZmqSocket.SendMessage(message); err != nil {
-		h.sendError(w, "Failed to communicate with orchestra", http.StatusInternalServerError)
+	if err := h.Ops.Unavailable(req.Modules); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		h.sendError(w, err.Error(), errorStatus(w, err))
 		return
 	}
 
-	// Receive response
-	reply, err := h.ZmqSocket.RecvMessage(0)
+	// Schedule the investigation, or attach to an identical one in flight
+	operation, attached := h.Ops.Submit(OperationSpec{
+		OperationID: req.OperationID,
+		Target:      req.Target,
+		Priority:    req.Priority,
+		Modules:     req.Modules,
+		ScanData:    req.ScanData,
+		Tags:        req.Tags,
+		Owner:       ownerFromContext(ctx),
+		RequestID:   middleware.RequestIDFromContext(ctx),
+		Trace:       span.SpanContext(),
+	})
+	span.SetAttributes(
+		attribute.String("operation.id", operation.ID),
+		attribute.Bool("operation.attached", attached),
+	)
+	w.Header().Set("X-Operation-ID", operation.ID)
+
+	reply, err := h.Ops.Wait(ctx, operation)
 	if err != nil {
-		h.sendError(w, "Failed to receive response from orchestra", http.StatusInternalServerError)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		h.sendError(w, err.Error(), errorStatus(w, err))
 		return
 	}
 
-	// Process response
-	if len(reply) == 0 {
-		h.sendError(w, "Empty response from orchestra", http.StatusInternalServerError)
-		return
-	}
+	h.storeCache(key, req.Target, reply)
 
 	// Forward the orchestra response
-	w.Header().Set("X-Operation-ID", req.OperationID)
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(reply[0]))
+	w.Write(reply)
 }
 
 func (h *IntelHandler) HandleBatchIntelRequest(w http.ResponseWriter, r *http.Request) {
@@ -124,63 +177,120 @@ func (h *IntelHandler) HandleBatchIntelRequest(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	ctx, span := tracing.Tracer().Start(r.Context(), "intel.batch", trace.WithAttributes(
+		attribute.Int("intel.batch_size", len(requests)),
+	))
+	defer span.End()
+
+	// Schedule every uncached target first so they run while we wait
 	results := make([]map[string]interface{}, len(requests))
+	operations := make([]*Operation, len(requests))
+	rejected := 0 // items that failed on the open circuit
 	for i, req := range requests {
+		middleware.SetLogTarget(r.Context(), req.Target)
 		if req.OperationID == "" {
 			req.OperationID = generateOperationID()
 		}
 
-		message := map[string]interface{}{
-			"action":       "investigate",
-			"target":       req.Target,
-			"operation_id": req.OperationID,
-			"priority":     req.Priority,
-			"timestamp":    time.Now(),
-			"batch_index":  i,
+		maxAge, err := parseMaxAge(req.MaxAge)
+		if err != nil {
+			results[i] = map[string]interface{}{
+				"operation_id": req.OperationID,
+				"status":       "error",
+				"error":        "Invalid max_age duration",
+			}
+			continue
+		}
+		if err := validateModules(req.Modules); err != nil {
+			results[i] = map[string]interface{}{
+				"operation_id": req.OperationID,
+				"status":       "error",
+				"error":        err.Error(),
+			}
+			continue
 		}
 
-		if _, err := h.ZmqSocket.SendMessage(message); err != nil {
+		key := cache.Key(req.Target, req.Modules, req.ScanData)
+		if entry, _ := h.lookupCache(key, maxAge, req.ForceRefresh); entry != nil {
+			var result map[string]interface{}
+			if err := json.Unmarshal(entry.Value, &result); err == nil {
+				results[i] = result
+				continue
+			}
+		}
+
+		// Retry-After tells the client when to resubmit the rejected targets
+		if err := h.Ops.Unavailable(req.Modules); err != nil {
+			if errorStatus(w, err) == http.StatusServiceUnavailable {
+				rejected++
+			}
 			results[i] = map[string]interface{}{
 				"operation_id": req.OperationID,
 				"status":       "error",
-				"error":        "Failed to send to orchestra",
+				"error":        err.Error(),
 			}
 			continue
 		}
 
-		reply, err := h.ZmqSocket.RecvMessage(0)
+		operations[i], _ = h.Ops.Submit(OperationSpec{
+			OperationID: req.OperationID,
+			Target:      req.Target,
+			Priority:    req.Priority,
+			Modules:     req.Modules,
+			ScanData:    req.ScanData,
+			Tags:        req.Tags,
+			Owner:       ownerFromContext(ctx),
+			RequestID:   middleware.RequestIDFromContext(ctx),
+			Trace:       span.SpanContext(),
+		})
+	}
+
+	for i, operation := range operations {
+		if operation == nil {
+			continue
+		}
+
+		reply, err := h.Ops.Wait(ctx, operation)
 		if err != nil {
+			if errorStatus(w, err) == http.StatusServiceUnavailable {
+				rejected++
+			}
 			results[i] = map[string]interface{}{
-				"operation_id": req.OperationID,
+				"operation_id": operation.ID,
 				"status":       "error",
-				"error":        "Failed to receive response",
+				"error":        err.Error(),
 			}
 			continue
 		}
 
-		if len(reply) > 0 {
-			var result map[string]interface{}
-			if err := json.Unmarshal([]byte(reply[0]), &result); err != nil {
-				results[i] = map[string]interface{}{
-					"operation_id": req.OperationID,
-					"status":       "error",
-					"error":        "Invalid response format",
-				}
-			} else {
-				results[i] = result
+		var result map[string]interface{}
+		if err := json.Unmarshal(reply, &result); err != nil {
+			results[i] = map[string]interface{}{
+				"operation_id": operation.ID,
+				"status":       "error",
+				"error":        "Invalid response format",
 			}
+			continue
 		}
+
+		results[i] = result
+		h.storeCache(cache.Key(requests[i].Target, requests[i].Modules, requests[i].ScanData), requests[i].Target, reply)
 	}
 
 	response := map[string]interface{}{
-		"batch_id":    generateOperationID(),
-		"total":       len(requests),
-		"successful":  countSuccessful(results),
-		"failed":      countFailed(results),
-		"operations":  results,
-		"timestamp":   time.Now(),
+		"batch_id":   generateOperationID(),
+		"total":      len(requests),
+		"successful": countSuccessful(results),
+		"failed":     countFailed(results),
+		"operations": results,
+		"timestamp":  time.Now(),
 	}
 
+	// Nothing got through, so fail the batch as a whole; clients and
+	// proxies ignore Retry-After on a 200
+	if rejected == len(requests) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
 	json.NewEncoder(w).Encode(response)
 }
 
@@ -190,25 +300,53 @@ func (h *IntelHandler) sendError(w http.ResponseWriter, message string, statusCo
 		"status":      "error",
 		"timestamp":   time.Now(),
 		"status_code": statusCode,
+		"request_id":  w.Header().Get(middleware.RequestIDHeader),
 	}
 
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(errorResponse)
 }
 
-func generateOperationID() string {
-	return fmt.Sprintf("op_%d_%s", time.Now().Unix(), randomString(6))
+// lookupCache returns a usable cached result for key, if any, along with the
+// X-Cache status to report.
+func (h *IntelHandler) lookupCache(key string, maxAge time.Duration, forceRefresh bool) (*cache.Entry, string) {
+	if h.Cache == nil || forceRefresh {
+		return nil, cacheBypass
+	}
+	if entry, ok := h.Cache.Get(key, maxAge); ok {
+		return entry, cacheHit
+	}
+	return nil, cacheMiss
+}
+
+// storeCache caches a successful orchestra reply. Replies carrying an error
+// are not cached so the next request retries.
+func (h *IntelHandler) storeCache(key, target string, reply []byte) {
+	if h.Cache == nil {
+		return
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(reply, &result); err != nil {
+		return
+	}
+	if _, failed := result["error"]; failed {
+		return
+	}
+
+	h.Cache.Set(key, cache.HashTarget(target), reply)
 }
 
-func randomString(length int) string {
-	bytes := make([]byte, length)
-	if _, err := rand.Read(bytes); err != nil {
-		return "default"
+// parseMaxAge parses the optional max_age request field
+func parseMaxAge(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
 	}
-	for i := range bytes {
-		bytes[i] = byte(int(bytes[i])%26 + 97) // a-z
+	maxAge, err := time.ParseDuration(value)
+	if err != nil || maxAge < 0 {
+		return 0, fmt.Errorf("invalid max_age %q", value)
 	}
-	return string(bytes)
+	return maxAge, nil
 }
 
 func countSuccessful(results []map[string]interface{}) int {
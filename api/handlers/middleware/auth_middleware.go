@@ -1,14 +1,34 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
+
+	"osint-api/metrics"
 )
 
+type contextKey string
+
+const apiKeyContextKey contextKey = "api_key"
+
+// APIKey identifies an authenticated caller
+type APIKey struct {
+	ID    string
+	Admin bool
+}
+
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Skip auth for health checks
-		if r.URL.Path == "/api/v1/health" || r.URL.Path == "/api/v1/ready" {
+		// Skip auth for health checks and version
+		switch r.URL.Path {
+		case "/api/v1/health", "/api/v1/live", "/api/v1/ready", "/api/v1/version":
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Already authenticated by client certificate
+		if _, ok := APIKeyFromContext(r.Context()); ok {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -19,13 +39,15 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			// Check for bearer token
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+				metrics.AuthFailures.WithLabelValues("missing").Inc()
+				writeError(w, "Authentication required", http.StatusUnauthorized)
 				return
 			}
 
 			parts := strings.Split(authHeader, " ")
 			if len(parts) != 2 || parts[0] != "Bearer" {
-				http.Error(w, `{"error": "Invalid authorization format"}`, http.StatusUnauthorized)
+				metrics.AuthFailures.WithLabelValues("malformed").Inc()
+				writeError(w, "Invalid authorization format", http.StatusUnauthorized)
 				return
 			}
 
@@ -33,37 +55,56 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		}
 
 		// Validate API key (in real implementation, check against database)
-		if !isValidAPIKey(apiKey) {
-			http.Error(w, `{"error": "Invalid API key"}`, http.StatusUnauthorized)
+		key, ok := lookupAPIKey(apiKey)
+		if !ok {
+			metrics.AuthFailures.WithLabelValues("invalid").Inc()
+			writeError(w, "Invalid API key", http.StatusUnauthorized)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		setLogAPIKey(r.Context(), key.ID)
+		ctx := context.WithValue(r.Context(), apiKeyContextKey, key)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func isValidAPIKey(apiKey string) bool {
-	// In production, this would validate against a database or environment variable
-	validKeys := map[string]bool{
-		"osint-api-key-123": true,
-		"test-key-456":      true,
-	}
-	return validKeys[apiKey]
-}
-
-func LoggingMiddleware(next http.Handler) http.Handler {
+// RequireAdmin rejects callers whose API key lacks admin privileges. It must
+// run after AuthMiddleware.
+func RequireAdmin(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Log request details
-		// logger.Printf("Request: %s %s", r.Method, r.URL.Path)
+		key, ok := APIKeyFromContext(r.Context())
+		if !ok || !key.Admin {
+			metrics.AuthFailures.WithLabelValues("forbidden").Inc()
+			writeError(w, "Admin privileges required", http.StatusForbidden)
+			return
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
 
+// APIKeyFromContext returns the caller authenticated by AuthMiddleware
+func APIKeyFromContext(ctx context.Context) (APIKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey).(APIKey)
+	return key, ok
+}
+
+func lookupAPIKey(apiKey string) (APIKey, bool) {
+	// In production, this would validate against a database or environment variable
+	validKeys := map[string]APIKey{
+		"osint-api-key-123": {ID: "primary", Admin: true},
+		"test-key-456":      {ID: "test"},
+	}
+	key, ok := validKeys[apiKey]
+	return key, ok
+}
+
 func CORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Request-ID, traceparent, Idempotency-Key")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, X-Operation-ID, X-Cache, Idempotent-Replayed, Retry-After")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ClientCertAuth authenticates callers by their verified TLS client
+// certificate. identities maps a certificate common name or DNS name to an
+// identity, "id" or "id:admin". Mapped callers skip the API key check in
+// AuthMiddleware, which must run after this; anyone else falls through to it.
+func ClientCertAuth(identities map[string]string) mux.MiddlewareFunc {
+	keys := make(map[string]APIKey, len(identities))
+	for name, identity := range identities {
+		id, role, _ := strings.Cut(identity, ":")
+		keys[name] = APIKey{ID: id, Admin: role == "admin"}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key, ok := lookupClientCert(keys, r.TLS.VerifiedChains[0][0])
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			setLogAPIKey(r.Context(), key.ID)
+			ctx := context.WithValue(r.Context(), apiKeyContextKey, key)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func lookupClientCert(keys map[string]APIKey, cert *x509.Certificate) (APIKey, bool) {
+	if key, ok := keys[cert.Subject.CommonName]; ok && cert.Subject.CommonName != "" {
+		return key, true
+	}
+	for _, name := range cert.DNSNames {
+		if key, ok := keys[name]; ok {
+			return key, true
+		}
+	}
+	return APIKey{}, false
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientCertAuth(t *testing.T) {
+	identities := map[string]string{
+		"ci-runner":          "ci",
+		"ops.internal":       "ops:admin",
+		"":                   "anonymous",
+		"unverified-service": "nobody",
+	}
+	chain := ClientCertAuth(identities)(AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, _ := APIKeyFromContext(r.Context())
+		w.Header().Set("X-Key-ID", key.ID)
+		if key.Admin {
+			w.Header().Set("X-Admin", "true")
+		}
+	})))
+
+	verified := func(commonName string, dnsNames ...string) *tls.ConnectionState {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}, DNSNames: dnsNames}
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+
+	tests := []struct {
+		name   string
+		tls    *tls.ConnectionState
+		status int
+		id     string
+		admin  bool
+	}{
+		{"common name", verified("ci-runner"), http.StatusOK, "ci", false},
+		{"dns name with admin role", verified("host-7", "ops.internal"), http.StatusOK, "ops", true},
+		{"unmapped certificate needs a key", verified("stranger"), http.StatusUnauthorized, "", false},
+		{"empty common name never matches", verified(""), http.StatusUnauthorized, "", false},
+		{"unverified certificate is ignored", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{
+			{Subject: pkix.Name{CommonName: "unverified-service"}},
+		}}, http.StatusUnauthorized, "", false},
+		{"plain HTTP needs a key", nil, http.StatusUnauthorized, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/operations", nil)
+			req.TLS = tt.tls
+			rec := httptest.NewRecorder()
+			chain.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status || rec.Header().Get("X-Key-ID") != tt.id || (rec.Header().Get("X-Admin") == "true") != tt.admin {
+				t.Errorf("status %d, id %q, admin %q; want %d, %q, %v",
+					rec.Code, rec.Header().Get("X-Key-ID"), rec.Header().Get("X-Admin"), tt.status, tt.id, tt.admin)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"osint-api/idempotency"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// IdempotencyKeyHeader lets clients retry a POST without repeating it
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayHeader marks a response replayed for a repeated key
+	IdempotentReplayHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// Idempotency replays the stored response when a caller repeats a request
+// with the same Idempotency-Key. Reusing a key for a different request, or
+// while the first one is still running, is a 409, as is repeating one whose
+// response did not survive a restart. Server errors and panics are not
+// stored, so the client can retry them. A nil store disables the middleware.
+func Idempotency(records *idempotency.Store) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if records == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				writeError(w, "Idempotency-Key is too long", http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				writeError(w, "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			caller := "anonymous"
+			if apiKey, ok := APIKeyFromContext(r.Context()); ok {
+				caller = apiKey.ID
+			}
+			id := idempotency.ID(caller, key)
+			fingerprint := idempotency.Fingerprint(r.Method, r.URL.Path, body)
+
+			if record := records.Begin(id, fingerprint); record != nil {
+				switch {
+				case record.Fingerprint != fingerprint:
+					writeError(w, "Idempotency-Key was already used for a different request", http.StatusConflict)
+				case !record.Done():
+					w.Header().Set("Retry-After", "1")
+					writeError(w, "A request with this Idempotency-Key is still in progress", http.StatusConflict)
+				case !record.Replayable():
+					writeError(w, "A request with this Idempotency-Key was already processed; its response is no longer available", http.StatusConflict)
+				default:
+					replay(w, record)
+				}
+				return
+			}
+
+			// A handler that panics has not produced a response worth
+			// replaying, so the key is released and the retry runs again
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			returned := false
+			defer func() {
+				if !returned || recorder.status >= 500 {
+					records.Release(id)
+					return
+				}
+				records.Complete(id, recorder.status, recorder.stored, recorder.body.Bytes())
+			}()
+			next.ServeHTTP(recorder, r)
+			returned = true
+		})
+	}
+}
+
+// replay writes a stored response. The request ID stays the current one.
+func replay(w http.ResponseWriter, record *idempotency.Record) {
+	for name, values := range record.Header {
+		if name == RequestIDHeader {
+			continue
+		}
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayHeader, "true")
+	w.WriteHeader(record.Status)
+	w.Write(record.Body)
+}
+
+// responseRecorder passes a response through and keeps a copy
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	stored      http.Header
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.wroteHeader = true
+		r.status = status
+		r.stored = r.Header().Clone()
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"osint-api/idempotency"
+)
+
+func TestIdempotencyPanic(t *testing.T) {
+	records, err := idempotency.New(time.Hour, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	chain := Idempotency(records)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls++; calls == 1 {
+			panic("boom")
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"operation_id":"op_1"}`))
+	}))
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/operations", strings.NewReader(`{"target":"zoe"}`))
+		req.Header.Set(IdempotencyKeyHeader, "k1")
+		rec := httptest.NewRecorder()
+		chain.ServeHTTP(rec, req)
+		return rec
+	}
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("recovered %v, want the handler's panic", p)
+			}
+		}()
+		post()
+	}()
+
+	// The retry runs the handler again and its response is the one kept
+	if rec := post(); rec.Code != http.StatusCreated || rec.Header().Get(IdempotentReplayHeader) != "" {
+		t.Fatalf("retry after a panic: status %d, replayed %q", rec.Code, rec.Header().Get(IdempotentReplayHeader))
+	}
+	if rec := post(); rec.Code != http.StatusCreated || rec.Header().Get(IdempotentReplayHeader) != "true" || rec.Body.String() != `{"operation_id":"op_1"}` {
+		t.Errorf("repeat: status %d, body %q", rec.Code, rec.Body.String())
+	}
+	if calls != 2 {
+		t.Errorf("handler ran %d times, want 2", calls)
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// ConcurrencyLimit rejects requests with 503 while max requests are already
+// being served. Probes are never rejected so an overloaded API is not
+// restarted. Zero disables the limit.
+func ConcurrencyLimit(max int) mux.MiddlewareFunc {
+	if max <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	slots := make(chan struct{}, max)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/v1/health", "/api/v1/live", "/api/v1/ready":
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+				next.ServeHTTP(w, r)
+			default:
+				w.Header().Set("Retry-After", "1")
+				writeError(w, "Too many concurrent requests", http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
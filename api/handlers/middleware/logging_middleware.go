@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"osint-api/cache"
+)
+
+const requestLogContextKey contextKey = "request_log"
+
+// requestLog collects fields that are only known further down the chain,
+// such as the authenticated key and the investigated targets
+type requestLog struct {
+	mu           sync.Mutex
+	apiKeyID     string
+	targetHashes []string // as brain's hash_target
+	targetKeys   []string // as cache.HashTarget
+}
+
+// LoggingMiddleware writes one structured log line per request. Raw targets
+// never reach the log; handlers report them via SetLogTarget. target_hash is
+// computed like brain's hash_target, so API and brain log lines join on it,
+// and target_key is cache.HashTarget, the value the cache and operations
+// index use.
+func LoggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &requestLog{}
+		ctx := context.WithValue(r.Context(), requestLogContextKey, entry)
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		attrs := []slog.Attr{
+			slog.String("request_id", RequestIDFromContext(ctx)),
+			slog.String("method", r.Method),
+			slog.String("route", routeTemplate(r)),
+			slog.Int("status", recorder.status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+		}
+
+		entry.mu.Lock()
+		if entry.apiKeyID != "" {
+			attrs = append(attrs, slog.String("api_key_id", entry.apiKeyID))
+		}
+		switch len(entry.targetHashes) {
+		case 0:
+		case 1:
+			attrs = append(attrs,
+				slog.String("target_hash", entry.targetHashes[0]),
+				slog.String("target_key", entry.targetKeys[0]),
+			)
+		default:
+			attrs = append(attrs,
+				slog.Any("target_hashes", entry.targetHashes),
+				slog.Any("target_keys", entry.targetKeys),
+			)
+		}
+		entry.mu.Unlock()
+
+		level := slog.LevelInfo
+		switch {
+		case recorder.status >= 500:
+			level = slog.LevelError
+		case recorder.status >= 400:
+			level = slog.LevelWarn
+		}
+		slog.LogAttrs(ctx, level, "request", attrs...)
+	})
+}
+
+// SetLogTarget records a target investigated by the current request. Only
+// its hashes are logged.
+func SetLogTarget(ctx context.Context, target string) {
+	if entry, ok := ctx.Value(requestLogContextKey).(*requestLog); ok {
+		entry.mu.Lock()
+		entry.targetHashes = append(entry.targetHashes, hashTarget(target))
+		entry.targetKeys = append(entry.targetKeys, cache.HashTarget(target))
+		entry.mu.Unlock()
+	}
+}
+
+// hashTarget matches brain's hash_target: the first 16 hex digits of the
+// SHA-256 of the raw target
+func hashTarget(target string) string {
+	sum := sha256.Sum256([]byte(target))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// setLogAPIKey records the authenticated key ID for the request log line
+func setLogAPIKey(ctx context.Context, id string) {
+	if entry, ok := ctx.Value(requestLogContextKey).(*requestLog); ok {
+		entry.mu.Lock()
+		entry.apiKeyID = id
+		entry.mu.Unlock()
+	}
+}
+
+// ParseLogLevel maps LOG_LEVEL values (DEBUG, INFO, WARN/WARNING, ERROR) to
+// slog levels, defaulting to info
+func ParseLogLevel(value string) slog.Level {
+	switch strings.ToUpper(strings.TrimSpace(value)) {
+	case "DEBUG":
+		return slog.LevelDebug
+	case "WARN", "WARNING":
+		return slog.LevelWarn
+	case "ERROR", "CRITICAL":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
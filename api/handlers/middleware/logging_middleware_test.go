@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"osint-api/cache"
+)
+
+func TestLoggingTargetHash(t *testing.T) {
+	var out bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&out, nil)))
+
+	target := "Alice@Example.com "
+	chain := LoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetLogTarget(r.Context(), target)
+	}))
+	chain.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/v1/intel", nil))
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("log line %q: %v", out.String(), err)
+	}
+	// hashlib.sha256(target.encode()).hexdigest()[:16], as brain logs it
+	if line["target_hash"] != "9723fb3bea379ff1" {
+		t.Errorf("target_hash %v, want brain's hash", line["target_hash"])
+	}
+	if line["target_key"] != cache.HashTarget(target) {
+		t.Errorf("target_key %v, want the cache hash", line["target_key"])
+	}
+	if strings.Contains(out.String(), "lice@") {
+		t.Errorf("raw target in the log: %s", out.String())
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"osint-api/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Metrics records request counts and latency for everything router serves,
+// including requests no route matched, per route template so
+// /operations/{id} style routes do not explode label cardinality
+func Metrics(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		route := "unmatched"
+		var match mux.RouteMatch
+		if router.Match(r, &match) {
+			route = templateOf(match.Route)
+		}
+
+		router.ServeHTTP(recorder, r)
+
+		metrics.HTTPRequests.WithLabelValues(route, r.Method, strconv.Itoa(recorder.status)).Inc()
+		metrics.HTTPDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// routeTemplate returns the matched mux route template, or "unmatched"
+func routeTemplate(r *http.Request) string {
+	return templateOf(mux.CurrentRoute(r))
+}
+
+func templateOf(route *mux.Route) string {
+	if route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unmatched"
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const maxNoteLength = 10000
+
+// Note is a timestamped analyst comment on an operation
+type Note struct {
+	ID        string    `json:"id"`
+	Author    string    `json:"author,omitempty"` // API key ID
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SetTags replaces an operation's tags
+func (h *OpsHandler) SetTags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	operation, exists := h.operations[mux.Vars(r)["id"]]
+	var tags []string
+	if exists {
+		old := operation.Tags
+		operation.Tags = normalizeTags(request.Tags)
+		h.index.retag(operation, old)
+		h.persist(operation)
+		tags = operation.Tags
+	}
+	h.mu.Unlock()
+
+	if !exists {
+		h.sendError(w, "Operation not found", http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"operation_id": mux.Vars(r)["id"],
+		"tags":         append([]string{}, tags...),
+		"timestamp":    time.Now(),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// AddNote appends an analyst note to an operation
+func (h *OpsHandler) AddNote(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	text := strings.TrimSpace(request.Text)
+	if text == "" || len(text) > maxNoteLength {
+		h.sendError(w, fmt.Sprintf("Note text must be 1 to %d characters", maxNoteLength), http.StatusBadRequest)
+		return
+	}
+
+	note := Note{
+		ID:        fmt.Sprintf("note_%d_%s", time.Now().Unix(), randomString(6)),
+		Author:    ownerFromContext(r.Context()),
+		Text:      text,
+		CreatedAt: time.Now(),
+	}
+
+	h.mu.Lock()
+	operation, exists := h.operations[mux.Vars(r)["id"]]
+	if exists {
+		operation.Notes = append(operation.Notes, note)
+		h.persist(operation)
+	}
+	h.mu.Unlock()
+
+	if !exists {
+		h.sendError(w, "Operation not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(note)
+}
+
+// ListNotes returns an operation's notes, oldest first
+func (h *OpsHandler) ListNotes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	h.mu.RLock()
+	operation, exists := h.operations[mux.Vars(r)["id"]]
+	var notes []Note
+	if exists {
+		notes = append([]Note{}, operation.Notes...)
+	}
+	h.mu.RUnlock()
+
+	if !exists {
+		h.sendError(w, "Operation not found", http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"operation_id": mux.Vars(r)["id"],
+		"notes":        notes,
+		"total":        len(notes),
+		"timestamp":    time.Now(),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// setCase moves an operation into caseID, or out of its case when caseID is
+// empty. It reports whether the operation exists.
+func (h *OpsHandler) setCase(operationID, caseID string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	operation, exists := h.operations[operationID]
+	if !exists {
+		return false
+	}
+	old := operation.CaseID
+	operation.CaseID = caseID
+	h.index.recase(operation, old)
+	h.persist(operation)
+	return true
+}
+
+// detachCase removes every operation from caseID and returns how many
+// there were
+func (h *OpsHandler) detachCase(caseID string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	detached := 0
+	for _, operation := range h.index.byCase[caseID] {
+		operation.CaseID = ""
+		h.persist(operation)
+		detached++
+	}
+	delete(h.index.byCase, caseID)
+	return detached
+}
+
+// countByCase returns the number of operations in caseID
+func (h *OpsHandler) countByCase(caseID string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.index.byCase[caseID])
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"osint-api/cache"
+	"osint-api/findings"
+
+	"github.com/gorilla/mux"
+)
+
+// DiffOperation compares a completed operation's findings with those of an
+// earlier run against the same normalized target: the operation named by
+// against, or by default the latest completed one created before it
+func (h *OpsHandler) DiffOperation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	operationID := mux.Vars(r)["id"]
+	againstID := r.URL.Query().Get("against")
+
+	h.mu.RLock()
+	operation, exists := h.operations[operationID]
+	var against *Operation
+	var after, before []findings.Finding
+	var afterRisk, beforeRisk float64
+	message, status := "", 0
+	switch {
+	case !exists:
+		message, status = "Operation not found", http.StatusNotFound
+	case operation.Status != "completed":
+		message, status = fmt.Sprintf("Operation %s has not completed", operationID), http.StatusConflict
+	case againstID == "":
+		if against = h.previousRun(operation); against == nil {
+			message, status = "No earlier completed operation for this target", http.StatusNotFound
+		}
+	default:
+		var ok bool
+		if against, ok = h.operations[againstID]; !ok {
+			message, status = "Operation to compare against not found", http.StatusNotFound
+		} else if against.Status != "completed" {
+			message, status = fmt.Sprintf("Operation %s has not completed", againstID), http.StatusConflict
+		} else if cache.NormalizeTarget(against.Target) != cache.NormalizeTarget(operation.Target) {
+			message, status = "Operations investigated different targets", http.StatusBadRequest
+		}
+	}
+	if status == 0 {
+		againstID = against.ID
+		after, afterRisk = findings.Extract(operation.Results), operation.RiskScore
+		before, beforeRisk = findings.Extract(against.Results), against.RiskScore
+	}
+	h.mu.RUnlock()
+
+	if status != 0 {
+		h.sendError(w, message, status)
+		return
+	}
+
+	diff := findings.Compare(before, after)
+	response := map[string]interface{}{
+		"operation_id": operationID,
+		"against":      againstID,
+		"added":        diff.Added,
+		"removed":      diff.Removed,
+		"changed":      diff.Changed,
+		"incomplete":   diff.Incomplete,
+		"risk_score": map[string]interface{}{
+			"before": beforeRisk,
+			"after":  afterRisk,
+			"delta":  math.Round((afterRisk-beforeRisk)*1e6) / 1e6,
+		},
+		"summary": map[string]interface{}{
+			"added":     len(diff.Added),
+			"removed":   len(diff.Removed),
+			"changed":   len(diff.Changed),
+			"unchanged": diff.Unchanged,
+		},
+		"timestamp": time.Now(),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// previousRun returns the latest completed operation against the same
+// normalized target created before operation, or nil. Callers must hold h.mu.
+func (h *OpsHandler) previousRun(operation *Operation) *Operation {
+	var previous *Operation
+	for _, other := range h.index.byTarget[cache.HashTarget(operation.Target)] {
+		if other == operation || other.Status != "completed" || !other.CreatedAt.Before(operation.CreatedAt) {
+			continue
+		}
+		if previous == nil || other.CreatedAt.After(previous.CreatedAt) {
+			previous = other
+		}
+	}
+	return previous
+}
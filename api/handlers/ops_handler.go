@@ -1,42 +1,276 @@
 package handlers
 
 import (
+	"context"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"osint-api/cache"
+	"osint-api/handlers/middleware"
+	"osint-api/metrics"
+	"osint-api/orchestra"
+	"osint-api/protocol"
+	"osint-api/store"
+	"osint-api/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Operation represents an OSINT investigation operation
 type Operation struct {
-	ID           string                 `json:"id"`
-	Target       string                 `json:"target"`
-	Status       string                 `json:"status"` // pending, processing, completed, failed, cancelled
-	Priority     string                 `json:"priority"` // low, medium, high, critical
-	Progress     float64                `json:"progress"` // 0-100
-	CreatedAt    time.Time              `json:"created_at"`
-	StartedAt    *time.Time             `json:"started_at,omitempty"`
-	CompletedAt  *time.Time             `json:"completed_at,omitempty"`
-	Results      map[string]interface{} `json:"results,omitempty"`
-	Error        string                 `json:"error,omitempty"`
-	Duration     string                 `json:"duration,omitempty"`
-	Resources    []string               `json:"resources,omitempty"` // Scrapy, SpiderFoot, etc.
-	RiskScore    float64                `json:"risk_score,omitempty"`
-	Findings     int                    `json:"findings_count,omitempty"`
+	ID          string                 `json:"id"`
+	Target      string                 `json:"target"`
+	Status      string                 `json:"status"`   // pending, processing, completed, failed, cancelled
+	Priority    string                 `json:"priority"` // low, medium, high, critical
+	Progress    float64                `json:"progress"` // 0-100
+	CreatedAt   time.Time              `json:"created_at"`
+	StartedAt   *time.Time             `json:"started_at,omitempty"`
+	CompletedAt *time.Time             `json:"completed_at,omitempty"`
+	Results     map[string]interface{} `json:"results,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	Duration    string                 `json:"duration,omitempty"`
+	Resources   []string               `json:"resources,omitempty"` // Scrapy, SpiderFoot, etc.
+	RiskScore   float64                `json:"risk_score,omitempty"`
+	Findings    int                    `json:"findings_count,omitempty"`
+	Modules     []string               `json:"modules,omitempty"`
+	Coalesced   int                    `json:"coalesced_requests,omitempty"` // duplicate submissions attached to this operation
+	RequestID   string                 `json:"request_id,omitempty"`         // request that created the operation
+	Owner       string                 `json:"owner,omitempty"`              // API key that created the operation
+	Attached    []string               `json:"attached_owners,omitempty"`    // other API keys whose submissions joined it
+	Tags        []string               `json:"tags,omitempty"`
+	CaseID      string                 `json:"case_id,omitempty"`
+	ScheduleID  string                 `json:"schedule_id,omitempty"`           // schedule that started the operation
+	Schedules   []string               `json:"attached_schedule_ids,omitempty"` // other schedules whose runs joined it
+	Notes       []Note                 `json:"notes,omitempty"`                 // analyst notes, oldest first
+	Attempts    []Attempt              `json:"attempts,omitempty"`              // every run so far, oldest first
+	NextRetryAt *time.Time             `json:"next_retry_at,omitempty"`         // when a failed attempt is retried
+
+	key         string // cache key of target and modules, used for coalescing
+	scanData    map[string]interface{}
+	spanContext trace.SpanContext // span of the submitting request, continued by the worker
+	reply       []byte            // raw orchestra reply, shared by every attached caller
+	failure     error             // why the operation failed, returned to waiters
+	trigger     string            // what queued the next attempt, see Attempt.Trigger
+	done        chan struct{}     // closed once the operation reaches a final status
+	cancel      context.CancelFunc
+	holds       map[string]int // submissions still relying on the operation, by owner
+}
+
+// LocalModule is an investigation module the API runs in-process instead of
+// delegating to orchestra
+type LocalModule interface {
+	Run(ctx context.Context, target string) (interface{}, error)
+}
+
+// OperationSpec describes an investigation to schedule
+type OperationSpec struct {
+	OperationID string // optional; generated when empty or already taken
+	Target      string
+	Priority    string
+	Modules     []string
+	ScanData    map[string]interface{}
+	RequestID   string // correlation ID of the submitting request
+	Owner       string // API key ID of the submitter
+	Tags        []string
+	ScheduleID  string            // schedule the operation is a run of
+	Trace       trace.SpanContext // span of the submitting request
 }
 
-// OpsHandler manages OSINT operations
+// OpsHandler manages OSINT operations and schedules them against orchestra.
+// Operations requesting only local modules are run in-process.
 type OpsHandler struct {
+	Orchestra *orchestra.Client
+	Modules   map[string]LocalModule
+	Store     *store.Collection // optional, plaintext 0600 files; nil keeps operations in memory only
+	Retry     RetryPolicy
+	// HashedTargetSearch restricts the list target filter to exact matches
+	// by hash, so stored targets are never substring-scanned
+	HashedTargetSearch bool
+	// OnComplete, if set, is called in its own goroutine with a copy of
+	// every operation that completes
+	OnComplete func(operation Operation)
 	operations map[string]*Operation
+	index      *operationIndex
+	inflight   map[string]*Operation // pending or processing operations by cache key
+	writer     *store.Writer         // writes Store outside mu
 	mu         sync.RWMutex
 }
 
 // NewOpsHandler creates a new operations handler
-func NewOpsHandler() *OpsHandler {
+func NewOpsHandler(client *orchestra.Client) *OpsHandler {
 	return &OpsHandler{
+		Orchestra:  client,
+		Modules:    make(map[string]LocalModule),
+		Retry:      RetryPolicy{MaxAttempts: 1},
 		operations: make(map[string]*Operation),
+		index:      newOperationIndex(),
+		inflight:   make(map[string]*Operation),
+	}
+}
+
+// Submit schedules an investigation. If an identical investigation (same
+// normalized target and module set) is already pending or processing, the
+// caller is attached to that operation instead of starting a duplicate scan,
+// and its owner and tags are merged in.
+func (h *OpsHandler) Submit(spec OperationSpec) (operation *Operation, attached bool) {
+	if spec.Priority == "" {
+		spec.Priority = "medium"
+	}
+	key := cache.Key(spec.Target, spec.Modules, spec.ScanData)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if existing, ok := h.inflight[key]; ok {
+		existing.Coalesced++
+		h.attach(existing, spec)
+		return existing, true
+	}
+
+	operationID := spec.OperationID
+	if _, taken := h.operations[operationID]; operationID == "" || taken {
+		operationID = generateOperationID()
+	}
+
+	operation = &Operation{
+		ID:          operationID,
+		Target:      spec.Target,
+		Status:      "pending",
+		Priority:    spec.Priority,
+		Progress:    0,
+		CreatedAt:   time.Now(),
+		Resources:   []string{"Scrapy", "SpiderFoot", "AI Analysis"},
+		Modules:     spec.Modules,
+		RequestID:   spec.RequestID,
+		Owner:       spec.Owner,
+		Tags:        normalizeTags(spec.Tags),
+		ScheduleID:  spec.ScheduleID,
+		key:         key,
+		scanData:    spec.ScanData,
+		spanContext: spec.Trace,
+		holds:       map[string]int{spec.Owner: 1},
+	}
+	h.operations[operationID] = operation
+	h.index.add(operation)
+	h.persist(operation)
+	h.start(operation)
+
+	return operation, false
+}
+
+// attach merges a duplicate submission into operation: the submitter becomes
+// an attached owner, its tags and schedule are added and it holds the
+// operation until it cancels. Callers must hold h.mu.
+func (h *OpsHandler) attach(operation *Operation, spec OperationSpec) {
+	if operation.holds == nil {
+		operation.holds = make(map[string]int)
+	}
+	operation.holds[spec.Owner]++
+
+	if spec.Owner != "" && spec.Owner != operation.Owner && !containsString(operation.Attached, spec.Owner) {
+		operation.Attached = append(operation.Attached, spec.Owner)
+		h.index.addOwner(operation, spec.Owner)
+	}
+	if tags := normalizeTags(append(append([]string(nil), operation.Tags...), spec.Tags...)); len(tags) != len(operation.Tags) {
+		old := operation.Tags
+		operation.Tags = tags
+		h.index.retag(operation, old)
+	}
+	if spec.ScheduleID != "" && spec.ScheduleID != operation.ScheduleID && !containsString(operation.Schedules, spec.ScheduleID) {
+		operation.Schedules = append(operation.Schedules, spec.ScheduleID)
+		h.index.addSchedule(operation, spec.ScheduleID)
+	}
+	h.persist(operation)
+}
+
+// detach releases one of caller's submissions to a shared operation and
+// reports how many submissions still rely on it. Nothing changes, and 0 is
+// returned, when caller holds nothing or is the only one left. Callers must
+// hold h.mu.
+func (h *OpsHandler) detach(operation *Operation, caller string) int {
+	remaining := -1
+	for _, n := range operation.holds {
+		remaining += n
+	}
+	if operation.holds[caller] == 0 || remaining == 0 {
+		return 0
+	}
+
+	if operation.holds[caller]--; operation.holds[caller] == 0 {
+		delete(operation.holds, caller)
+		for i, owner := range operation.Attached {
+			if owner == caller {
+				operation.Attached = append(operation.Attached[:i:i], operation.Attached[i+1:]...)
+				h.index.removeOwner(operation, caller)
+				break
+			}
+		}
+	}
+	h.persist(operation)
+	return remaining
+}
+
+// start launches the worker for a pending operation. Callers must hold h.mu.
+func (h *OpsHandler) start(operation *Operation) {
+	// The worker outlives the request, so it keeps the trace but not the
+	// request's cancellation
+	ctx, cancel := context.WithCancel(trace.ContextWithSpanContext(context.Background(), operation.spanContext))
+	operation.cancel = cancel
+	operation.done = make(chan struct{})
+	if _, busy := h.inflight[operation.key]; !busy {
+		h.inflight[operation.key] = operation
+	}
+
+	go h.processOperation(ctx, operation)
+}
+
+// Wait blocks until operation finishes or ctx is done and returns the
+// orchestra reply. Every caller attached to the operation gets the same reply.
+func (h *OpsHandler) Wait(ctx context.Context, operation *Operation) ([]byte, error) {
+	// A retry replaces done, so it is read under the lock
+	h.mu.RLock()
+	done := operation.done
+	h.mu.RUnlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	switch operation.Status {
+	case "completed":
+		return operation.reply, nil
+	case "pending":
+		return nil, errRequeued
+	default:
+		if operation.failure != nil {
+			return nil, operation.failure
+		}
+		return nil, errors.New(operation.Error)
+	}
+}
+
+// Unavailable returns a *orchestra.CircuitOpenError if an investigation of
+// modules would need orchestra while its circuit breaker is open
+func (h *OpsHandler) Unavailable(modules []string) error {
+	if h.Orchestra == nil || h.localModules(modules) != nil {
+		return nil
 	}
+	return h.Orchestra.Breaker().Check()
 }
 
 // CreateOperation creates a new OSINT operation
@@ -44,8 +278,10 @@ func (h *OpsHandler) CreateOperation(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var request struct {
-		Target   string `json:"target"`
-		Priority string `json:"priority"`
+		Target   string   `json:"target"`
+		Priority string   `json:"priority"`
+		Modules  []string `json:"modules"`
+		Tags     []string `json:"tags"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -57,36 +293,44 @@ func (h *OpsHandler) CreateOperation(w http.ResponseWriter, r *http.Request) {
 		h.sendError(w, "Target is required", http.StatusBadRequest)
 		return
 	}
-
-	if request.Priority == "" {
-		request.Priority = "medium"
+	if err := validateModules(request.Modules); err != nil {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+	middleware.SetLogTarget(r.Context(), request.Target)
 
-	operationID := generateOperationID()
-	now := time.Now()
+	if err := h.Unavailable(request.Modules); err != nil {
+		h.sendError(w, err.Error(), errorStatus(w, err))
+		return
+	}
 
-	operation := &Operation{
-		ID:        operationID,
+	operation, attached := h.Submit(OperationSpec{
 		Target:    request.Target,
-		Status:    "pending",
 		Priority:  request.Priority,
-		Progress:  0,
-		CreatedAt: now,
-		Resources: []string{"Scrapy", "SpiderFoot", "AI Analysis"},
-	}
-
-	h.mu.Lock()
-	h.operations[operationID] = operation
-	h.mu.Unlock()
+		Modules:   request.Modules,
+		Tags:      request.Tags,
+		Owner:     ownerFromContext(r.Context()),
+		RequestID: middleware.RequestIDFromContext(r.Context()),
+		Trace:     trace.SpanContextFromContext(r.Context()),
+	})
+
+	if attached {
+		response := map[string]interface{}{
+			"operation_id": operation.ID,
+			"status":       "attached",
+			"message":      "Identical investigation already in progress",
+			"created_at":   operation.CreatedAt,
+		}
 
-	// Simulate async processing (in real implementation, this would be a background worker)
-	go h.processOperation(operation)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
 
 	response := map[string]interface{}{
-		"operation_id": operationID,
+		"operation_id": operation.ID,
 		"status":       "created",
 		"message":      "Operation queued for processing",
-		"created_at":   now,
+		"created_at":   operation.CreatedAt,
 	}
 
 	w.WriteHeader(http.StatusCreated)
@@ -103,8 +347,13 @@ func (h *OpsHandler) GetOperationStatus(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Copy under the lock: the worker keeps updating the operation
 	h.mu.RLock()
 	operation, exists := h.operations[operationID]
+	var snapshot Operation
+	if exists {
+		snapshot = *operation
+	}
 	h.mu.RUnlock()
 
 	if !exists {
@@ -112,64 +361,46 @@ func (h *OpsHandler) GetOperationStatus(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	json.NewEncoder(w).Encode(operation)
+	json.NewEncoder(w).Encode(snapshot)
 }
 
-// ListOperations returns a list of all operations with optional filtering
+// ListOperations returns one page of operations. Filters: status, priority,
+// target, owner, case, schedule, note (text substring), tag (repeatable, all
+// must match), created_after, created_before, min_risk and max_risk. Sort by
+// created_at (default), priority, risk_score or duration, newest or highest
+// first unless order=asc; pass next_cursor back as cursor for the following
+// page.
 func (h *OpsHandler) ListOperations(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Get query parameters for filtering
-	statusFilter := r.URL.Query().Get("status")
-	priorityFilter := r.URL.Query().Get("priority")
-	limit := 50 // Default limit
-
-	// Parse limit parameter
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if n, err := fmt.Sscanf(limitStr, "%d", &limit); err != nil || n != 1 {
-			limit = 50
-		}
-		if limit > 1000 {
-			limit = 1000
-		}
+	query, err := parseOperationQuery(r.URL.Query())
+	if err != nil {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if query.Target != "" {
+		middleware.SetLogTarget(r.Context(), query.Target)
 	}
 
 	h.mu.RLock()
-	operations := make([]*Operation, 0, len(h.operations))
-	
-	for _, op := range h.operations {
-		// Apply filters
-		if statusFilter != "" && op.Status != statusFilter {
-			continue
-		}
-		if priorityFilter != "" && op.Priority != priorityFilter {
-			continue
-		}
-		operations = append(operations, op)
-	}
+	operations, total, next := h.index.query(query, h.HashedTargetSearch)
 	h.mu.RUnlock()
 
-	// Sort by creation time (newest first)
-	for i := 0; i < len(operations)-1; i++ {
-		for j := i + 1; j < len(operations); j++ {
-			if operations[i].CreatedAt.Before(operations[j].CreatedAt) {
-				operations[i], operations[j] = operations[j], operations[i]
-			}
-		}
-	}
-
-	// Apply limit
-	if len(operations) > limit {
-		operations = operations[:limit]
-	}
-
 	response := map[string]interface{}{
-		"operations": operations,
-		"total":      len(operations),
-		"limit":      limit,
-		"filters": map[string]string{
-			"status":   statusFilter,
-			"priority": priorityFilter,
+		"operations":  operations,
+		"count":       len(operations),
+		"total":       total,
+		"limit":       query.Limit,
+		"next_cursor": next,
+		"sort":        query.Sort,
+		"order":       map[bool]string{true: "desc", false: "asc"}[query.Desc],
+		"filters": map[string]interface{}{
+			"status":   query.Status,
+			"priority": query.Priority,
+			"owner":    query.Owner,
+			"case":     query.Case,
+			"schedule": query.Schedule,
+			"tags":     query.Tags,
 		},
 		"timestamp": time.Now(),
 	}
@@ -177,7 +408,9 @@ func (h *OpsHandler) ListOperations(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// CancelOperation cancels a running operation
+// CancelOperation cancels a running operation. A caller sharing the
+// operation with other submitters only detaches from it; the work goes on
+// for the others.
 func (h *OpsHandler) CancelOperation(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -189,11 +422,12 @@ func (h *OpsHandler) CancelOperation(w http.ResponseWriter, r *http.Request) {
 
 	h.mu.Lock()
 	operation, exists := h.operations[operationID]
+	others := 0
 	if exists && (operation.Status == "pending" || operation.Status == "processing") {
-		operation.Status = "cancelled"
-		operation.CompletedAt = &[]time.Time{time.Now()}[0]
-		operation.Progress = 0
-		operation.Error = "Operation cancelled by user"
+		if others = h.detach(operation, ownerFromContext(r.Context())); others == 0 {
+			h.finishOperation(operation, "cancelled", "Operation cancelled by user")
+			operation.Progress = 0
+		}
 	}
 	h.mu.Unlock()
 
@@ -202,6 +436,18 @@ func (h *OpsHandler) CancelOperation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if others > 0 {
+		response := map[string]interface{}{
+			"operation_id":      operationID,
+			"status":            "detached",
+			"message":           "Operation is shared; it keeps running for the other callers",
+			"remaining_callers": others,
+			"timestamp":         time.Now(),
+		}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
 	response := map[string]interface{}{
 		"operation_id": operationID,
 		"status":       "cancelled",
@@ -220,14 +466,14 @@ func (h *OpsHandler) GetOperationsStats(w http.ResponseWriter, r *http.Request)
 	defer h.mu.RUnlock()
 
 	stats := map[string]interface{}{
-		"total_operations":    len(h.operations),
-		"pending_operations":  0,
+		"total_operations":      len(h.operations),
+		"pending_operations":    0,
 		"processing_operations": 0,
-		"completed_operations": 0,
-		"failed_operations":   0,
-		"cancelled_operations": 0,
-		"average_duration":    "0s",
-		"success_rate":        0.0,
+		"completed_operations":  0,
+		"failed_operations":     0,
+		"cancelled_operations":  0,
+		"average_duration":      "0s",
+		"success_rate":          0.0,
 	}
 
 	var totalDuration time.Duration
@@ -290,71 +536,245 @@ func (h *OpsHandler) CleanupOperations(w http.ResponseWriter, r *http.Request) {
 	for id, op := range h.operations {
 		if op.CreatedAt.Before(cutoff) && (op.Status == "completed" || op.Status == "failed" || op.Status == "cancelled") {
 			delete(h.operations, id)
+			h.index.remove(op)
+			h.unpersist(id)
 			deletedCount++
 		}
 	}
 	h.mu.Unlock()
 
 	response := map[string]interface{}{
-		"deleted_count": deletedCount,
-		"max_age":       maxAge.String(),
-		"cutoff_time":   cutoff,
+		"deleted_count":        deletedCount,
+		"max_age":              maxAge.String(),
+		"cutoff_time":          cutoff,
 		"remaining_operations": len(h.operations),
-		"timestamp":     time.Now(),
+		"timestamp":            time.Now(),
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
-// processOperation simulates processing an OSINT operation
-func (h *OpsHandler) processOperation(operation *Operation) {
+// CountOperations reports operations by status and priority for metrics
+func (h *OpsHandler) CountOperations() []metrics.OperationCount {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	counts := make(map[[2]string]int)
+	for _, op := range h.operations {
+		counts[[2]string{op.Status, op.Priority}]++
+	}
+
+	result := make([]metrics.OperationCount, 0, len(counts))
+	for key, count := range counts {
+		result = append(result, metrics.OperationCount{Status: key[0], Priority: key[1], Count: count})
+	}
+	return result
+}
+
+// processOperation runs an operation against orchestra, or in-process when
+// every requested module is local
+func (h *OpsHandler) processOperation(ctx context.Context, operation *Operation) {
+	ctx, span := tracing.Tracer().Start(ctx, "operation.process", trace.WithAttributes(
+		attribute.String("operation.id", operation.ID),
+		attribute.String("operation.priority", operation.Priority),
+		attribute.StringSlice("operation.modules", operation.Modules),
+		attribute.String("target.hash", cache.HashTarget(operation.Target)),
+	))
+	defer span.End()
+
 	h.mu.Lock()
+	// Skip operations cancelled or re-queued before the worker got going
+	if operation.Status != "pending" || ctx.Err() != nil {
+		h.mu.Unlock()
+		return
+	}
 	operation.Status = "processing"
 	startTime := time.Now()
 	operation.StartedAt = &startTime
+	operation.NextRetryAt = nil
+	operation.Progress = 10
+	span.SetAttributes(attribute.Int("operation.attempt", len(operation.Attempts)+1))
+
+	request, err := protocol.New(protocol.TypeInvestigate, "", protocol.InvestigateRequest{
+		OperationID: operation.ID,
+		Target:      operation.Target,
+		Modules:     operation.Modules,
+		Priority:    operation.Priority,
+		ScanData:    operation.scanData,
+		Attempt:     len(operation.Attempts) + 1,
+	})
+	if err == nil {
+		request.RequestID = operation.RequestID
+	}
+	local := h.localModules(operation.Modules)
 	h.mu.Unlock()
 
-	// Simulate processing stages
-	stages := []struct {
-		name     string
-		duration time.Duration
-		progress float64
-	}{
-		{"Initializing", 2 * time.Second, 10},
-		{"Scrapy Crawling", 8 * time.Second, 30},
-		{"SpiderFoot Analysis", 6 * time.Second, 60},
-		{"AI Pattern Recognition", 4 * time.Second, 80},
-		{"Final Correlation", 2 * time.Second, 100},
-	}
-
-	for _, stage := range stages {
-		time.Sleep(stage.duration)
-
-		h.mu.Lock()
-		if operation.Status == "cancelled" {
-			h.mu.Unlock()
-			return
+	var reply []byte
+	span.SetAttributes(attribute.Bool("operation.local", local != nil))
+	if local != nil {
+		reply, err = h.runLocalModules(ctx, operation, local, startTime)
+	} else if err == nil {
+		var response *protocol.Envelope
+		if response, err = h.Orchestra.Call(ctx, request); err == nil {
+			reply = response.Payload
+		}
+	}
+
+	var results map[string]interface{}
+	if err == nil {
+		if jsonErr := json.Unmarshal(reply, &results); jsonErr != nil {
+			err = errors.New("invalid response format from orchestra")
+		} else if msg, failed := results["error"]; failed {
+			err = fmt.Errorf("%v", msg)
 		}
-		operation.Progress = stage.progress
-		h.mu.Unlock()
 	}
 
-	// Final results
 	h.mu.Lock()
-	operation.Status = "completed"
+	defer h.mu.Unlock()
+
+	// A cancelled operation keeps its status even if orchestra answers later
+	if operation.Status != "processing" {
+		return
+	}
+
+	h.recordAttempt(operation, startTime, err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		if h.scheduleRetry(ctx, operation, err) {
+			return
+		}
+		operation.failure = err
+		h.finishOperation(operation, "failed", err.Error())
+		return
+	}
+
+	operation.reply = reply
+	operation.Results = results
+	operation.Progress = 100
+	operation.RiskScore, operation.Findings = summarizeResults(results)
+	h.finishOperation(operation, "completed", "")
+}
+
+// finishOperation moves an operation to a final status, releases its
+// coalescing slot and wakes every waiter. Callers must hold h.mu.
+func (h *OpsHandler) finishOperation(operation *Operation, status, errMsg string) {
 	completeTime := time.Now()
+	operation.Status = status
+	operation.Error = errMsg
 	operation.CompletedAt = &completeTime
-	operation.Duration = completeTime.Sub(startTime).String()
-	operation.Results = map[string]interface{}{
-		"platforms_checked": 15,
-		"findings_count":    23,
-		"risk_score":        0.72,
-		"pattern_type":      "professional_account",
-		"confidence":        0.88,
-	}
-	operation.Findings = 23
-	operation.RiskScore = 0.72
-	h.mu.Unlock()
+	if operation.StartedAt != nil {
+		operation.Duration = completeTime.Sub(*operation.StartedAt).String()
+	}
+	h.index.resort(operation)
+
+	if h.inflight[operation.key] == operation {
+		delete(h.inflight, operation.key)
+	}
+	operation.cancel()
+	close(operation.done)
+	h.persist(operation)
+
+	if status == "completed" && h.OnComplete != nil {
+		go h.OnComplete(*operation)
+	}
+}
+
+// localModules returns the local implementations of modules, or nil unless
+// every one of them is local
+func (h *OpsHandler) localModules(modules []string) map[string]LocalModule {
+	if len(modules) == 0 {
+		return nil
+	}
+
+	local := make(map[string]LocalModule, len(modules))
+	for _, name := range modules {
+		module, ok := h.Modules[name]
+		if !ok {
+			return nil
+		}
+		local[name] = module
+	}
+	return local
+}
+
+// maxModules bounds the modules one investigation may request
+const maxModules = 32
+
+// validateModules rejects module lists no investigation can run: too many
+// names, or blank, duplicate or malformed ones. Names are lowercase letters,
+// digits, '_' and '-'.
+func validateModules(modules []string) error {
+	if len(modules) > maxModules {
+		return fmt.Errorf("at most %d modules may be requested", maxModules)
+	}
+	seen := make(map[string]bool, len(modules))
+	for _, name := range modules {
+		if name == "" || len(name) > 64 || strings.Trim(name, "abcdefghijklmnopqrstuvwxyz0123456789_-") != "" {
+			return fmt.Errorf("invalid module name %q", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("module %q is listed twice", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// runLocalModules runs modules in-process and renders an IntelResponse as
+// the operation's reply
+func (h *OpsHandler) runLocalModules(ctx context.Context, operation *Operation, modules map[string]LocalModule, startTime time.Time) ([]byte, error) {
+	results := make(map[string]interface{}, len(modules))
+	for name, module := range modules {
+		moduleCtx, span := tracing.Tracer().Start(ctx, "module."+name)
+		result, err := module.Run(moduleCtx, operation.Target)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			span.End()
+			return nil, fmt.Errorf("module %s failed: %w", name, err)
+		}
+		span.End()
+		results[name] = result
+	}
+
+	finished := time.Now()
+	return json.Marshal(IntelResponse{
+		OperationID: operation.ID,
+		Target:      operation.Target,
+		Status:      "completed",
+		Results:     results,
+		Timestamps: Timestamps{
+			Started:  startTime,
+			Finished: finished,
+			Duration: finished.Sub(startTime).String(),
+		},
+	})
+}
+
+// summarizeResults extracts the risk score and findings count from an
+// orchestra correlation report or a local module reply
+func summarizeResults(results map[string]interface{}) (riskScore float64, findings int) {
+	if correlation, ok := results["correlation"].(map[string]interface{}); ok {
+		riskScore, _ = correlation["risk_score"].(float64)
+	}
+	if spiderfoot, ok := results["spiderfoot"].(map[string]interface{}); ok {
+		if count, ok := spiderfoot["findings_count"].(float64); ok {
+			findings = int(count)
+		}
+	}
+
+	// Local module replies nest each module's output under "results"
+	if modules, ok := results["results"].(map[string]interface{}); ok {
+		for _, result := range modules {
+			if module, ok := result.(map[string]interface{}); ok {
+				if count, ok := module["found_count"].(float64); ok {
+					findings += int(count)
+				}
+			}
+		}
+	}
+	return riskScore, findings
 }
 
 // sendError sends a standardized error response
@@ -363,6 +783,7 @@ func (h *OpsHandler) sendError(w http.ResponseWriter, message string, statusCode
 		"error":       message,
 		"status":      "error",
 		"status_code": statusCode,
+		"request_id":  w.Header().Get(middleware.RequestIDHeader),
 		"timestamp":   time.Now(),
 	}
 
@@ -370,6 +791,27 @@ func (h *OpsHandler) sendError(w http.ResponseWriter, message string, statusCode
 	json.NewEncoder(w).Encode(errorResponse)
 }
 
+// ownerFromContext returns the ID of the API key making the request
+func ownerFromContext(ctx context.Context) string {
+	apiKey, _ := middleware.APIKeyFromContext(ctx)
+	return apiKey.ID
+}
+
+// errorStatus returns the HTTP status for a failed investigation. While
+// orchestra's circuit is open that is 503, with Retry-After set on w.
+func errorStatus(w http.ResponseWriter, err error) int {
+	var open *orchestra.CircuitOpenError
+	if !errors.As(err, &open) {
+		return http.StatusInternalServerError
+	}
+	seconds := int(math.Ceil(open.RetryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	return http.StatusServiceUnavailable
+}
+
 // generateOperationID generates a unique operation ID
 func generateOperationID() string {
 	return fmt.Sprintf("op_%d_%s", time.Now().Unix(), randomString(6))
@@ -379,8 +821,11 @@ func generateOperationID() string {
 func randomString(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
 	result := make([]byte, length)
+	if _, err := rand.Read(result); err != nil {
+		return "default"
+	}
 	for i := range result {
-		result[i] = charset[time.Now().UnixNano()%int64(len(charset))]
+		result[i] = charset[int(result[i])%len(charset)]
 	}
 	return string(result)
 }
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// blockingModule runs until its operation is cancelled
+type blockingModule struct{}
+
+func (blockingModule) Run(ctx context.Context, target string) (interface{}, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestSubmitAttach(t *testing.T) {
+	h := NewOpsHandler(nil)
+	h.Modules["block"] = blockingModule{}
+
+	spec := OperationSpec{Target: "xavier", Modules: []string{"block"}, Owner: "key_a", Tags: []string{"fraud"}}
+	operation, attached := h.Submit(spec)
+	if attached {
+		t.Fatal("first submission attached")
+	}
+	spec.Owner, spec.Tags = "key_b", []string{"Fraud", "vip"}
+	if joined, attached := h.Submit(spec); !attached || joined != operation {
+		t.Fatalf("duplicate submission started %s", joined.ID)
+	}
+
+	h.mu.RLock()
+	if operation.Owner != "key_a" || len(operation.Attached) != 1 || operation.Attached[0] != "key_b" || len(operation.Tags) != 2 || operation.Tags[1] != "vip" {
+		t.Errorf("attached operation %+v", operation)
+	}
+	byB, byTag := h.index.byOwner["key_b"][operation.ID], h.index.byTag["vip"][operation.ID]
+	h.mu.RUnlock()
+	if byB == nil || byTag == nil {
+		t.Errorf("attached metadata not indexed: owner %v, tag %v", byB != nil, byTag != nil)
+	}
+
+	// key_b detaching leaves the work running for key_a
+	h.mu.Lock()
+	remaining := h.detach(operation, "key_b")
+	h.mu.Unlock()
+	if remaining != 1 {
+		t.Fatalf("%d callers left, want 1", remaining)
+	}
+	h.mu.RLock()
+	if len(operation.Attached) != 0 || h.index.byOwner["key_b"][operation.ID] != nil || operation.Status == "cancelled" {
+		t.Errorf("after detaching key_b: %+v", operation)
+	}
+	h.mu.RUnlock()
+}
+
+func TestCancelShared(t *testing.T) {
+	h := NewOpsHandler(nil)
+	h.Modules["block"] = blockingModule{}
+	spec := OperationSpec{Target: "yvonne", Modules: []string{"block"}}
+	operation, _ := h.Submit(spec)
+	h.Submit(spec)
+
+	cancel := func() map[string]interface{} {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.CancelOperation(rec, httptest.NewRequest("DELETE", "/api/v1/operations/cancel?id="+operation.ID, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("cancel: status %d", rec.Code)
+		}
+		var body map[string]interface{}
+		json.NewDecoder(rec.Body).Decode(&body)
+		return body
+	}
+
+	if body := cancel(); body["status"] != "detached" || body["remaining_callers"] != 1.0 {
+		t.Fatalf("first cancel of a shared operation: %v", body)
+	}
+	h.mu.RLock()
+	status := operation.Status
+	h.mu.RUnlock()
+	if status == "cancelled" {
+		t.Fatal("shared operation was cancelled while a caller still waits")
+	}
+
+	if body := cancel(); body["status"] != "cancelled" {
+		t.Fatalf("last caller's cancel: %v", body)
+	}
+	if _, err := h.Wait(context.Background(), operation); err == nil {
+		t.Error("cancelled operation returned a reply")
+	}
+}
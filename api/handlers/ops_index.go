@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"sort"
+	"strings"
+
+	"osint-api/cache"
+)
+
+// operationIndex keeps operations ordered by creation time and by every
+// other sort field, with secondary indexes by owner, tag, case, schedule and
+// target hash, so listing does not have to sort every operation. It lives in
+// memory only and is rebuilt from the store at startup. Callers must hold
+// OpsHandler.mu.
+type operationIndex struct {
+	byCreation []*Operation            // oldest first, ties broken by ID
+	bySort     map[string]*sortedIndex // priority, risk_score and duration
+	byOwner    map[string]map[string]*Operation
+	byTag      map[string]map[string]*Operation
+	byCase     map[string]map[string]*Operation
+	bySchedule map[string]map[string]*Operation
+	byTarget   map[string]map[string]*Operation // keyed by cache.HashTarget
+}
+
+func newOperationIndex() *operationIndex {
+	return &operationIndex{
+		bySort: map[string]*sortedIndex{
+			"priority":   newSortedIndex("priority"),
+			"risk_score": newSortedIndex("risk_score"),
+			"duration":   newSortedIndex("duration"),
+		},
+		byOwner:    make(map[string]map[string]*Operation),
+		byTag:      make(map[string]map[string]*Operation),
+		byCase:     make(map[string]map[string]*Operation),
+		bySchedule: make(map[string]map[string]*Operation),
+		byTarget:   make(map[string]map[string]*Operation),
+	}
+}
+
+// add indexes an operation. Operations are usually created in order, so
+// this is an append; restored ones are inserted in place.
+func (x *operationIndex) add(operation *Operation) {
+	i := x.position(operation)
+	x.byCreation = append(x.byCreation, nil)
+	copy(x.byCreation[i+1:], x.byCreation[i:])
+	x.byCreation[i] = operation
+	for _, sorted := range x.bySort {
+		sorted.insert(operation)
+	}
+
+	addTo(x.byOwner, operation.Owner, operation)
+	for _, owner := range operation.Attached {
+		addTo(x.byOwner, owner, operation)
+	}
+	addTo(x.byCase, operation.CaseID, operation)
+	addTo(x.bySchedule, operation.ScheduleID, operation)
+	for _, schedule := range operation.Schedules {
+		addTo(x.bySchedule, schedule, operation)
+	}
+	addTo(x.byTarget, cache.HashTarget(operation.Target), operation)
+	for _, tag := range operation.Tags {
+		addTo(x.byTag, tag, operation)
+	}
+}
+
+// remove drops an operation from every index
+func (x *operationIndex) remove(operation *Operation) {
+	if i := x.position(operation); i < len(x.byCreation) && x.byCreation[i] == operation {
+		x.byCreation = append(x.byCreation[:i], x.byCreation[i+1:]...)
+	}
+	for _, sorted := range x.bySort {
+		sorted.delete(operation)
+	}
+
+	removeFrom(x.byOwner, operation.Owner, operation)
+	for _, owner := range operation.Attached {
+		removeFrom(x.byOwner, owner, operation)
+	}
+	removeFrom(x.byCase, operation.CaseID, operation)
+	removeFrom(x.bySchedule, operation.ScheduleID, operation)
+	for _, schedule := range operation.Schedules {
+		removeFrom(x.bySchedule, schedule, operation)
+	}
+	removeFrom(x.byTarget, cache.HashTarget(operation.Target), operation)
+	for _, tag := range operation.Tags {
+		removeFrom(x.byTag, tag, operation)
+	}
+}
+
+// addOwner lists an operation under an attached owner
+func (x *operationIndex) addOwner(operation *Operation, owner string) {
+	addTo(x.byOwner, owner, operation)
+}
+
+// removeOwner drops an operation from an owner it no longer has
+func (x *operationIndex) removeOwner(operation *Operation, owner string) {
+	removeFrom(x.byOwner, owner, operation)
+}
+
+// addSchedule lists an operation under a schedule whose run joined it
+func (x *operationIndex) addSchedule(operation *Operation, schedule string) {
+	addTo(x.bySchedule, schedule, operation)
+}
+
+// retag moves an operation from its old tags to its current ones
+func (x *operationIndex) retag(operation *Operation, old []string) {
+	for _, tag := range old {
+		removeFrom(x.byTag, tag, operation)
+	}
+	for _, tag := range operation.Tags {
+		addTo(x.byTag, tag, operation)
+	}
+}
+
+// recase moves an operation from its old case to its current one
+func (x *operationIndex) recase(operation *Operation, old string) {
+	removeFrom(x.byCase, old, operation)
+	addTo(x.byCase, operation.CaseID, operation)
+}
+
+// resort moves an operation to where its current risk score and duration
+// sort. Call it after changing either.
+func (x *operationIndex) resort(operation *Operation) {
+	for _, sorted := range x.bySort {
+		sorted.delete(operation)
+		sorted.insert(operation)
+	}
+}
+
+// ordered returns every operation in ascending order of field
+func (x *operationIndex) ordered(field string) []*Operation {
+	if sorted, ok := x.bySort[field]; ok {
+		return sorted.ops
+	}
+	return x.byCreation
+}
+
+// position returns where operation sorts in byCreation
+func (x *operationIndex) position(operation *Operation) int {
+	return sort.Search(len(x.byCreation), func(i int) bool {
+		other := x.byCreation[i]
+		if !other.CreatedAt.Equal(operation.CreatedAt) {
+			return other.CreatedAt.After(operation.CreatedAt)
+		}
+		return other.ID >= operation.ID
+	})
+}
+
+func addTo(index map[string]map[string]*Operation, key string, operation *Operation) {
+	if key == "" {
+		return
+	}
+	if index[key] == nil {
+		index[key] = make(map[string]*Operation)
+	}
+	index[key][operation.ID] = operation
+}
+
+func removeFrom(index map[string]map[string]*Operation, key string, operation *Operation) {
+	delete(index[key], operation.ID)
+	if len(index[key]) == 0 {
+		delete(index, key)
+	}
+}
+
+// normalizeTags lowercases and trims tags and drops blanks and duplicates
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) == 0 {
+		return nil
+	}
+	return normalized
+}
+
+// sortedIndex keeps operations in ascending order of one sort field, ties
+// broken by ID. It remembers the value each operation was inserted with, so
+// an operation whose value changed is still found to be moved.
+type sortedIndex struct {
+	field  string
+	ops    []*Operation
+	values map[string]int64
+}
+
+func newSortedIndex(field string) *sortedIndex {
+	return &sortedIndex{field: field, values: make(map[string]int64)}
+}
+
+func (s *sortedIndex) insert(operation *Operation) {
+	value := sortValue(operation, s.field)
+	i := s.search(value, operation.ID)
+	s.ops = append(s.ops, nil)
+	copy(s.ops[i+1:], s.ops[i:])
+	s.ops[i] = operation
+	s.values[operation.ID] = value
+}
+
+func (s *sortedIndex) delete(operation *Operation) {
+	value, ok := s.values[operation.ID]
+	if !ok {
+		return
+	}
+	if i := s.search(value, operation.ID); i < len(s.ops) && s.ops[i] == operation {
+		s.ops = append(s.ops[:i], s.ops[i+1:]...)
+	}
+	delete(s.values, operation.ID)
+}
+
+// search returns where (value, id) sorts in ops
+func (s *sortedIndex) search(value int64, id string) int {
+	return sort.Search(len(s.ops), func(i int) bool {
+		other := s.values[s.ops[i].ID]
+		if other != value {
+			return other > value
+		}
+		return s.ops[i].ID >= id
+	})
+}
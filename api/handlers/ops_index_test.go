@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSortedIndex(t *testing.T) {
+	x := newOperationIndex()
+	start := time.Now()
+	priorities := []string{"low", "medium", "high", "critical"}
+	var all []*Operation
+	for i := 0; i < 40; i++ {
+		op := &Operation{
+			ID:        fmt.Sprintf("op_%02d", i),
+			Priority:  priorities[i%4],
+			CreatedAt: start.Add(time.Duration(i) * time.Second),
+			RiskScore: float64((i*7)%10) / 10,
+		}
+		if i%3 == 0 {
+			began, done := op.CreatedAt, op.CreatedAt.Add(time.Duration(i%5)*time.Minute)
+			op.StartedAt, op.CompletedAt = &began, &done
+		}
+		switch {
+		case i%2 == 0:
+			op.Tags = []string{"even"}
+		case i == 7:
+			op.Tags = []string{"rare"}
+		}
+		all = append(all, op)
+		x.add(op)
+	}
+
+	// check pages through a query and compares it with sorting every match
+	check := func(q operationQuery, want func(*Operation) bool) {
+		t.Helper()
+		var expected []*Operation
+		for _, op := range all {
+			if want(op) {
+				expected = append(expected, op)
+			}
+		}
+		sort.Slice(expected, func(i, j int) bool {
+			a, b := sortValue(expected[i], q.Sort), sortValue(expected[j], q.Sort)
+			if a != b {
+				return a < b != q.Desc
+			}
+			return expected[i].ID < expected[j].ID != q.Desc
+		})
+
+		var got []string
+		q.Limit, q.MaxRisk = 7, math.MaxFloat64
+		for {
+			page, total, next := x.query(q, false)
+			if total != len(expected) {
+				t.Fatalf("%s: total %d, want %d", q.Sort, total, len(expected))
+			}
+			for _, op := range page {
+				got = append(got, op.ID)
+			}
+			if next == "" {
+				break
+			}
+			q.After, _ = decodeCursor(next)
+		}
+		if len(got) != len(expected) {
+			t.Fatalf("%s: listed %d, want %d", q.Sort, len(got), len(expected))
+		}
+		for i, op := range expected {
+			if got[i] != op.ID {
+				t.Fatalf("%s desc=%v: listed %v", q.Sort, q.Desc, got)
+			}
+		}
+	}
+	every := func(*Operation) bool { return true }
+	tagged := func(tag string) func(*Operation) bool {
+		return func(op *Operation) bool { return hasTags(op, []string{tag}) }
+	}
+
+	for _, field := range []string{"created_at", "priority", "risk_score", "duration"} {
+		for _, desc := range []bool{false, true} {
+			check(operationQuery{Sort: field, Desc: desc}, every)
+			check(operationQuery{Sort: field, Desc: desc, Tags: []string{"even"}}, tagged("even"))
+			check(operationQuery{Sort: field, Desc: desc, Tags: []string{"rare"}}, tagged("rare"))
+		}
+	}
+
+	// Finished and restarted operations move; removed ones are gone
+	done := start.Add(time.Hour)
+	all[5].RiskScore, all[5].StartedAt, all[5].CompletedAt = 0.95, &start, &done
+	x.resort(all[5])
+	all[9].StartedAt, all[9].CompletedAt = nil, nil
+	x.resort(all[9])
+	x.remove(all[0])
+	all = all[1:]
+	for _, field := range []string{"risk_score", "duration"} {
+		check(operationQuery{Sort: field, Desc: true}, every)
+		if got := x.ordered(field); len(got) != len(all) {
+			t.Errorf("%s index holds %d operations, want %d", field, len(got), len(all))
+		}
+	}
+	if top := x.ordered("risk_score"); top[len(top)-1] != all[4] {
+		t.Errorf("highest risk is %s, want %s", top[len(top)-1].ID, all[4].ID)
+	}
+}
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"osint-api/cache"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 1000
+)
+
+// priorityRank orders priorities for sorting
+var priorityRank = map[string]int64{"low": 0, "medium": 1, "high": 2, "critical": 3}
+
+// operationQuery filters, sorts and pages ListOperations
+type operationQuery struct {
+	Status        string
+	Priority      string
+	Target        string
+	Owner         string
+	Case          string
+	Schedule      string
+	Note          string   // substring of any note, case-insensitive
+	Tags          []string // every tag must be present
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	MinRisk       float64
+	MaxRisk       float64
+
+	Sort  string // created_at, priority, risk_score or duration
+	Desc  bool
+	Limit int
+	After *listCursor
+}
+
+// listCursor is the position after the last operation of a page. It is
+// handed to clients as an opaque string and only valid for the same sort.
+type listCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value int64  `json:"v"`
+	ID    string `json:"id"`
+}
+
+func (c listCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	var c listCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return nil, errors.New("invalid cursor")
+	}
+	return &c, nil
+}
+
+// parseOperationQuery reads ListOperations query parameters
+func parseOperationQuery(values url.Values) (operationQuery, error) {
+	q := operationQuery{
+		Status:   values.Get("status"),
+		Priority: values.Get("priority"),
+		Target:   strings.TrimSpace(values.Get("target")),
+		Owner:    values.Get("owner"),
+		Case:     values.Get("case"),
+		Schedule: values.Get("schedule"),
+		Note:     strings.ToLower(strings.TrimSpace(values.Get("note"))),
+		Tags:     normalizeTags(values["tag"]),
+		MinRisk:  math.Inf(-1),
+		MaxRisk:  math.Inf(1),
+		Sort:     values.Get("sort"),
+		Desc:     values.Get("order") != "asc",
+		Limit:    defaultListLimit,
+	}
+
+	switch q.Sort {
+	case "":
+		q.Sort = "created_at"
+	case "created_at", "priority", "risk_score", "duration":
+	default:
+		return q, fmt.Errorf("sort must be created_at, priority, risk_score or duration")
+	}
+	if order := values.Get("order"); order != "" && order != "asc" && order != "desc" {
+		return q, fmt.Errorf("order must be asc or desc")
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return q, fmt.Errorf("limit must be a positive number")
+		}
+		if n > maxListLimit {
+			n = maxListLimit
+		}
+		q.Limit = n
+	}
+
+	var err error
+	if q.CreatedAfter, err = parseListTime(values.Get("created_after")); err != nil {
+		return q, fmt.Errorf("created_after: %w", err)
+	}
+	if q.CreatedBefore, err = parseListTime(values.Get("created_before")); err != nil {
+		return q, fmt.Errorf("created_before: %w", err)
+	}
+	if v := values.Get("min_risk"); v != "" {
+		if q.MinRisk, err = strconv.ParseFloat(v, 64); err != nil {
+			return q, fmt.Errorf("min_risk must be a number")
+		}
+	}
+	if v := values.Get("max_risk"); v != "" {
+		if q.MaxRisk, err = strconv.ParseFloat(v, 64); err != nil {
+			return q, fmt.Errorf("max_risk must be a number")
+		}
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		if q.After, err = decodeCursor(cursor); err != nil {
+			return q, err
+		}
+		if q.After.Sort != q.Sort || q.After.Desc != q.Desc {
+			return q, errors.New("cursor belongs to a different sort order")
+		}
+	}
+	return q, nil
+}
+
+// parseListTime accepts RFC 3339 timestamps or plain dates
+func parseListTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, errors.New("expected an RFC 3339 time or a YYYY-MM-DD date")
+	}
+	return t, nil
+}
+
+// sortValue returns the value an operation sorts by
+func sortValue(operation *Operation, field string) int64 {
+	switch field {
+	case "priority":
+		return priorityRank[operation.Priority]
+	case "risk_score":
+		return int64(math.Round(operation.RiskScore * 1e6))
+	case "duration":
+		if operation.StartedAt == nil || operation.CompletedAt == nil {
+			return 0
+		}
+		return int64(operation.CompletedAt.Sub(*operation.StartedAt))
+	}
+	return operation.CreatedAt.UnixNano()
+}
+
+// query returns one page of operations matching q, copied so they can be
+// encoded after the lock is released, along with the number of matches and
+// the cursor of the next page, empty on the last one. With hashedTargets the
+// target filter matches whole normalized targets only, by hash.
+//
+// Every page filters all candidates to count the matches; they come out of
+// the index already in sort order unless a small secondary index narrowed
+// them.
+func (x *operationIndex) query(q operationQuery, hashedTargets bool) (page []Operation, total int, next string) {
+	candidates, ordered := x.candidates(q, hashedTargets)
+
+	target, targetHash := cache.NormalizeTarget(q.Target), cache.HashTarget(q.Target)
+	matches := make([]*Operation, 0, len(candidates))
+	for _, op := range candidates {
+		switch {
+		case q.Status != "" && op.Status != q.Status,
+			q.Priority != "" && op.Priority != q.Priority,
+			q.Owner != "" && op.Owner != q.Owner && !containsString(op.Attached, q.Owner),
+			q.Case != "" && op.CaseID != q.Case,
+			q.Schedule != "" && op.ScheduleID != q.Schedule && !containsString(op.Schedules, q.Schedule),
+			q.Note != "" && !hasNote(op, q.Note),
+			!q.CreatedAfter.IsZero() && op.CreatedAt.Before(q.CreatedAfter),
+			!q.CreatedBefore.IsZero() && !op.CreatedAt.Before(q.CreatedBefore),
+			op.RiskScore < q.MinRisk || op.RiskScore > q.MaxRisk,
+			!hasTags(op, q.Tags),
+			target != "" && !hashedTargets && !strings.Contains(cache.NormalizeTarget(op.Target), target),
+			target != "" && hashedTargets && cache.HashTarget(op.Target) != targetHash:
+			continue
+		}
+		matches = append(matches, op)
+	}
+
+	// Ascending by (value, ID); reversed for descending order
+	less := func(a, b *Operation) bool {
+		va, vb := sortValue(a, q.Sort), sortValue(b, q.Sort)
+		if va != vb {
+			return va < vb
+		}
+		return a.ID < b.ID
+	}
+	if !ordered {
+		sort.Slice(matches, func(i, j int) bool { return less(matches[i], matches[j]) })
+	}
+	if q.Desc {
+		for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+			matches[i], matches[j] = matches[j], matches[i]
+		}
+	}
+
+	start := 0
+	if q.After != nil {
+		start = sort.Search(len(matches), func(i int) bool {
+			value := sortValue(matches[i], q.Sort)
+			if value == q.After.Value {
+				if q.Desc {
+					return matches[i].ID < q.After.ID
+				}
+				return matches[i].ID > q.After.ID
+			}
+			if q.Desc {
+				return value < q.After.Value
+			}
+			return value > q.After.Value
+		})
+	}
+
+	end := start + q.Limit
+	if end > len(matches) {
+		end = len(matches)
+	}
+	page = make([]Operation, 0, end-start)
+	for _, op := range matches[start:end] {
+		page = append(page, *op)
+	}
+	if end < len(matches) {
+		last := matches[end-1]
+		next = listCursor{Sort: q.Sort, Desc: q.Desc, Value: sortValue(last, q.Sort), ID: last.ID}.encode()
+	}
+	return page, len(matches), next
+}
+
+// narrowedSortRatio is how much smaller than the whole index a narrowed
+// candidate set must be for sorting it to beat walking the sorted index
+const narrowedSortRatio = 16
+
+// candidates narrows the search with the most selective index available. It
+// reports whether the result is already in ascending sort order.
+func (x *operationIndex) candidates(q operationQuery, hashedTargets bool) ([]*Operation, bool) {
+	var smallest map[string]*Operation
+	narrowed := false
+	consider := func(set map[string]*Operation) {
+		if !narrowed || len(set) < len(smallest) {
+			smallest, narrowed = set, true
+		}
+	}
+	if q.Owner != "" {
+		consider(x.byOwner[q.Owner])
+	}
+	if q.Case != "" {
+		consider(x.byCase[q.Case])
+	}
+	if q.Schedule != "" {
+		consider(x.bySchedule[q.Schedule])
+	}
+	for _, tag := range q.Tags {
+		consider(x.byTag[tag])
+	}
+	if hashedTargets && q.Target != "" {
+		consider(x.byTarget[cache.HashTarget(q.Target)])
+	}
+
+	// A small set is cheaper to sort than walking the whole sorted index
+	if narrowed && len(smallest) < len(x.byCreation)/narrowedSortRatio {
+		candidates := make([]*Operation, 0, len(smallest))
+		for _, op := range smallest {
+			candidates = append(candidates, op)
+		}
+		return candidates, false
+	}
+	if narrowed {
+		candidates := make([]*Operation, 0, len(smallest))
+		for _, op := range x.ordered(q.Sort) {
+			if smallest[op.ID] == op {
+				candidates = append(candidates, op)
+			}
+		}
+		return candidates, true
+	}
+	if q.Sort != "created_at" {
+		return x.ordered(q.Sort), true
+	}
+
+	// Creation order: binary search the date range
+	from, to := 0, len(x.byCreation)
+	if !q.CreatedAfter.IsZero() {
+		from = sort.Search(len(x.byCreation), func(i int) bool { return !x.byCreation[i].CreatedAt.Before(q.CreatedAfter) })
+	}
+	if !q.CreatedBefore.IsZero() {
+		to = sort.Search(len(x.byCreation), func(i int) bool { return !x.byCreation[i].CreatedAt.Before(q.CreatedBefore) })
+	}
+	if from > to {
+		from = to
+	}
+	candidates := append([]*Operation(nil), x.byCreation[from:to]...)
+	return candidates, true
+}
+
+func hasNote(operation *Operation, text string) bool {
+	for _, note := range operation.Notes {
+		if strings.Contains(strings.ToLower(note.Text), text) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTags(operation *Operation, tags []string) bool {
+	for _, tag := range tags {
+		if !containsString(operation.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	mrand "math/rand"
+	"net/http"
+	"time"
+
+	"osint-api/handlers/middleware"
+	"osint-api/metrics"
+	"osint-api/orchestra"
+
+	"github.com/gorilla/mux"
+)
+
+// What started an attempt
+const (
+	triggerInitial = "initial"
+	triggerRetry   = "retry"
+	triggerManual  = "manual"
+)
+
+// Attempt records one run of an operation
+type Attempt struct {
+	Number     int       `json:"number"`
+	Trigger    string    `json:"trigger"` // initial, retry or manual
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Error      string    `json:"error,omitempty"`
+	Temporary  bool      `json:"temporary,omitempty"` // the error was worth retrying
+}
+
+// RetryPolicy controls automatic retries of operations that failed for a
+// temporary reason: an orchestra timeout, a transport error or an open
+// circuit. Error replies from orchestra and local module failures are final.
+type RetryPolicy struct {
+	MaxAttempts int           // attempts per run, including the first; 1 disables retries
+	Backoff     time.Duration // before the first retry, doubled for each further one
+	MaxBackoff  time.Duration
+}
+
+// delay returns the backoff before retry n, counted from 1, with jitter so
+// operations failed by the same outage do not all retry at once
+func (p RetryPolicy) delay(n int) time.Duration {
+	// Doubling stops at MaxBackoff, before it could overflow
+	d := p.Backoff
+	for i := 1; i < n && d < p.MaxBackoff; i++ {
+		if d > p.MaxBackoff/2 {
+			d = p.MaxBackoff
+			break
+		}
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d/2 + time.Duration(mrand.Int63n(int64(d/2)+1))
+}
+
+// recordAttempt appends the attempt that just finished to the operation's
+// history. Callers must hold h.mu.
+func (h *OpsHandler) recordAttempt(operation *Operation, started time.Time, err error) {
+	trigger := operation.trigger
+	if trigger == "" {
+		trigger = triggerInitial
+		if len(operation.Attempts) > 0 {
+			trigger = triggerRetry // resumed after a restart
+		}
+	}
+
+	attempt := Attempt{
+		Number:     len(operation.Attempts) + 1,
+		Trigger:    trigger,
+		StartedAt:  started,
+		FinishedAt: time.Now(),
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+		attempt.Temporary = orchestra.Temporary(err)
+	}
+	operation.Attempts = append(operation.Attempts, attempt)
+	operation.trigger = ""
+}
+
+// runAttempts counts the attempts since the operation was submitted or last
+// retried by hand, which share one MaxAttempts budget
+func (o *Operation) runAttempts() int {
+	n := 0
+	for i := len(o.Attempts) - 1; i >= 0; i-- {
+		n++
+		if o.Attempts[i].Trigger != triggerRetry {
+			break
+		}
+	}
+	return n
+}
+
+// scheduleRetry puts an operation whose attempt failed with err back in the
+// queue, unless err is final or the attempts are used up. It reports whether
+// a retry was scheduled. Callers must hold h.mu.
+func (h *OpsHandler) scheduleRetry(ctx context.Context, operation *Operation, err error) bool {
+	attempts := operation.runAttempts()
+	if !orchestra.Temporary(err) || attempts >= h.Retry.MaxAttempts {
+		return false
+	}
+
+	delay := h.Retry.delay(attempts)
+	var open *orchestra.CircuitOpenError
+	if errors.As(err, &open) && open.RetryAfter > delay {
+		delay = open.RetryAfter
+	}
+	retryAt := time.Now().Add(delay)
+
+	operation.Status = "pending"
+	operation.Progress = 0
+	operation.StartedAt = nil
+	operation.NextRetryAt = &retryAt
+	operation.trigger = triggerRetry
+	h.persist(operation)
+	metrics.OperationRetries.WithLabelValues(triggerRetry).Inc()
+	log.Printf("🔄 Retrying operation %s in %s (attempt %d of %d failed: %v)",
+		operation.ID, delay.Round(time.Millisecond), attempts, h.Retry.MaxAttempts, err)
+
+	// Cancelling the operation, or re-queueing it on shutdown, stops the wait
+	go func() {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+			h.processOperation(ctx, operation)
+		}
+	}()
+	return true
+}
+
+// RetryOperation starts a failed or cancelled operation again with a fresh
+// attempt budget. Retrying an operation that is already queued or running
+// is a no-op, so clients can safely repeat the request.
+func (h *OpsHandler) RetryOperation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	operationID := mux.Vars(r)["id"]
+
+	h.mu.Lock()
+	operation, exists := h.operations[operationID]
+	if !exists {
+		h.mu.Unlock()
+		h.sendError(w, "Operation not found", http.StatusNotFound)
+		return
+	}
+	middleware.SetLogTarget(r.Context(), operation.Target)
+
+	switch operation.Status {
+	case "completed":
+		h.mu.Unlock()
+		h.sendError(w, "Operation already completed", http.StatusConflict)
+		return
+	case "failed", "cancelled":
+		if err := h.Unavailable(operation.Modules); err != nil {
+			h.mu.Unlock()
+			h.sendError(w, err.Error(), errorStatus(w, err))
+			return
+		}
+		h.restart(operation)
+		metrics.OperationRetries.WithLabelValues(triggerManual).Inc()
+	}
+	response := map[string]interface{}{
+		"operation_id": operation.ID,
+		"status":       operation.Status,
+		"attempts":     len(operation.Attempts),
+		"message":      "Operation queued for retry",
+		"timestamp":    time.Now(),
+	}
+	h.mu.Unlock()
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+// restart clears the outcome of a finished operation and queues it again.
+// Callers must hold h.mu.
+func (h *OpsHandler) restart(operation *Operation) {
+	operation.Status = "pending"
+	operation.Progress = 0
+	operation.StartedAt = nil
+	operation.CompletedAt = nil
+	operation.NextRetryAt = nil
+	operation.Duration = ""
+	operation.Error = ""
+	operation.failure = nil
+	operation.trigger = triggerManual
+	h.index.resort(operation)
+	h.persist(operation)
+	h.start(operation)
+}
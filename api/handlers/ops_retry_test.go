@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3, Backoff: 2 * time.Second, MaxBackoff: time.Minute}
+	tests := []struct {
+		n    int
+		base time.Duration // delay before jitter
+	}{
+		{1, 2 * time.Second},
+		{2, 4 * time.Second},
+		{5, 32 * time.Second},
+		{6, time.Minute},
+		{40, time.Minute},
+		{64, time.Minute},
+		{math.MaxInt32, time.Minute},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if d := p.delay(tt.n); d < tt.base/2 || d > tt.base {
+				t.Fatalf("delay(%d) = %v, want between %v and %v", tt.n, d, tt.base/2, tt.base)
+			}
+		}
+	}
+
+	// A large backoff cannot overflow into a negative delay
+	p = RetryPolicy{Backoff: time.Hour, MaxBackoff: math.MaxInt64}
+	if d := p.delay(60); d < math.MaxInt64/4 {
+		t.Errorf("delay(60) with an hour's backoff = %v", d)
+	}
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"osint-api/cache"
+	"osint-api/store"
+)
+
+// errRequeued is returned to callers waiting on an operation that was put
+// back in the queue because the API is shutting down
+var errRequeued = errors.New("API is shutting down; operation re-queued and will resume on restart")
+
+const shutdownCancelMessage = "API shut down before the operation completed"
+
+// operationRecord is the persisted form of an Operation
+type operationRecord struct {
+	Operation
+	ScanData map[string]interface{} `json:"scan_data,omitempty"`
+}
+
+// persist queues operation for writing to the store, if any. The record is
+// encoded here; the file is written in the background. Callers must hold
+// h.mu.
+func (h *OpsHandler) persist(operation *Operation) {
+	if h.storeWriter() == nil {
+		return
+	}
+	record := operationRecord{Operation: *operation, ScanData: operation.scanData}
+	if err := h.writer.Put(operation.ID, record); err != nil {
+		log.Printf("⚠️ Failed to persist operation %s: %v", operation.ID, err)
+	}
+}
+
+// unpersist queues the removal of an operation from the store, if any.
+// Callers must hold h.mu.
+func (h *OpsHandler) unpersist(id string) {
+	if h.storeWriter() == nil {
+		return
+	}
+	h.writer.Delete(id)
+}
+
+// storeWriter returns the background writer for Store, starting it on first
+// use, or nil without a store. Callers must hold h.mu.
+func (h *OpsHandler) storeWriter() *store.Writer {
+	if h.Store == nil {
+		return nil
+	}
+	if h.writer == nil {
+		h.writer = store.NewWriter(h.Store)
+	}
+	return h.writer
+}
+
+// FlushStore waits until every queued operation change is on disk
+func (h *OpsHandler) FlushStore() {
+	h.mu.RLock()
+	writer := h.writer
+	h.mu.RUnlock()
+
+	if writer != nil {
+		writer.Flush()
+	}
+}
+
+// Restore loads operations from the store. Finished operations are kept as
+// history; operations that were pending or processing when the API stopped
+// are re-queued. It returns the number of re-queued operations.
+func (h *OpsHandler) Restore() (int, error) {
+	if h.Store == nil {
+		return 0, nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	requeued := 0
+	err := h.Store.Each(func(id string, data []byte) error {
+		var record operationRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			log.Printf("⚠️ Skipping unreadable stored operation %s: %v", id, err)
+			return nil
+		}
+
+		operation := new(Operation)
+		*operation = record.Operation
+		operation.scanData = record.ScanData
+		operation.key = cache.Key(operation.Target, operation.Modules, operation.scanData)
+		h.operations[operation.ID] = operation
+		h.index.add(operation)
+
+		switch operation.Status {
+		case "pending", "processing":
+			operation.Status = "pending"
+			operation.Progress = 0
+			operation.StartedAt = nil
+			h.start(operation)
+			requeued++
+		default:
+			operation.cancel = func() {}
+			operation.done = make(chan struct{})
+			close(operation.done)
+		}
+		return nil
+	})
+	return requeued, err
+}
+
+// Shutdown lets running operations finish until ctx is done, then re-queues
+// the rest in the store so they resume on the next start; their waiters are
+// released with errRequeued. Without a store the rest are cancelled instead.
+// It returns the number of operations that did not finish. Stored changes
+// are flushed before it returns.
+func (h *OpsHandler) Shutdown(ctx context.Context) int {
+	defer h.FlushStore()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for h.inflightCount() > 0 {
+		select {
+		case <-ctx.Done():
+			return h.stopInflight()
+		case <-ticker.C:
+		}
+	}
+	return 0
+}
+
+func (h *OpsHandler) inflightCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	count := 0
+	for _, op := range h.operations {
+		if op.Status == "pending" || op.Status == "processing" {
+			count++
+		}
+	}
+	return count
+}
+
+func (h *OpsHandler) stopInflight() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stopped := 0
+	for _, operation := range h.operations {
+		if operation.Status != "pending" && operation.Status != "processing" {
+			continue
+		}
+
+		if h.Store == nil {
+			h.finishOperation(operation, "cancelled", shutdownCancelMessage)
+			stopped++
+			continue
+		}
+
+		operation.cancel()
+		operation.Status = "pending"
+		operation.Progress = 0
+		operation.StartedAt = nil
+		if h.inflight[operation.key] == operation {
+			delete(h.inflight, operation.key)
+		}
+		h.persist(operation)
+		close(operation.done)
+		stopped++
+	}
+	return stopped
+}
@@ -0,0 +1,505 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"osint-api/handlers/middleware"
+	"osint-api/schedule"
+	"osint-api/store"
+
+	"github.com/gorilla/mux"
+)
+
+// Schedule is an operation template run on a cron expression or once at a
+// set time. Every run submits an operation carrying the schedule's ID.
+type Schedule struct {
+	ID              string     `json:"id"`
+	Name            string     `json:"name,omitempty"`
+	Target          string     `json:"target"`
+	Modules         []string   `json:"modules,omitempty"`
+	Priority        string     `json:"priority"`
+	Tags            []string   `json:"tags,omitempty"`
+	Cron            string     `json:"cron,omitempty"`     // recurring runs
+	Timezone        string     `json:"timezone,omitempty"` // IANA zone Cron is read in; UTC when empty
+	RunAt           *time.Time `json:"run_at,omitempty"`   // a single run
+	Status          string     `json:"status"`             // active, paused, completed
+	NextRunAt       *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt       *time.Time `json:"last_run_at,omitempty"`
+	LastOperationID string     `json:"last_operation_id,omitempty"`
+	Runs            int        `json:"runs"`
+	Owner           string     `json:"owner,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	cron *schedule.Cron // parsed Cron
+	loc  *time.Location // parsed Timezone
+}
+
+// scheduleUpdate is the body of create and update requests. Omitted fields
+// are left unchanged on update; setting cron clears run_at and vice versa.
+type scheduleUpdate struct {
+	Name     *string    `json:"name"`
+	Target   *string    `json:"target"`
+	Modules  *[]string  `json:"modules"`
+	Priority *string    `json:"priority"`
+	Tags     *[]string  `json:"tags"`
+	Cron     *string    `json:"cron"`
+	Timezone *string    `json:"timezone"`
+	RunAt    *time.Time `json:"run_at"`
+}
+
+// ScheduleHandler manages schedules and starts their runs.
+// Lock order: h.mu before Ops.mu.
+type ScheduleHandler struct {
+	Ops       *OpsHandler
+	Store     *store.Collection // optional; nil keeps schedules in memory only
+	schedules map[string]*Schedule
+	mu        sync.RWMutex
+}
+
+// NewScheduleHandler creates a schedule handler submitting runs to ops
+func NewScheduleHandler(ops *OpsHandler) *ScheduleHandler {
+	return &ScheduleHandler{Ops: ops, schedules: make(map[string]*Schedule)}
+}
+
+// Restore loads schedules from the store. Runs missed while the API was
+// down are not replayed: a due schedule runs once, on the next check.
+func (h *ScheduleHandler) Restore() (int, error) {
+	if h.Store == nil {
+		return 0, nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	err := h.Store.Each(func(id string, data []byte) error {
+		var s Schedule
+		if err := json.Unmarshal(data, &s); err != nil {
+			log.Printf("⚠️ Skipping unreadable stored schedule %s: %v", id, err)
+			return nil
+		}
+		if err := s.parse(); err != nil {
+			log.Printf("⚠️ Skipping invalid stored schedule %s: %v", id, err)
+			return nil
+		}
+		h.schedules[s.ID] = &s
+		return nil
+	})
+	return len(h.schedules), err
+}
+
+// Watch starts due runs every interval until ctx is done
+func (h *ScheduleHandler) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.RunDue(time.Now())
+		}
+	}
+}
+
+// RunDue submits an operation for every active schedule due at now and
+// moves it to its next run. It returns the number of runs started.
+func (h *ScheduleHandler) RunDue(now time.Time) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	started := 0
+	for _, s := range h.schedules {
+		if s.Status != "active" || s.NextRunAt == nil || s.NextRunAt.After(now) {
+			continue
+		}
+
+		operation, attached := h.Ops.Submit(OperationSpec{
+			Target:     s.Target,
+			Priority:   s.Priority,
+			Modules:    s.Modules,
+			Tags:       s.Tags,
+			Owner:      s.Owner,
+			ScheduleID: s.ID,
+		})
+		if attached {
+			log.Printf("🔄 Schedule %s attached to running operation %s", s.ID, operation.ID)
+		} else {
+			log.Printf("🔄 Schedule %s started operation %s", s.ID, operation.ID)
+		}
+
+		ranAt := now
+		s.LastRunAt = &ranAt
+		s.LastOperationID = operation.ID
+		s.Runs++
+		s.advance(now)
+		h.persist(s)
+		started++
+	}
+	return started
+}
+
+// ListSchedules returns schedules, newest first, optionally filtered by
+// status and owner
+func (h *ScheduleHandler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	statusFilter := r.URL.Query().Get("status")
+	ownerFilter := r.URL.Query().Get("owner")
+
+	h.mu.RLock()
+	result := make([]Schedule, 0)
+	for _, s := range h.schedules {
+		if statusFilter != "" && s.Status != statusFilter {
+			continue
+		}
+		if ownerFilter != "" && s.Owner != ownerFilter {
+			continue
+		}
+		result = append(result, *s)
+	}
+	h.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.After(result[j].CreatedAt) })
+
+	response := map[string]interface{}{
+		"schedules": result,
+		"total":     len(result),
+		"timestamp": time.Now(),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetSchedule returns a schedule with the number of operations it started.
+// The operations themselves are listed by GET /operations?schedule=.
+func (h *ScheduleHandler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	h.mu.RLock()
+	s, ok := h.schedules[mux.Vars(r)["id"]]
+	var snapshot Schedule
+	if ok {
+		snapshot = *s
+	}
+	h.mu.RUnlock()
+
+	if !ok {
+		h.sendError(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"schedule":        snapshot,
+		"operation_count": h.Ops.countBySchedule(snapshot.ID),
+		"timestamp":       time.Now(),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// CreateSchedule adds a schedule. It needs a target and either cron or
+// run_at.
+func (h *ScheduleHandler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var update scheduleUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		h.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if update.Target == nil {
+		h.sendError(w, "Target is required", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	s := &Schedule{
+		ID:        fmt.Sprintf("sched_%d_%s", now.Unix(), randomString(6)),
+		Priority:  "medium",
+		Status:    "active",
+		Owner:     ownerFromContext(r.Context()),
+		CreatedAt: now,
+	}
+	if err := s.apply(update, now); err != nil {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	middleware.SetLogTarget(r.Context(), s.Target)
+
+	h.mu.Lock()
+	h.schedules[s.ID] = s
+	h.persist(s)
+	snapshot := *s
+	h.mu.Unlock()
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// UpdateSchedule changes the fields present in the request body. Changing
+// when a completed schedule runs makes it active again.
+func (h *ScheduleHandler) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var update scheduleUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		h.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	s, ok := h.schedules[mux.Vars(r)["id"]]
+	if !ok {
+		h.mu.Unlock()
+		h.sendError(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	updated := *s
+	if err := updated.apply(update, time.Now()); err != nil {
+		h.mu.Unlock()
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	*s = updated
+	h.persist(s)
+	h.mu.Unlock()
+
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteSchedule removes a schedule. Operations it started are kept.
+func (h *ScheduleHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+
+	h.mu.Lock()
+	_, ok := h.schedules[id]
+	if ok {
+		delete(h.schedules, id)
+		h.unpersist(id)
+	}
+	h.mu.Unlock()
+
+	if !ok {
+		h.sendError(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"id":         id,
+		"status":     "deleted",
+		"deleted_at": time.Now(),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// PauseSchedule stops a schedule from running until it is resumed
+func (h *ScheduleHandler) PauseSchedule(w http.ResponseWriter, r *http.Request) {
+	h.setPaused(w, r, true)
+}
+
+// ResumeSchedule restarts a paused schedule from its next run after now;
+// runs missed while paused are skipped, except a single run still due
+func (h *ScheduleHandler) ResumeSchedule(w http.ResponseWriter, r *http.Request) {
+	h.setPaused(w, r, false)
+}
+
+func (h *ScheduleHandler) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	w.Header().Set("Content-Type", "application/json")
+
+	h.mu.Lock()
+	s, ok := h.schedules[mux.Vars(r)["id"]]
+	if !ok {
+		h.mu.Unlock()
+		h.sendError(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	if s.Status == "completed" {
+		h.mu.Unlock()
+		h.sendError(w, "Schedule has completed; set a new cron or run_at to reuse it", http.StatusConflict)
+		return
+	}
+
+	switch {
+	case paused && s.Status == "active":
+		s.Status = "paused"
+		s.NextRunAt = nil
+	case !paused && s.Status == "paused":
+		s.Status = "active"
+		s.plan(time.Now())
+	}
+	s.UpdatedAt = time.Now()
+	h.persist(s)
+	snapshot := *s
+	h.mu.Unlock()
+
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// apply validates and copies the fields present in update and plans the
+// next run
+func (s *Schedule) apply(update scheduleUpdate, now time.Time) error {
+	if update.Cron != nil && update.RunAt != nil {
+		return errors.New("set either cron or run_at, not both")
+	}
+
+	if update.Name != nil {
+		s.Name = strings.TrimSpace(*update.Name)
+	}
+	if update.Target != nil {
+		target := strings.TrimSpace(*update.Target)
+		if target == "" {
+			return errors.New("target must not be empty")
+		}
+		s.Target = target
+	}
+	if update.Modules != nil {
+		if err := validateModules(*update.Modules); err != nil {
+			return err
+		}
+		s.Modules = *update.Modules
+	}
+	if update.Priority != nil {
+		if _, ok := priorityRank[*update.Priority]; !ok {
+			return errors.New("priority must be low, medium, high or critical")
+		}
+		s.Priority = *update.Priority
+	}
+	if update.Tags != nil {
+		s.Tags = normalizeTags(*update.Tags)
+	}
+	if update.Timezone != nil {
+		s.Timezone = strings.TrimSpace(*update.Timezone)
+	}
+
+	timing := update.Cron != nil || update.RunAt != nil || update.Timezone != nil
+	if update.Cron != nil {
+		s.Cron, s.RunAt = strings.TrimSpace(*update.Cron), nil
+	}
+	if update.RunAt != nil {
+		runAt := *update.RunAt
+		s.Cron, s.RunAt = "", &runAt
+	}
+	if s.Cron == "" && s.RunAt == nil {
+		return errors.New("cron or run_at is required")
+	}
+	if err := s.parse(); err != nil {
+		return err
+	}
+
+	if timing {
+		if s.Status == "completed" {
+			s.Status = "active"
+		}
+		if s.Status == "active" {
+			s.plan(now)
+		}
+	}
+	s.UpdatedAt = now
+	return nil
+}
+
+// parse checks Cron and Timezone and keeps their parsed forms
+func (s *Schedule) parse() error {
+	s.cron, s.loc = nil, time.UTC
+	if s.Timezone != "" {
+		loc, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return fmt.Errorf("unknown timezone %q", s.Timezone)
+		}
+		s.loc = loc
+	}
+	if s.Cron != "" {
+		c, err := schedule.Parse(s.Cron)
+		if err != nil {
+			return fmt.Errorf("invalid cron: %v", err)
+		}
+		s.cron = c
+	}
+	return nil
+}
+
+// plan sets NextRunAt to the next cron match after now, or to run_at even
+// when it has passed, so a late single run still happens
+func (s *Schedule) plan(now time.Time) {
+	if s.cron == nil {
+		next := *s.RunAt
+		s.NextRunAt = &next
+		return
+	}
+	s.setNext(s.cron.Next(now.In(s.loc)))
+}
+
+// advance moves the schedule past a run made at now. A single run, or a
+// cron expression that never matches again, completes the schedule.
+func (s *Schedule) advance(now time.Time) {
+	if s.cron == nil {
+		s.setNext(time.Time{})
+		return
+	}
+	s.setNext(s.cron.Next(now.In(s.loc)))
+}
+
+func (s *Schedule) setNext(next time.Time) {
+	if next.IsZero() {
+		s.Status = "completed"
+		s.NextRunAt = nil
+		return
+	}
+	s.NextRunAt = &next
+}
+
+// countBySchedule returns the number of operations started by scheduleID,
+// including running ones its runs attached to
+func (h *OpsHandler) countBySchedule(scheduleID string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.index.bySchedule[scheduleID])
+}
+
+// persist writes a schedule to the store, if any. Callers must hold h.mu.
+func (h *ScheduleHandler) persist(s *Schedule) {
+	if h.Store == nil {
+		return
+	}
+	if err := h.Store.Put(s.ID, s); err != nil {
+		log.Printf("⚠️ Failed to persist schedule %s: %v", s.ID, err)
+	}
+}
+
+// unpersist removes a schedule from the store, if any. Callers must hold h.mu.
+func (h *ScheduleHandler) unpersist(id string) {
+	if h.Store == nil {
+		return
+	}
+	if err := h.Store.Delete(id); err != nil {
+		log.Printf("⚠️ Failed to delete stored schedule %s: %v", id, err)
+	}
+}
+
+// sendError sends a standardized error response
+func (h *ScheduleHandler) sendError(w http.ResponseWriter, message string, statusCode int) {
+	errorResponse := map[string]interface{}{
+		"error":       message,
+		"status":      "error",
+		"status_code": statusCode,
+		"request_id":  w.Header().Get(middleware.RequestIDHeader),
+		"timestamp":   time.Now(),
+	}
+
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(errorResponse)
+}
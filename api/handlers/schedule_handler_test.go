@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// stubModule is a local module, so operations never reach orchestra
+type stubModule struct{}
+
+func (stubModule) Run(ctx context.Context, target string) (interface{}, error) {
+	return map[string]interface{}{"target": target}, nil
+}
+
+func newTestScheduleHandler() *ScheduleHandler {
+	ops := NewOpsHandler(nil)
+	ops.Modules["stub"] = stubModule{}
+	return NewScheduleHandler(ops)
+}
+
+// serve calls a schedule endpoint with id as the route's {id}
+func serve(handler http.HandlerFunc, method, id string, body interface{}) (int, Schedule) {
+	var payload bytes.Buffer
+	json.NewEncoder(&payload).Encode(body)
+	req := mux.SetURLVars(httptest.NewRequest(method, "/api/v1/schedules/"+id, &payload), map[string]string{"id": id})
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	var s Schedule
+	json.NewDecoder(rec.Body).Decode(&s)
+	return rec.Code, s
+}
+
+func TestScheduleRunDue(t *testing.T) {
+	h := newTestScheduleHandler()
+
+	status, recurring := serve(h.CreateSchedule, "POST", "", map[string]interface{}{
+		"target": "trent", "modules": []string{"stub"}, "cron": "*/5 * * * *", "tags": []string{"Nightly"},
+	})
+	if status != http.StatusCreated || recurring.NextRunAt == nil {
+		t.Fatalf("create: status %d, schedule %+v", status, recurring)
+	}
+	runAt := time.Now().Add(time.Hour)
+	_, once := serve(h.CreateSchedule, "POST", "", map[string]interface{}{
+		"target": "uma", "modules": []string{"stub"}, "run_at": runAt,
+	})
+
+	due := *recurring.NextRunAt
+	if n := h.RunDue(due.Add(-time.Second)); n != 0 {
+		t.Fatalf("started %d runs before anything was due", n)
+	}
+	if n := h.RunDue(due); n != 1 {
+		t.Fatalf("started %d runs, want 1", n)
+	}
+	if n := h.RunDue(due); n != 0 {
+		t.Errorf("started %d runs again at the same time", n)
+	}
+
+	h.mu.RLock()
+	s := *h.schedules[recurring.ID]
+	h.mu.RUnlock()
+	if s.Runs != 1 || s.LastOperationID == "" || !s.LastRunAt.Equal(due) || !s.NextRunAt.Equal(due.Add(5*time.Minute)) {
+		t.Errorf("after a run: %+v", s)
+	}
+	h.Ops.mu.RLock()
+	operation := h.Ops.operations[s.LastOperationID]
+	h.Ops.mu.RUnlock()
+	if operation == nil || operation.ScheduleID != recurring.ID || operation.Target != "trent" || operation.Tags[0] != "nightly" {
+		t.Errorf("scheduled operation %+v", operation)
+	}
+
+	// A single run completes the schedule
+	if n := h.RunDue(runAt); n != 2 {
+		t.Fatalf("started %d runs at run_at, want 2", n)
+	}
+	h.mu.RLock()
+	s = *h.schedules[once.ID]
+	h.mu.RUnlock()
+	if s.Status != "completed" || s.NextRunAt != nil || s.Runs != 1 {
+		t.Errorf("single run schedule %+v", s)
+	}
+	if n := h.Ops.countBySchedule(once.ID); n != 1 {
+		t.Errorf("%d operations for the single run, want 1", n)
+	}
+}
+
+func TestSchedulePauseResume(t *testing.T) {
+	h := newTestScheduleHandler()
+	_, created := serve(h.CreateSchedule, "POST", "", map[string]interface{}{
+		"target": "victor", "modules": []string{"stub"}, "cron": "0 0 1 1 *",
+	})
+
+	status, paused := serve(h.PauseSchedule, "POST", created.ID, nil)
+	if status != http.StatusOK || paused.Status != "paused" || paused.NextRunAt != nil {
+		t.Fatalf("pause: status %d, schedule %+v", status, paused)
+	}
+	if n := h.RunDue(time.Now().Add(400 * 24 * time.Hour)); n != 0 {
+		t.Errorf("paused schedule started %d runs", n)
+	}
+
+	// Runs missed while paused are skipped
+	status, resumed := serve(h.ResumeSchedule, "POST", created.ID, nil)
+	if status != http.StatusOK || resumed.Status != "active" || resumed.NextRunAt == nil || !resumed.NextRunAt.After(time.Now()) {
+		t.Fatalf("resume: status %d, schedule %+v", status, resumed)
+	}
+	if _, again := serve(h.ResumeSchedule, "POST", created.ID, nil); !again.NextRunAt.Equal(*resumed.NextRunAt) {
+		t.Errorf("resuming an active schedule moved its next run to %v", again.NextRunAt)
+	}
+
+	// A single run keeps its run_at, even once it has passed
+	runAt := time.Now().Add(-time.Minute)
+	_, once := serve(h.CreateSchedule, "POST", "", map[string]interface{}{
+		"target": "wendy", "modules": []string{"stub"}, "run_at": runAt,
+	})
+	serve(h.PauseSchedule, "POST", once.ID, nil)
+	if _, resumed := serve(h.ResumeSchedule, "POST", once.ID, nil); resumed.NextRunAt == nil || !resumed.NextRunAt.Equal(runAt) {
+		t.Fatalf("resumed single run: next run %v, want %v", resumed.NextRunAt, runAt)
+	}
+	if n := h.RunDue(time.Now()); n != 1 {
+		t.Fatalf("resumed single run: started %d runs, want 1", n)
+	}
+
+	if status, _ := serve(h.PauseSchedule, "POST", once.ID, nil); status != http.StatusConflict {
+		t.Errorf("pausing a completed schedule: status %d, want 409", status)
+	}
+	if status, _ := serve(h.ResumeSchedule, "POST", "sched_missing", nil); status != http.StatusNotFound {
+		t.Errorf("resuming an unknown schedule: status %d, want 404", status)
+	}
+}
+
+func TestScheduleModules(t *testing.T) {
+	h := newTestScheduleHandler()
+	for _, modules := range [][]string{{""}, {"Email"}, {"email", "email"}, {"dns; rm"}} {
+		if status, _ := serve(h.CreateSchedule, "POST", "", map[string]interface{}{
+			"target": "xena", "modules": modules, "cron": "@daily",
+		}); status != http.StatusBadRequest {
+			t.Errorf("modules %q: status %d, want 400", modules, status)
+		}
+	}
+
+	_, created := serve(h.CreateSchedule, "POST", "", map[string]interface{}{
+		"target": "xena", "modules": []string{"stub"}, "cron": "@daily",
+	})
+	if status, _ := serve(h.UpdateSchedule, "PATCH", created.ID, map[string]interface{}{"modules": []string{"stub", "bad module"}}); status != http.StatusBadRequest {
+		t.Errorf("update with a bad module: status %d, want 400", status)
+	}
+}
+
+func TestScheduleRunAttached(t *testing.T) {
+	h := newTestScheduleHandler()
+	h.Ops.Modules["block"] = blockingModule{}
+	running, _ := h.Ops.Submit(OperationSpec{Target: "yusuf", Modules: []string{"block"}})
+
+	_, s := serve(h.CreateSchedule, "POST", "", map[string]interface{}{
+		"target": "yusuf", "modules": []string{"block"}, "run_at": time.Now(),
+	})
+	if n := h.RunDue(time.Now()); n != 1 {
+		t.Fatalf("started %d runs, want 1", n)
+	}
+
+	// The run joined the operation in flight, which now links to the schedule
+	h.mu.RLock()
+	last := h.schedules[s.ID].LastOperationID
+	h.mu.RUnlock()
+	if last != running.ID {
+		t.Fatalf("schedule ran %s, want the running %s", last, running.ID)
+	}
+	if n := h.Ops.countBySchedule(s.ID); n != 1 {
+		t.Errorf("%d operations for the schedule, want 1", n)
+	}
+	h.Ops.mu.RLock()
+	page, _, _ := h.Ops.index.query(operationQuery{Schedule: s.ID, Sort: "created_at", Limit: 10}, false)
+	h.Ops.mu.RUnlock()
+	if len(page) != 1 || page[0].ID != running.ID {
+		t.Errorf("?schedule= lists %+v", page)
+	}
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"osint-api/handlers/middleware"
+	"osint-api/scanner"
+	"osint-api/sites"
+
+	"github.com/gorilla/mux"
+)
+
+// SitesHandler exposes administration of username scanner site definitions
+type SitesHandler struct {
+	Registry *sites.Registry
+}
+
+// ListSites returns site definitions, optionally filtered by category,
+// nsfw and disabled flags
+func (h *SitesHandler) ListSites(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	categoryFilter := r.URL.Query().Get("category")
+	nsfwFilter, nsfwErr := parseOptionalBool(r.URL.Query().Get("nsfw"))
+	disabledFilter, disabledErr := parseOptionalBool(r.URL.Query().Get("disabled"))
+	if nsfwErr != nil || disabledErr != nil {
+		h.sendError(w, "nsfw and disabled filters must be true or false", http.StatusBadRequest)
+		return
+	}
+
+	result := make([]scanner.Site, 0)
+	for _, site := range h.Registry.All() {
+		if categoryFilter != "" && site.Category != categoryFilter {
+			continue
+		}
+		if nsfwFilter != nil && site.NSFW != *nsfwFilter {
+			continue
+		}
+		if disabledFilter != nil && site.Disabled != *disabledFilter {
+			continue
+		}
+		result = append(result, site)
+	}
+
+	response := map[string]interface{}{
+		"sites":     result,
+		"total":     len(result),
+		"timestamp": time.Now(),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetSite returns a single site definition
+func (h *SitesHandler) GetSite(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	site, ok := h.Registry.Get(mux.Vars(r)["name"])
+	if !ok {
+		h.sendError(w, "Site not found", http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(site)
+}
+
+// CreateSite adds a site definition
+func (h *SitesHandler) CreateSite(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var site scanner.Site
+	if err := json.NewDecoder(r.Body).Decode(&site); err != nil {
+		h.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Registry.Create(site); err != nil {
+		h.sendRegistryError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(site)
+}
+
+// UpdateSite replaces a site definition
+func (h *SitesHandler) UpdateSite(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var site scanner.Site
+	if err := json.NewDecoder(r.Body).Decode(&site); err != nil {
+		h.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Registry.Update(mux.Vars(r)["name"], site); err != nil {
+		h.sendRegistryError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(site)
+}
+
+// DeleteSite removes a site definition
+func (h *SitesHandler) DeleteSite(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	name := mux.Vars(r)["name"]
+	if err := h.Registry.Delete(name); err != nil {
+		h.sendRegistryError(w, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"name":       name,
+		"status":     "deleted",
+		"deleted_at": time.Now(),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// sendRegistryError maps registry errors to HTTP responses
+func (h *SitesHandler) sendRegistryError(w http.ResponseWriter, err error) {
+	var validationErr *sites.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		errorResponse := map[string]interface{}{
+			"error":       "Invalid site definition",
+			"problems":    validationErr.Problems,
+			"status":      "error",
+			"status_code": http.StatusBadRequest,
+			"request_id":  w.Header().Get(middleware.RequestIDHeader),
+			"timestamp":   time.Now(),
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorResponse)
+	case errors.Is(err, sites.ErrNotFound):
+		h.sendError(w, "Site not found", http.StatusNotFound)
+	case errors.Is(err, sites.ErrExists):
+		h.sendError(w, "Site already exists", http.StatusConflict)
+	default:
+		h.sendError(w, "Failed to save site definitions", http.StatusInternalServerError)
+	}
+}
+
+// sendError sends a standardized error response
+func (h *SitesHandler) sendError(w http.ResponseWriter, message string, statusCode int) {
+	errorResponse := map[string]interface{}{
+		"error":       message,
+		"status":      "error",
+		"status_code": statusCode,
+		"request_id":  w.Header().Get(middleware.RequestIDHeader),
+		"timestamp":   time.Now(),
+	}
+
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(errorResponse)
+}
+
+// parseOptionalBool parses a boolean query parameter; empty means unset
+func parseOptionalBool(value string) (*bool, error) {
+	if value == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
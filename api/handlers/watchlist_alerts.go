@@ -0,0 +1,383 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"osint-api/cache"
+	"osint-api/findings"
+	"osint-api/metrics"
+	"osint-api/notify"
+
+	"github.com/gorilla/mux"
+)
+
+// Alert reasons
+const (
+	ReasonNewFindings   = "new_findings"
+	ReasonRiskThreshold = "risk_threshold"
+)
+
+// maxAlertFindings caps the new findings listed in a notification
+const maxAlertFindings = 20
+
+// Alert records a result on a watched target that introduced findings not
+// seen before or took its risk score across the watchlist's threshold
+type Alert struct {
+	ID                string             `json:"id"`
+	WatchlistID       string             `json:"watchlist_id"`
+	Watchlist         string             `json:"watchlist"` // name when the alert was raised
+	Owner             string             `json:"owner,omitempty"`
+	Team              string             `json:"team,omitempty"`
+	OperationID       string             `json:"operation_id"`
+	Target            string             `json:"target"`
+	Reasons           []string           `json:"reasons"` // new_findings, risk_threshold
+	NewFindings       []findings.Finding `json:"new_findings,omitempty"`
+	RiskScore         float64            `json:"risk_score"`
+	PreviousRiskScore *float64           `json:"previous_risk_score,omitempty"`
+	RiskThreshold     float64            `json:"risk_threshold,omitempty"`
+	Deliveries        []Delivery         `json:"deliveries,omitempty"`
+	CreatedAt         time.Time          `json:"created_at"`
+	AcknowledgedAt    *time.Time         `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy    string             `json:"acknowledged_by,omitempty"`
+}
+
+// Delivery is the outcome of sending an alert through one notifier
+type Delivery struct {
+	Notifier string    `json:"notifier"`
+	Status   string    `json:"status"` // sent, failed
+	Error    string    `json:"error,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// Observe checks a completed operation against every watchlist holding its
+// target, raises the alerts it calls for and sends them. Completions may be
+// observed out of order; an operation that completed before the last one
+// seen for a target is ignored.
+func (h *WatchlistHandler) Observe(operation Operation) {
+	target := cache.NormalizeTarget(operation.Target)
+	found := findings.Extract(operation.Results)
+
+	h.mu.Lock()
+	var raised []*Alert
+	for _, wl := range h.watchlists {
+		watched := wl.target(target)
+		if watched == nil {
+			continue
+		}
+		if stale(watched, operation) {
+			log.Printf("🔕 Watchlist %s skipped operation %s, older than %s", wl.ID, operation.ID, watched.LastOperationID)
+			continue
+		}
+		if alert := h.evaluate(wl, watched, operation, found); alert != nil {
+			h.alerts[alert.ID] = alert
+			h.persistAlert(alert)
+			raised = append(raised, alert)
+		}
+		h.persist(wl)
+	}
+	deliveries := make([][]notify.Notifier, len(raised))
+	messages := make([]notify.Message, len(raised))
+	for i, alert := range raised {
+		deliveries[i] = h.notifiersFor(h.watchlists[alert.WatchlistID])
+		messages[i] = alertMessage(*alert)
+	}
+	h.mu.Unlock()
+
+	for i, alert := range raised {
+		for _, reason := range alert.Reasons {
+			metrics.WatchlistAlerts.WithLabelValues(reason).Inc()
+		}
+		log.Printf("🔔 Watchlist %s raised alert %s (%s)", alert.WatchlistID, alert.ID, strings.Join(alert.Reasons, ", "))
+		h.deliver(alert.ID, deliveries[i], messages[i])
+	}
+}
+
+// evaluate updates what a watched target is known to have and returns the
+// alert the operation calls for, or nil. Callers must hold h.mu.
+func (h *WatchlistHandler) evaluate(wl *Watchlist, watched *WatchedTarget, operation Operation, found []findings.Finding) *Alert {
+	known := make(map[string]bool, len(watched.Known))
+	for _, key := range watched.Known {
+		known[key] = true
+	}
+	// Sampled findings are remembered but never alerted on: a different
+	// sample is not news
+	var fresh, news []findings.Finding
+	for _, finding := range found {
+		if !known[finding.Key] {
+			fresh = append(fresh, finding)
+			if !finding.Sampled {
+				news = append(news, finding)
+			}
+		}
+	}
+
+	var reasons []string
+	baseline := watched.LastOperationID == ""
+	if len(news) > 0 && !baseline {
+		reasons = append(reasons, ReasonNewFindings)
+	}
+	previous := watched.LastRiskScore
+	if wl.RiskThreshold > 0 && operation.RiskScore >= wl.RiskThreshold && (previous == nil || *previous < wl.RiskThreshold) {
+		reasons = append(reasons, ReasonRiskThreshold)
+	}
+
+	// Replace rather than modify, snapshots may share these
+	if len(fresh) > 0 {
+		keys := make([]string, 0, len(watched.Known)+len(fresh))
+		keys = append(keys, watched.Known...)
+		for _, finding := range fresh {
+			keys = append(keys, finding.Key)
+		}
+		sort.Strings(keys)
+		watched.Known = keys
+	}
+	risk := operation.RiskScore
+	watched.LastRiskScore = &risk
+	watched.LastOperationID = operation.ID
+	if operation.CompletedAt != nil {
+		completed := *operation.CompletedAt
+		watched.LastCompletedAt = &completed
+	}
+
+	if len(reasons) == 0 {
+		return nil
+	}
+	alert := &Alert{
+		ID:                fmt.Sprintf("alert_%d_%s", time.Now().Unix(), randomString(6)),
+		WatchlistID:       wl.ID,
+		Watchlist:         wl.Name,
+		Owner:             wl.Owner,
+		Team:              wl.Team,
+		OperationID:       operation.ID,
+		Target:            watched.Target,
+		Reasons:           reasons,
+		RiskScore:         operation.RiskScore,
+		PreviousRiskScore: previous,
+		RiskThreshold:     wl.RiskThreshold,
+		CreatedAt:         time.Now(),
+	}
+	if !baseline {
+		alert.NewFindings = news
+	}
+	return alert
+}
+
+// stale reports whether operation completed before the last one evaluated
+// for watched
+func stale(watched *WatchedTarget, operation Operation) bool {
+	return watched.LastCompletedAt != nil && operation.CompletedAt != nil && operation.CompletedAt.Before(*watched.LastCompletedAt)
+}
+
+// notifiersFor returns the notifiers a watchlist fires. Callers must hold h.mu.
+func (h *WatchlistHandler) notifiersFor(wl *Watchlist) []notify.Notifier {
+	var notifiers []notify.Notifier
+	if len(wl.Notifiers) == 0 {
+		for _, notifier := range h.Notifiers {
+			notifiers = append(notifiers, notifier)
+		}
+	}
+	for _, name := range wl.Notifiers {
+		if notifier, ok := h.Notifiers[name]; ok {
+			notifiers = append(notifiers, notifier)
+		}
+	}
+	sort.Slice(notifiers, func(i, j int) bool { return notifiers[i].Name() < notifiers[j].Name() })
+	return notifiers
+}
+
+// deliver sends an alert through each notifier and records the outcomes
+func (h *WatchlistHandler) deliver(alertID string, notifiers []notify.Notifier, msg notify.Message) {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	for _, notifier := range notifiers {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := notifier.Notify(ctx, msg)
+		cancel()
+
+		delivery := Delivery{Notifier: notifier.Name(), Status: "sent", At: time.Now()}
+		if err != nil {
+			delivery.Status, delivery.Error = "failed", err.Error()
+			log.Printf("⚠️ Failed to send alert %s through %s: %v", alertID, notifier.Name(), err)
+		}
+		metrics.AlertNotifications.WithLabelValues(notifier.Name(), delivery.Status).Inc()
+
+		h.mu.Lock()
+		if alert, ok := h.alerts[alertID]; ok {
+			alert.Deliveries = append(alert.Deliveries, delivery)
+			h.persistAlert(alert)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// alertMessage renders an alert for notifiers
+func alertMessage(alert Alert) notify.Message {
+	subject := fmt.Sprintf("[OSINT] %s: %s", alert.Watchlist, alert.Target)
+
+	var text strings.Builder
+	for _, reason := range alert.Reasons {
+		switch reason {
+		case ReasonNewFindings:
+			fmt.Fprintf(&text, "%d new findings\n", len(alert.NewFindings))
+			for i, finding := range alert.NewFindings {
+				if i == maxAlertFindings {
+					fmt.Fprintf(&text, "  ... and %d more\n", len(alert.NewFindings)-i)
+					break
+				}
+				fmt.Fprintf(&text, "  - %s: %s\n", finding.Source, finding.Value)
+			}
+		case ReasonRiskThreshold:
+			fmt.Fprintf(&text, "Risk score %.2f reached the threshold of %.2f\n", alert.RiskScore, alert.RiskThreshold)
+		}
+	}
+	fmt.Fprintf(&text, "Operation: %s\nAlert: %s", alert.OperationID, alert.ID)
+
+	return notify.Message{
+		Subject: subject,
+		Text:    text.String(),
+		Payload: map[string]interface{}{"event": "watchlist.alert", "alert": alert},
+	}
+}
+
+// ListAlerts returns alerts, newest first. Filters: watchlist, target,
+// reason, owner, team and acknowledged (true or false); limit caps the
+// number returned.
+func (h *WatchlistHandler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	target := cache.NormalizeTarget(query.Get("target"))
+	limit := defaultListLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			h.sendError(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		if n > maxListLimit {
+			n = maxListLimit
+		}
+		limit = n
+	}
+	var acknowledged *bool
+	if v := query.Get("acknowledged"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			h.sendError(w, "acknowledged must be true or false", http.StatusBadRequest)
+			return
+		}
+		acknowledged = &b
+	}
+
+	h.mu.RLock()
+	result := make([]Alert, 0)
+	for _, alert := range h.alerts {
+		switch {
+		case query.Get("watchlist") != "" && alert.WatchlistID != query.Get("watchlist"),
+			target != "" && alert.Target != target,
+			query.Get("reason") != "" && !containsString(alert.Reasons, query.Get("reason")),
+			query.Get("owner") != "" && alert.Owner != query.Get("owner"),
+			query.Get("team") != "" && alert.Team != query.Get("team"),
+			acknowledged != nil && *acknowledged != (alert.AcknowledgedAt != nil):
+			continue
+		}
+		result = append(result, alert.snapshot())
+	}
+	h.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.After(result[j].CreatedAt)
+		}
+		return result[i].ID > result[j].ID
+	})
+	total := len(result)
+	if len(result) > limit {
+		result = result[:limit]
+	}
+
+	response := map[string]interface{}{
+		"alerts":    result,
+		"count":     len(result),
+		"total":     total,
+		"timestamp": time.Now(),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetAlert returns one alert with its deliveries
+func (h *WatchlistHandler) GetAlert(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	h.mu.RLock()
+	alert, ok := h.alerts[mux.Vars(r)["id"]]
+	var snapshot Alert
+	if ok {
+		snapshot = alert.snapshot()
+	}
+	h.mu.RUnlock()
+
+	if !ok {
+		h.sendError(w, "Alert not found", http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// AcknowledgeAlert marks an alert as handled by the caller. Acknowledging
+// twice keeps the first acknowledgement.
+func (h *WatchlistHandler) AcknowledgeAlert(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	h.mu.Lock()
+	alert, ok := h.alerts[mux.Vars(r)["id"]]
+	var snapshot Alert
+	if ok {
+		if alert.AcknowledgedAt == nil {
+			now := time.Now()
+			alert.AcknowledgedAt = &now
+			alert.AcknowledgedBy = ownerFromContext(r.Context())
+			h.persistAlert(alert)
+		}
+		snapshot = alert.snapshot()
+	}
+	h.mu.Unlock()
+
+	if !ok {
+		h.sendError(w, "Alert not found", http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// snapshot copies an alert so it can be encoded without the lock
+func (a *Alert) snapshot() Alert {
+	copied := *a
+	copied.Deliveries = append([]Delivery(nil), a.Deliveries...)
+	return copied
+}
+
+// persistAlert writes an alert to the alert store, if any. Callers must
+// hold h.mu.
+func (h *WatchlistHandler) persistAlert(alert *Alert) {
+	if h.AlertStore == nil {
+		return
+	}
+	if err := h.AlertStore.Put(alert.ID, alert); err != nil {
+		log.Printf("⚠️ Failed to persist alert %s: %v", alert.ID, err)
+	}
+}
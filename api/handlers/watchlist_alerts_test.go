@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"osint-api/notify"
+)
+
+// recordingNotifier keeps the messages it is asked to send
+type recordingNotifier struct {
+	mu       sync.Mutex
+	messages []notify.Message
+}
+
+func (n *recordingNotifier) Name() string { return "recording" }
+
+func (n *recordingNotifier) Notify(ctx context.Context, msg notify.Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+func TestObserve(t *testing.T) {
+	notifier := &recordingNotifier{}
+	h := NewWatchlistHandler()
+	h.Notifiers = map[string]notify.Notifier{"recording": notifier}
+	h.watchlists["wl_1"] = &Watchlist{
+		ID: "wl_1", Name: "VIPs", RiskThreshold: 0.5,
+		Targets: []WatchedTarget{{Target: "oscar"}, {Target: "peggy"}},
+	}
+
+	start := time.Now()
+	completed := func(id string, minutes int, risk float64, results map[string]interface{}) Operation {
+		at := start.Add(time.Duration(minutes) * time.Minute)
+		return Operation{ID: id, Target: "@Oscar", Status: "completed", CompletedAt: &at, RiskScore: risk, Results: results}
+	}
+	listed := func(found ...interface{}) map[string]interface{} {
+		return map[string]interface{}{"findings": found}
+	}
+	watched := func() WatchedTarget {
+		h.mu.RLock()
+		defer h.mu.RUnlock()
+		return h.watchlists["wl_1"].Targets[0]
+	}
+	raised := func(want int) []*Alert {
+		t.Helper()
+		h.mu.RLock()
+		defer h.mu.RUnlock()
+		var alerts []*Alert
+		for _, alert := range h.alerts {
+			alerts = append(alerts, alert)
+		}
+		if len(alerts) != want {
+			t.Fatalf("%d alerts, want %d", len(alerts), want)
+		}
+		return alerts
+	}
+
+	// The first result is the baseline
+	h.Observe(completed("op_1", 0, 0.3, listed("paste:oscar")))
+	raised(0)
+
+	h.Observe(completed("op_3", 20, 0.3, listed("paste:oscar", "breach:2024")))
+	alert := raised(1)[0]
+	if fmt.Sprint(alert.Reasons) != "[new_findings]" || len(alert.NewFindings) != 1 || alert.NewFindings[0].Value != "breach:2024" {
+		t.Errorf("new findings alert %+v", alert)
+	}
+	if len(alert.Deliveries) != 1 || alert.Deliveries[0].Status != "sent" || len(notifier.messages) != 1 {
+		t.Errorf("deliveries %+v, %d messages sent", alert.Deliveries, len(notifier.messages))
+	}
+
+	// A run that completed earlier but is observed late changes nothing
+	h.Observe(completed("op_2", 10, 0.9, listed("paste:oscar", "old:lead")))
+	raised(1)
+	if got := watched(); got.LastOperationID != "op_3" || *got.LastRiskScore != 0.3 {
+		t.Errorf("stale run was evaluated: %+v", got)
+	}
+
+	h.Observe(completed("op_4", 30, 0.8, listed("paste:oscar", "breach:2024")))
+	for _, alert := range raised(2) {
+		if alert.OperationID == "op_4" && (fmt.Sprint(alert.Reasons) != "[risk_threshold]" || *alert.PreviousRiskScore != 0.3) {
+			t.Errorf("risk threshold alert %+v", alert)
+		}
+	}
+
+	// Pages from a sampled scrapy list are remembered without an alert
+	h.Observe(completed("op_5", 40, 0.9, map[string]interface{}{
+		"findings": []interface{}{"paste:oscar", "breach:2024"},
+		"scrapy":   map[string]interface{}{"pages_crawled": 40.0, "findings": []interface{}{map[string]interface{}{"url": "https://oscar.example"}}},
+	}))
+	raised(2)
+	if got := watched(); got.LastOperationID != "op_5" || len(got.Known) != 3 {
+		t.Errorf("after a sampled run: %+v", got)
+	}
+
+	// Other targets are not affected
+	h.mu.RLock()
+	other := h.watchlists["wl_1"].Targets[1]
+	h.mu.RUnlock()
+	if other.LastOperationID != "" {
+		t.Errorf("unrelated target evaluated: %+v", other)
+	}
+}
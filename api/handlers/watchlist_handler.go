@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"osint-api/cache"
+	"osint-api/handlers/middleware"
+	"osint-api/notify"
+	"osint-api/store"
+
+	"github.com/gorilla/mux"
+)
+
+const maxWatchedTargets = 1000
+
+// Watchlist is a set of targets a user or team wants alerts about. Every
+// completed operation against a watched target is checked for findings not
+// seen before and for its risk score crossing the threshold.
+type Watchlist struct {
+	ID            string          `json:"id"`
+	Name          string          `json:"name"`
+	Owner         string          `json:"owner,omitempty"` // API key ID of the creator
+	Team          string          `json:"team,omitempty"`
+	Targets       []WatchedTarget `json:"targets"`
+	RiskThreshold float64         `json:"risk_threshold,omitempty"` // alert when a result reaches it; 0 disables
+	Notifiers     []string        `json:"notifiers,omitempty"`      // empty fires every configured notifier
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// WatchedTarget is a target on a watchlist and what its results showed so
+// far. The first result only sets the baseline of known findings.
+type WatchedTarget struct {
+	Target          string     `json:"target"` // normalized
+	AddedAt         time.Time  `json:"added_at"`
+	LastOperationID string     `json:"last_operation_id,omitempty"`
+	LastCompletedAt *time.Time `json:"last_completed_at,omitempty"` // of LastOperationID
+	LastRiskScore   *float64   `json:"last_risk_score,omitempty"`
+	Known           []string   `json:"known_findings,omitempty"` // keys of findings already seen, sorted
+}
+
+// watchlistUpdate is the body of create and update requests. Omitted fields
+// are left unchanged on update; targets replaces the whole list, keeping
+// what is known about targets that stay on it.
+type watchlistUpdate struct {
+	Name          *string   `json:"name"`
+	Team          *string   `json:"team"`
+	Targets       *[]string `json:"targets"`
+	RiskThreshold *float64  `json:"risk_threshold"`
+	Notifiers     *[]string `json:"notifiers"`
+}
+
+// WatchlistHandler manages watchlists and the alerts raised for them
+type WatchlistHandler struct {
+	Notifiers  map[string]notify.Notifier // by name; nil raises alerts without notifying
+	Timeout    time.Duration              // per notification; 0 means 10s
+	Store      *store.Collection          // optional; nil keeps watchlists in memory only
+	AlertStore *store.Collection          // optional; nil keeps alerts in memory only
+	watchlists map[string]*Watchlist
+	alerts     map[string]*Alert
+	mu         sync.RWMutex
+}
+
+// NewWatchlistHandler creates a watchlist handler. Set OpsHandler.OnComplete
+// to its Observe method to check results as operations complete.
+func NewWatchlistHandler() *WatchlistHandler {
+	return &WatchlistHandler{
+		watchlists: make(map[string]*Watchlist),
+		alerts:     make(map[string]*Alert),
+	}
+}
+
+// Restore loads watchlists and alerts from their stores
+func (h *WatchlistHandler) Restore() (watchlists, alerts int, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.Store != nil {
+		err = h.Store.Each(func(id string, data []byte) error {
+			var w Watchlist
+			if err := json.Unmarshal(data, &w); err != nil {
+				log.Printf("⚠️ Skipping unreadable stored watchlist %s: %v", id, err)
+				return nil
+			}
+			h.watchlists[w.ID] = &w
+			return nil
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if h.AlertStore != nil {
+		err = h.AlertStore.Each(func(id string, data []byte) error {
+			var a Alert
+			if err := json.Unmarshal(data, &a); err != nil {
+				log.Printf("⚠️ Skipping unreadable stored alert %s: %v", id, err)
+				return nil
+			}
+			h.alerts[a.ID] = &a
+			return nil
+		})
+	}
+	return len(h.watchlists), len(h.alerts), err
+}
+
+// ListWatchlists returns watchlists, newest first, optionally filtered by
+// owner, team and a watched target
+func (h *WatchlistHandler) ListWatchlists(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ownerFilter := r.URL.Query().Get("owner")
+	teamFilter := r.URL.Query().Get("team")
+	targetFilter := cache.NormalizeTarget(r.URL.Query().Get("target"))
+	if targetFilter != "" {
+		middleware.SetLogTarget(r.Context(), targetFilter)
+	}
+
+	h.mu.RLock()
+	result := make([]Watchlist, 0)
+	for _, wl := range h.watchlists {
+		if ownerFilter != "" && wl.Owner != ownerFilter {
+			continue
+		}
+		if teamFilter != "" && wl.Team != teamFilter {
+			continue
+		}
+		if targetFilter != "" && wl.target(targetFilter) == nil {
+			continue
+		}
+		result = append(result, wl.snapshot())
+	}
+	h.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.After(result[j].CreatedAt) })
+
+	response := map[string]interface{}{
+		"watchlists": result,
+		"total":      len(result),
+		"timestamp":  time.Now(),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetWatchlist returns a watchlist with the number of alerts raised for it
+// and how many are unacknowledged. The alerts are listed by GET
+// /alerts?watchlist=.
+func (h *WatchlistHandler) GetWatchlist(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	h.mu.RLock()
+	wl, ok := h.watchlists[mux.Vars(r)["id"]]
+	var snapshot Watchlist
+	alerts, open := 0, 0
+	if ok {
+		snapshot = wl.snapshot()
+		for _, alert := range h.alerts {
+			if alert.WatchlistID == wl.ID {
+				alerts++
+				if alert.AcknowledgedAt == nil {
+					open++
+				}
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	if !ok {
+		h.sendError(w, "Watchlist not found", http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"watchlist":            snapshot,
+		"alert_count":          alerts,
+		"unacknowledged_count": open,
+		"timestamp":            time.Now(),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// CreateWatchlist adds a watchlist owned by the caller
+func (h *WatchlistHandler) CreateWatchlist(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var update watchlistUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		h.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if update.Name == nil {
+		h.sendError(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	wl := &Watchlist{
+		ID:        fmt.Sprintf("wl_%d_%s", now.Unix(), randomString(6)),
+		Owner:     ownerFromContext(r.Context()),
+		Targets:   []WatchedTarget{},
+		CreatedAt: now,
+	}
+	if err := h.apply(wl, update); err != nil {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	h.watchlists[wl.ID] = wl
+	h.persist(wl)
+	snapshot := wl.snapshot()
+	h.mu.Unlock()
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// UpdateWatchlist changes the fields present in the request body
+func (h *WatchlistHandler) UpdateWatchlist(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var update watchlistUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		h.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	wl, ok := h.watchlists[mux.Vars(r)["id"]]
+	if !ok {
+		h.mu.Unlock()
+		h.sendError(w, "Watchlist not found", http.StatusNotFound)
+		return
+	}
+	updated := wl.snapshot()
+	if err := h.apply(&updated, update); err != nil {
+		h.mu.Unlock()
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	*wl = updated
+	h.persist(wl)
+	snapshot := wl.snapshot()
+	h.mu.Unlock()
+
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// DeleteWatchlist removes a watchlist. Its alerts are kept.
+func (h *WatchlistHandler) DeleteWatchlist(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+
+	h.mu.Lock()
+	_, ok := h.watchlists[id]
+	if ok {
+		delete(h.watchlists, id)
+		h.unpersist(id)
+	}
+	h.mu.Unlock()
+
+	if !ok {
+		h.sendError(w, "Watchlist not found", http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"id":         id,
+		"status":     "deleted",
+		"deleted_at": time.Now(),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// apply validates and copies the fields present in update
+func (h *WatchlistHandler) apply(wl *Watchlist, update watchlistUpdate) error {
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		if name == "" || len(name) > 200 {
+			return errors.New("name must be 1 to 200 characters")
+		}
+		wl.Name = name
+	}
+	if update.Team != nil {
+		wl.Team = strings.TrimSpace(*update.Team)
+	}
+	if update.RiskThreshold != nil {
+		if *update.RiskThreshold < 0 {
+			return errors.New("risk_threshold must not be negative")
+		}
+		wl.RiskThreshold = *update.RiskThreshold
+	}
+	if update.Notifiers != nil {
+		names := normalizeTags(*update.Notifiers)
+		for _, name := range names {
+			if _, ok := h.Notifiers[name]; !ok {
+				return fmt.Errorf("notifier %q is not configured", name)
+			}
+		}
+		wl.Notifiers = names
+	}
+	if update.Targets != nil {
+		if len(*update.Targets) > maxWatchedTargets {
+			return fmt.Errorf("a watchlist holds at most %d targets", maxWatchedTargets)
+		}
+		targets := make([]WatchedTarget, 0, len(*update.Targets))
+		seen := make(map[string]bool, len(*update.Targets))
+		for _, target := range *update.Targets {
+			target = cache.NormalizeTarget(target)
+			if target == "" || seen[target] {
+				continue
+			}
+			seen[target] = true
+			if existing := wl.target(target); existing != nil {
+				targets = append(targets, *existing)
+			} else {
+				targets = append(targets, WatchedTarget{Target: target, AddedAt: time.Now()})
+			}
+		}
+		wl.Targets = targets
+	}
+	wl.UpdatedAt = time.Now()
+	return nil
+}
+
+// target returns the watched entry for a normalized target, or nil
+func (wl *Watchlist) target(target string) *WatchedTarget {
+	for i := range wl.Targets {
+		if wl.Targets[i].Target == target {
+			return &wl.Targets[i]
+		}
+	}
+	return nil
+}
+
+// snapshot copies a watchlist so it can be changed or encoded without the
+// lock
+func (wl *Watchlist) snapshot() Watchlist {
+	copied := *wl
+	copied.Targets = append([]WatchedTarget{}, wl.Targets...)
+	copied.Notifiers = append([]string(nil), wl.Notifiers...)
+	return copied
+}
+
+// persist writes a watchlist to the store, if any. Callers must hold h.mu.
+func (h *WatchlistHandler) persist(wl *Watchlist) {
+	if h.Store == nil {
+		return
+	}
+	if err := h.Store.Put(wl.ID, wl); err != nil {
+		log.Printf("⚠️ Failed to persist watchlist %s: %v", wl.ID, err)
+	}
+}
+
+// unpersist removes a watchlist from the store, if any. Callers must hold h.mu.
+func (h *WatchlistHandler) unpersist(id string) {
+	if h.Store == nil {
+		return
+	}
+	if err := h.Store.Delete(id); err != nil {
+		log.Printf("⚠️ Failed to delete stored watchlist %s: %v", id, err)
+	}
+}
+
+// sendError sends a standardized error response
+func (h *WatchlistHandler) sendError(w http.ResponseWriter, message string, statusCode int) {
+	errorResponse := map[string]interface{}{
+		"error":       message,
+		"status":      "error",
+		"status_code": statusCode,
+		"request_id":  w.Header().Get(middleware.RequestIDHeader),
+		"timestamp":   time.Now(),
+	}
+
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(errorResponse)
+}
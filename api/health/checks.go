@@ -0,0 +1,73 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"osint-api/orchestra"
+	"osint-api/protocol"
+)
+
+// ZMQPing checks a ZMQ REP service by sending a ping message and waiting
+// for a "pong" reply. curve may be nil for plaintext services.
+func ZMQPing(name, endpoint string, curve *orchestra.Curve) Checker {
+	return Func(name, func(ctx context.Context) error {
+		request, err := protocol.New(protocol.TypePing, "", nil)
+		if err != nil {
+			return err
+		}
+		reply, err := orchestra.RequestOnce(ctx, endpoint, curve, request)
+		if err != nil {
+			return err
+		}
+
+		var pong protocol.PingReply
+		if err := reply.DecodePayload(&pong); err != nil {
+			return errors.New("invalid ping reply")
+		}
+		if pong.Status != "pong" {
+			return fmt.Errorf("unexpected ping reply status %q", pong.Status)
+		}
+		return nil
+	})
+}
+
+// CircuitBreaker fails while breaker is open or half-open, so tripped
+// circuits show up in the health report
+func CircuitBreaker(name string, breaker *orchestra.Breaker) Checker {
+	return Func(name, func(ctx context.Context) error {
+		switch breaker.State() {
+		case orchestra.BreakerOpen:
+			return breaker.Check()
+		case orchestra.BreakerHalfOpen:
+			return errors.New("circuit half-open; waiting for a trial call to succeed")
+		}
+		return nil
+	})
+}
+
+// DirWritable checks that files can be created in dir
+func DirWritable(name, dir string) Checker {
+	return Func(name, func(ctx context.Context) error {
+		file, err := os.CreateTemp(dir, ".healthcheck-*")
+		if err != nil {
+			return err
+		}
+		file.Close()
+		return os.Remove(file.Name())
+	})
+}
+
+// FileReadable checks that path exists and can be opened
+func FileReadable(name, path string) Checker {
+	return Func(name, func(ctx context.Context) error {
+		file, err := os.Open(filepath.Clean(path))
+		if err != nil {
+			return err
+		}
+		return file.Close()
+	})
+}
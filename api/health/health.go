@@ -0,0 +1,186 @@
+// Package health runs dependency checks in the background and serves their
+// cached results to the liveness, readiness and health endpoints.
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Check statuses
+const (
+	StatusHealthy   = "healthy"
+	StatusUnhealthy = "unhealthy"
+	StatusDegraded  = "degraded" // only non-critical checks are failing
+	StatusUnknown   = "unknown"  // no check has completed yet
+)
+
+var startTime = time.Now()
+
+// Uptime returns how long the process has been running
+func Uptime() time.Duration {
+	return time.Since(startTime)
+}
+
+// Checker probes one dependency. Check must honour ctx's deadline.
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// Func returns a Checker named name that runs fn
+func Func(name string, fn func(ctx context.Context) error) Checker {
+	return funcChecker{name: name, fn: fn}
+}
+
+type funcChecker struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func (c funcChecker) Name() string                    { return c.name }
+func (c funcChecker) Check(ctx context.Context) error { return c.fn(ctx) }
+
+// Result is the outcome of the latest run of one check
+type Result struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Critical  bool      `json:"critical"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMS int64     `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report summarizes every registered check
+type Report struct {
+	Status string   `json:"status"`
+	Ready  bool     `json:"ready"`
+	Checks []Result `json:"checks"`
+}
+
+type registration struct {
+	checker  Checker
+	critical bool
+}
+
+// Monitor runs registered checks and caches their results. Critical checks
+// gate readiness; non-critical failures only degrade the reported health.
+type Monitor struct {
+	timeout time.Duration
+
+	mu      sync.RWMutex
+	checks  []registration
+	results map[string]Result
+	runMu   sync.Mutex // serializes check rounds
+}
+
+// NewMonitor creates a monitor whose checks each get timeout to complete
+func NewMonitor(timeout time.Duration) *Monitor {
+	return &Monitor{
+		timeout: timeout,
+		results: make(map[string]Result),
+	}
+}
+
+// Register adds a checker. Critical checkers must pass for readiness.
+func (m *Monitor) Register(checker Checker, critical bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checks = append(m.checks, registration{checker: checker, critical: critical})
+}
+
+// Watch runs every check immediately and then every interval until ctx is
+// cancelled
+func (m *Monitor) Watch(ctx context.Context, interval time.Duration) {
+	m.Refresh(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Refresh(ctx)
+		}
+	}
+}
+
+// Refresh runs every check concurrently and stores the results
+func (m *Monitor) Refresh(ctx context.Context) {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+
+	m.mu.RLock()
+	checks := append([]registration(nil), m.checks...)
+	m.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, reg := range checks {
+		wg.Add(1)
+		go func(i int, reg registration) {
+			defer wg.Done()
+			results[i] = m.run(ctx, reg)
+		}(i, reg)
+	}
+	wg.Wait()
+
+	m.mu.Lock()
+	for _, result := range results {
+		m.results[result.Name] = result
+	}
+	m.mu.Unlock()
+}
+
+func (m *Monitor) run(ctx context.Context, reg registration) Result {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := reg.checker.Check(ctx)
+	result := Result{
+		Name:      reg.checker.Name(),
+		Status:    StatusHealthy,
+		Critical:  reg.critical,
+		LatencyMS: time.Since(start).Milliseconds(),
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Status = StatusUnhealthy
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Report returns the cached results. Checks that have not run yet are
+// reported as unknown and count as failing.
+func (m *Monitor) Report() Report {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	report := Report{Status: StatusHealthy, Ready: true}
+	for _, reg := range m.checks {
+		result, ok := m.results[reg.checker.Name()]
+		if !ok {
+			result = Result{Name: reg.checker.Name(), Status: StatusUnknown, Critical: reg.critical}
+		}
+		report.Checks = append(report.Checks, result)
+
+		if result.Status == StatusHealthy {
+			continue
+		}
+		if reg.critical {
+			report.Ready = false
+			report.Status = StatusUnhealthy
+		} else if report.Status == StatusHealthy {
+			report.Status = StatusDegraded
+		}
+	}
+
+	sort.Slice(report.Checks, func(i, j int) bool { return report.Checks[i].Name < report.Checks[j].Name })
+	return report
+}
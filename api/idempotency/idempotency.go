@@ -0,0 +1,267 @@
+// Package idempotency remembers the response to the first request made with
+// an Idempotency-Key, so a client retrying after a network failure gets the
+// same response instead of starting a duplicate investigation.
+package idempotency
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"osint-api/store"
+)
+
+// DefaultCapacity bounds the remembered keys when New is given none
+const DefaultCapacity = 10000
+
+// Record is the outcome of the first request made with a key
+type Record struct {
+	Fingerprint string      `json:"fingerprint"` // hash of the request, to detect a reused key
+	Status      int         `json:"status"`      // zero while the first request is still running
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"-"` // memory only; responses carry targets and results
+	BodyDigest  string      `json:"body_digest,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+// Done reports whether the first request has finished
+func (r *Record) Done() bool {
+	return r.Status != 0
+}
+
+// Replayable reports whether the response can be sent again. Records loaded
+// from disk after a restart only know that the request was made.
+func (r *Record) Replayable() bool {
+	return r.Body != nil || r.BodyDigest == ""
+}
+
+// Store keeps up to capacity records for ttl, in memory and optionally on
+// disk so a repeated key is still recognised after a restart. The disk only
+// holds fingerprints, statuses and headers, never response bodies. Records
+// are scoped to a caller, so two API keys may use the same Idempotency-Key
+// independently.
+type Store struct {
+	ttl      time.Duration
+	capacity int
+	disk     *store.Collection // optional
+
+	mu      sync.Mutex
+	order   *list.List // of *entry, oldest first
+	records map[string]*list.Element
+}
+
+type entry struct {
+	id     string
+	record *Record
+}
+
+// New creates a store, loading the newest unexpired records from disk if
+// given. A capacity of 0 means DefaultCapacity.
+func New(ttl time.Duration, capacity int, disk *store.Collection) (*Store, error) {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	s := &Store{
+		ttl:      ttl,
+		capacity: capacity,
+		disk:     disk,
+		order:    list.New(),
+		records:  make(map[string]*list.Element),
+	}
+	if disk == nil {
+		return s, nil
+	}
+
+	var loaded []entry
+	err := disk.Each(func(id string, data []byte) error {
+		var record Record
+		if err := json.Unmarshal(data, &record); err != nil || !record.Done() || s.expired(&record) {
+			disk.Delete(id)
+			return nil
+		}
+		loaded = append(loaded, entry{id, &record})
+		return nil
+	})
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].record.CreatedAt.Before(loaded[j].record.CreatedAt) })
+	for _, e := range loaded {
+		s.records[e.id] = s.order.PushBack(&entry{e.id, e.record})
+	}
+	s.deleteFiles(s.evict())
+	return s, err
+}
+
+// ID derives the record ID for a caller's key
+func ID(caller, key string) string {
+	sum := sha256.Sum256([]byte(caller + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Fingerprint hashes what makes two requests the same. A JSON body is
+// hashed in canonical form, so whitespace and key order do not matter.
+func Fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(canonical(body))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// canonical re-encodes a JSON body with sorted keys and no insignificant
+// whitespace. Anything else is returned as is.
+func canonical(body []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil || decoder.More() {
+		return body
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return encoded
+}
+
+// Begin returns the record for id if one exists. Otherwise it reserves id
+// for a request with fingerprint and returns nil; the caller must then
+// Complete or Release it.
+func (s *Store) Begin(id, fingerprint string) *Record {
+	s.mu.Lock()
+	if element, ok := s.records[id]; ok {
+		if record := element.Value.(*entry).record; !s.expired(record) {
+			copied := *record
+			s.mu.Unlock()
+			return &copied
+		}
+		s.remove(element)
+	}
+	record := &Record{Fingerprint: fingerprint, CreatedAt: time.Now()}
+	s.records[id] = s.order.PushBack(&entry{id, record})
+	evicted := s.evict()
+	s.mu.Unlock()
+
+	s.deleteFiles(evicted)
+	return nil
+}
+
+// Complete stores the response to the request that reserved id
+func (s *Store) Complete(id string, status int, header http.Header, body []byte) {
+	s.mu.Lock()
+	element, ok := s.records[id]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	record := element.Value.(*entry).record
+	record.Status = status
+	record.Header = header
+	record.Body = body
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		record.BodyDigest = hex.EncodeToString(sum[:])
+	}
+	stored := *record
+	s.mu.Unlock()
+
+	if s.disk != nil {
+		if err := s.disk.Put(id, &stored); err != nil {
+			log.Printf("⚠️ Failed to persist idempotency record: %v", err)
+		}
+	}
+}
+
+// Release forgets a reservation, so the key can be used again
+func (s *Store) Release(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.records[id]; ok {
+		s.order.Remove(element)
+		delete(s.records, id)
+	}
+}
+
+// Len returns the number of records held
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records)
+}
+
+// Purge removes expired records and returns how many it removed
+func (s *Store) Purge() int {
+	s.mu.Lock()
+	var purged []string
+	for element := s.order.Front(); element != nil; {
+		next := element.Next()
+		if s.expired(element.Value.(*entry).record) {
+			purged = append(purged, s.remove(element))
+		}
+		element = next
+	}
+	s.mu.Unlock()
+
+	s.deleteFiles(purged)
+	return len(purged)
+}
+
+// Watch purges expired records every interval until ctx is cancelled
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Purge()
+		}
+	}
+}
+
+// evict drops the oldest finished records beyond capacity and returns their
+// IDs. Reservations are kept; there are at most as many as requests in
+// progress. Callers must hold s.mu.
+func (s *Store) evict() []string {
+	var evicted []string
+	for element := s.order.Front(); element != nil && len(s.records) > s.capacity; {
+		next := element.Next()
+		if element.Value.(*entry).record.Done() {
+			evicted = append(evicted, s.remove(element))
+		}
+		element = next
+	}
+	return evicted
+}
+
+// remove forgets a record in memory and returns its ID. Callers must hold
+// s.mu.
+func (s *Store) remove(element *list.Element) string {
+	e := s.order.Remove(element).(*entry)
+	delete(s.records, e.id)
+	return e.id
+}
+
+// deleteFiles removes forgotten records from disk
+func (s *Store) deleteFiles(ids []string) {
+	if s.disk == nil {
+		return
+	}
+	for _, id := range ids {
+		s.disk.Delete(id)
+	}
+}
+
+// expired reports whether record is past the window. Reservations never
+// expire, so a slow first request cannot be duplicated.
+func (s *Store) expired(record *Record) bool {
+	return record.Done() && time.Since(record.CreatedAt) > s.ttl
+}
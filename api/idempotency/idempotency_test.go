@@ -0,0 +1,170 @@
+package idempotency
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"osint-api/store"
+)
+
+func TestFingerprint(t *testing.T) {
+	base := Fingerprint("POST", "/api/v1/operations", []byte(`{"target":"eve","tags":["a","b"],"priority":"high"}`))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		same   bool
+	}{
+		{"whitespace", "POST", "/api/v1/operations", "{\n  \"target\": \"eve\",\n  \"tags\": [\"a\", \"b\"],\n  \"priority\": \"high\"\n}\n", true},
+		{"key order", "POST", "/api/v1/operations", `{"priority":"high","tags":["a","b"],"target":"eve"}`, true},
+		{"array order", "POST", "/api/v1/operations", `{"target":"eve","tags":["b","a"],"priority":"high"}`, false},
+		{"value", "POST", "/api/v1/operations", `{"target":"eve","tags":["a","b"],"priority":"low"}`, false},
+		{"path", "POST", "/api/v1/intel", `{"target":"eve","tags":["a","b"],"priority":"high"}`, false},
+		{"method", "PUT", "/api/v1/operations", `{"target":"eve","tags":["a","b"],"priority":"high"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := Fingerprint(tt.method, tt.path, []byte(tt.body)) == base; same != tt.same {
+				t.Errorf("same fingerprint %v, want %v", same, tt.same)
+			}
+		})
+	}
+
+	// Bodies that are not a single JSON value are hashed as they are
+	if Fingerprint("POST", "/x", []byte("not json")) == Fingerprint("POST", "/x", []byte("not  json")) {
+		t.Error("raw bodies with different bytes share a fingerprint")
+	}
+	if Fingerprint("POST", "/x", []byte(`{"a":1} {"b":2}`)) == Fingerprint("POST", "/x", []byte(`{"a":1}`)) {
+		t.Error("trailing JSON value was ignored")
+	}
+}
+
+func TestBeginCompleteRelease(t *testing.T) {
+	s, err := New(time.Hour, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if record := s.Begin("k1", "fp"); record != nil {
+		t.Fatalf("new key returned %+v", record)
+	}
+	if record := s.Begin("k1", "fp"); record == nil || record.Done() {
+		t.Fatalf("repeat during the first request: %+v, want a reservation", record)
+	}
+
+	s.Complete("k1", http.StatusCreated, http.Header{"Location": {"/op_1"}}, []byte(`{"operation_id":"op_1"}`))
+	record := s.Begin("k1", "other")
+	if record == nil || record.Status != http.StatusCreated || record.Fingerprint != "fp" || string(record.Body) != `{"operation_id":"op_1"}` || !record.Replayable() {
+		t.Fatalf("completed record %+v", record)
+	}
+
+	s.Begin("k2", "fp")
+	s.Release("k2")
+	if record := s.Begin("k2", "fp"); record != nil {
+		t.Errorf("released key returned %+v", record)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	s, err := New(time.Hour, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Begin("old", "fp")
+	s.Complete("old", http.StatusOK, nil, nil)
+	s.Begin("running", "fp")
+	s.records["old"].Value.(*entry).record.CreatedAt = time.Now().Add(-2 * time.Hour)
+	s.records["running"].Value.(*entry).record.CreatedAt = time.Now().Add(-2 * time.Hour)
+
+	if record := s.Begin("old", "fp2"); record != nil {
+		t.Errorf("expired record returned %+v", record)
+	}
+	s.Complete("old", http.StatusOK, nil, nil)
+	s.records["old"].Value.(*entry).record.CreatedAt = time.Now().Add(-2 * time.Hour)
+
+	// Reservations never expire
+	if n := s.Purge(); n != 1 || s.Len() != 1 {
+		t.Errorf("purged %d, %d left; want 1 and 1", n, s.Len())
+	}
+	if record := s.Begin("running", "fp"); record == nil || record.Done() {
+		t.Errorf("reservation after purge: %+v", record)
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	s, err := New(time.Hour, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A reservation at the front is kept; the oldest finished record goes
+	s.Begin("running", "fp")
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("k%d", i)
+		s.Begin(id, "fp")
+		s.Complete(id, http.StatusOK, nil, nil)
+	}
+	if n := s.Len(); n != 3 {
+		t.Fatalf("%d records, want 3", n)
+	}
+	for id, kept := range map[string]bool{"running": true, "k2": false, "k3": true, "k4": true} {
+		if _, ok := s.records[id]; ok != kept {
+			t.Errorf("%s kept %v, want %v", id, ok, kept)
+		}
+	}
+}
+
+func TestDiskHoldsNoBodies(t *testing.T) {
+	dir := t.TempDir()
+	disk, err := store.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(time.Hour, 2, disk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		s.Begin(id, "fp-"+id)
+		s.Complete(id, http.StatusCreated, nil, []byte(`{"target":"secret-target-`+id+`"}`))
+	}
+	s.Begin("empty", "fp-empty")
+	s.Complete("empty", http.StatusNoContent, nil, nil)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 2 {
+		t.Errorf("%d files on disk, want 2", len(files))
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "secret-target") {
+			t.Errorf("%s holds a response body: %s", file, data)
+		}
+	}
+
+	// After a restart the key is still known, but its response is gone
+	reopened, err := New(time.Hour, 2, disk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := reopened.Begin("c", "fp-c")
+	if record == nil || record.Status != http.StatusCreated || record.Replayable() {
+		t.Errorf("reloaded record %+v, want a finished one that cannot be replayed", record)
+	}
+	if record := reopened.Begin("empty", "fp-empty"); record == nil || !record.Replayable() {
+		t.Errorf("reloaded empty response %+v, want replayable", record)
+	}
+	if record := reopened.Begin("a", "fp-a"); record != nil {
+		t.Errorf("evicted record came back: %+v", record)
+	}
+}
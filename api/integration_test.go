@@ -0,0 +1,997 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"osint-api/config"
+	"osint-api/handlers"
+	"osint-api/health"
+	"osint-api/metrics"
+	"osint-api/orchestra"
+	"osint-api/orchestra/orchestratest"
+	"osint-api/protocol"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const testAPIKey = "osint-api-key-123"
+
+// testAPI is the full HTTP stack wired to a fake orchestra
+type testAPI struct {
+	t         *testing.T
+	orchestra *orchestratest.Server
+	monitor   *health.Monitor
+	schedules *handlers.ScheduleHandler
+	url       string
+}
+
+func newTestAPI(t *testing.T, configure func(*config.Config)) *testAPI {
+	t.Helper()
+
+	fake := orchestratest.NewServer()
+	t.Cleanup(fake.Close)
+
+	// Defaults only, so the ambient environment and .env cannot leak in
+	cfg, err := config.Load(config.Options{Environ: []string{}})
+	if err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	cfg.Orchestra.Addr = fake.Endpoint
+	cfg.Orchestra.Timeout = 2 * time.Second
+	cfg.Brain.URL = ""
+	cfg.Cache.Dir = ""
+	cfg.Operations.Dir = ""
+	cfg.Health.Interval = time.Hour // tests refresh explicitly
+	cfg.Health.Timeout = 300 * time.Millisecond
+	cfg.Operations.RetryBackoff = 20 * time.Millisecond
+	cfg.Operations.RetryMaxBackoff = 100 * time.Millisecond
+	if configure != nil {
+		configure(cfg)
+	}
+
+	breaker := orchestra.NewBreaker(orchestra.BreakerSettings{
+		Failures: cfg.Orchestra.BreakerFailures,
+		Cooldown: cfg.Orchestra.BreakerCooldown,
+		Probes:   cfg.Orchestra.BreakerProbes,
+	})
+	client, err := orchestra.Dial(cfg.Orchestra.Addr, cfg.Orchestra.Timeout, nil, breaker)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close(context.Background()) })
+
+	resultCache, err := newResultCache(cfg.Cache)
+	if err != nil {
+		t.Fatalf("cache: %v", err)
+	}
+
+	idempotencyStore, err := newIdempotencyStore(cfg.Idempotency)
+	if err != nil {
+		t.Fatalf("idempotency: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	watchlists, err := newWatchlistHandler(cfg.Watchlists)
+	if err != nil {
+		t.Fatalf("watchlists: %v", err)
+	}
+
+	ops := handlers.NewOpsHandler(client)
+	ops.OnComplete = watchlists.Observe
+	ops.Retry = handlers.RetryPolicy{
+		MaxAttempts: cfg.Operations.MaxAttempts,
+		Backoff:     cfg.Operations.RetryBackoff,
+		MaxBackoff:  cfg.Operations.RetryMaxBackoff,
+	}
+	monitor := newHealthMonitor(ctx, cfg, nil, breaker, nil, nil)
+	schedules := handlers.NewScheduleHandler(ops) // tests call RunDue instead of Watch
+	router := newRouter(cfg, services{
+		intel:      &handlers.IntelHandler{Ops: ops, Cache: resultCache},
+		ops:        ops,
+		cases:      handlers.NewCaseHandler(ops),
+		schedules:  schedules,
+		watchlists: watchlists,
+		health:     &handlers.HealthHandler{Monitor: monitor},
+		cache:      &handlers.CacheHandler{Cache: resultCache},
+
+		idempotency: idempotencyStore,
+	})
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	monitor.Refresh(ctx)
+
+	return &testAPI{t: t, orchestra: fake, monitor: monitor, schedules: schedules, url: server.URL}
+}
+
+// do sends an authenticated request and decodes the JSON response body
+func (a *testAPI) do(method, path string, body interface{}, header ...string) (*http.Response, map[string]interface{}) {
+	a.t.Helper()
+
+	var payload bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+	req, err := http.NewRequest(method, a.url+path, &payload)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", testAPIKey)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		a.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	var decoded map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&decoded)
+	return resp, decoded
+}
+
+func (a *testAPI) waitForStatus(operationID, status string) map[string]interface{} {
+	a.t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, operation := a.do("GET", "/api/v1/operations/status?id="+operationID, nil)
+		if operation["status"] == status {
+			return operation
+		}
+		if time.Now().After(deadline) {
+			a.t.Fatalf("operation %s stuck in %v, want %s", operationID, operation["status"], status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestIntelEndToEnd(t *testing.T) {
+	api := newTestAPI(t, nil)
+	api.orchestra.Script(protocol.TypeInvestigate, orchestratest.Response{
+		Payload: map[string]interface{}{"status": "completed", "risk_score": 0.7, "findings": []string{"breach"}},
+	})
+
+	resp, body := api.do("POST", "/api/v1/intel", map[string]interface{}{
+		"target":  "alice@example.com",
+		"modules": []string{"email"},
+	}, "X-Request-ID", "it-intel-1")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d: %v", resp.StatusCode, body)
+	}
+	if body["risk_score"] != 0.7 || resp.Header.Get("X-Cache") != "MISS" {
+		t.Errorf("body %v, X-Cache %q", body, resp.Header.Get("X-Cache"))
+	}
+
+	requests := api.orchestra.Requests(protocol.TypeInvestigate)
+	if len(requests) != 1 {
+		t.Fatalf("orchestra got %d investigate requests, want 1", len(requests))
+	}
+	var payload protocol.InvestigateRequest
+	if err := requests[0].DecodePayload(&payload); err != nil {
+		t.Fatal(err)
+	}
+	if requests[0].RequestID != "it-intel-1" || payload.Target != "alice@example.com" {
+		t.Errorf("orchestra got request_id %q target %q", requests[0].RequestID, payload.Target)
+	}
+	if requests[0].ID == "" || requests[0].ID == requests[0].RequestID {
+		t.Errorf("envelope id %q is not a fresh correlation ID", requests[0].ID)
+	}
+	if requests[0].Deadline == nil {
+		t.Errorf("request has no deadline: %+v", requests[0])
+	}
+
+	// The same investigation is served from the cache
+	resp, body = api.do("POST", "/api/v1/intel", map[string]interface{}{
+		"target":  "alice@example.com",
+		"modules": []string{"email"},
+	})
+	if resp.Header.Get("X-Cache") != "HIT" || body["risk_score"] != 0.7 {
+		t.Errorf("second request: X-Cache %q, body %v", resp.Header.Get("X-Cache"), body)
+	}
+	if n := len(api.orchestra.Requests(protocol.TypeInvestigate)); n != 1 {
+		t.Errorf("orchestra got %d investigate requests after a cache hit, want 1", n)
+	}
+}
+
+func TestIntelOrchestraError(t *testing.T) {
+	api := newTestAPI(t, nil)
+	api.orchestra.Script(protocol.TypeInvestigate, orchestratest.Response{Error: "spiderfoot unavailable"})
+
+	resp, body := api.do("POST", "/api/v1/intel", map[string]interface{}{"target": "bob"}, "X-Request-ID", "it-error-1")
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("status %d, want 500", resp.StatusCode)
+	}
+	if body["error"] != "spiderfoot unavailable" || body["request_id"] != "it-error-1" {
+		t.Errorf("error body %v", body)
+	}
+}
+
+func TestIntelUncorrelatedReply(t *testing.T) {
+	api := newTestAPI(t, func(cfg *config.Config) {
+		cfg.Operations.MaxAttempts = 1
+	})
+	api.orchestra.Script(protocol.TypeInvestigate, orchestratest.Response{
+		Raw: []byte(`{"protocol":2,"type":"reply","id":"it-stale-1","payload":{"status":"completed","risk_score":0.9}}`),
+	})
+
+	resp, body := api.do("POST", "/api/v1/intel", map[string]interface{}{"target": "dave"}, "X-Request-ID", "it-stale-1")
+	if resp.StatusCode != http.StatusInternalServerError || !strings.Contains(body["error"].(string), "instead of") {
+		t.Fatalf("status %d, body %v", resp.StatusCode, body)
+	}
+}
+
+func TestIntelOrchestraTimeout(t *testing.T) {
+	api := newTestAPI(t, func(cfg *config.Config) {
+		cfg.Orchestra.Timeout = 200 * time.Millisecond
+		cfg.Operations.MaxAttempts = 1
+	})
+	api.orchestra.Script(protocol.TypeInvestigate, orchestratest.Response{Drop: true})
+
+	resp, body := api.do("POST", "/api/v1/intel", map[string]interface{}{"target": "carol"})
+	if resp.StatusCode != http.StatusInternalServerError || !strings.Contains(body["error"].(string), "did not reply") {
+		t.Fatalf("status %d, body %v", resp.StatusCode, body)
+	}
+
+	// The client reconnects, so the next investigation goes through
+	resp, body = api.do("POST", "/api/v1/intel", map[string]interface{}{"target": "carol"})
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("after a timeout: status %d, body %v", resp.StatusCode, body)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	api := newTestAPI(t, func(cfg *config.Config) {
+		cfg.Orchestra.Timeout = 100 * time.Millisecond
+		cfg.Orchestra.BreakerFailures = 2
+		cfg.Orchestra.BreakerCooldown = 500 * time.Millisecond
+		cfg.Operations.MaxAttempts = 1
+	})
+	api.orchestra.Script(protocol.TypeInvestigate,
+		orchestratest.Response{Drop: true},
+		orchestratest.Response{Drop: true},
+	)
+
+	for _, target := range []string{"ivan", "judy"} {
+		if resp, _ := api.do("POST", "/api/v1/intel", map[string]interface{}{"target": target}); resp.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("%s: status %d, want 500", target, resp.StatusCode)
+		}
+	}
+
+	// Two timeouts open the circuit: calls fail fast without reaching orchestra
+	start := time.Now()
+	resp, body := api.do("POST", "/api/v1/intel", map[string]interface{}{"target": "mallory"})
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("open circuit: status %d, Retry-After %q, body %v", resp.StatusCode, resp.Header.Get("Retry-After"), body)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("open circuit took %s to fail", elapsed)
+	}
+	if resp, _ := api.do("POST", "/api/v1/operations", map[string]interface{}{"target": "mallory"}); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("create operation with open circuit: status %d, want 503", resp.StatusCode)
+	}
+	resp, body = api.do("POST", "/api/v1/intel/batch", []map[string]interface{}{{"target": "mallory"}, {"target": "niaj"}})
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") == "" || body["failed"] != 2.0 {
+		t.Errorf("batch with open circuit: status %d, Retry-After %q, body %v", resp.StatusCode, resp.Header.Get("Retry-After"), body)
+	}
+	if n := len(api.orchestra.Requests(protocol.TypeInvestigate)); n != 2 {
+		t.Errorf("orchestra got %d investigate requests, want 2", n)
+	}
+
+	api.monitor.Refresh(context.Background())
+	if _, report := api.do("GET", "/api/v1/health", nil); !strings.Contains(fmt.Sprint(report["checks"]), "circuit open") {
+		t.Errorf("health does not report the open circuit: %v", report["checks"])
+	}
+
+	// After the cooldown a trial call succeeds and closes the circuit
+	time.Sleep(500 * time.Millisecond)
+	if resp, body := api.do("POST", "/api/v1/intel", map[string]interface{}{"target": "mallory"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("trial call: status %d, body %v", resp.StatusCode, body)
+	}
+	if resp, _ := api.do("POST", "/api/v1/intel", map[string]interface{}{"target": "niaj"}); resp.StatusCode != http.StatusOK {
+		t.Errorf("closed circuit: status %d", resp.StatusCode)
+	}
+}
+
+func TestIntelBatch(t *testing.T) {
+	api := newTestAPI(t, nil)
+	api.orchestra.Handle(protocol.TypeInvestigate, func(request *protocol.Envelope) orchestratest.Response {
+		var payload protocol.InvestigateRequest
+		request.DecodePayload(&payload)
+		if payload.Target == "broken" {
+			return orchestratest.Response{Error: "target rejected"}
+		}
+		return orchestratest.Response{Payload: map[string]interface{}{"status": "completed", "target": payload.Target}}
+	})
+
+	resp, body := api.do("POST", "/api/v1/intel/batch", []map[string]interface{}{
+		{"target": "dave"},
+		{"target": "broken"},
+		{"target": "erin"},
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d: %v", resp.StatusCode, body)
+	}
+	if body["successful"] != 2.0 || body["failed"] != 1.0 {
+		t.Errorf("successful %v failed %v, want 2 and 1", body["successful"], body["failed"])
+	}
+	results := body["operations"].([]interface{})
+	if results[1].(map[string]interface{})["error"] != "target rejected" {
+		t.Errorf("failed result %v", results[1])
+	}
+}
+
+func TestOperationLifecycle(t *testing.T) {
+	api := newTestAPI(t, nil)
+	api.orchestra.Script(protocol.TypeInvestigate, orchestratest.Response{
+		Payload: map[string]interface{}{"status": "completed", "risk_score": 0.4},
+		Delay:   300 * time.Millisecond,
+	})
+
+	resp, created := api.do("POST", "/api/v1/operations", map[string]interface{}{"target": "frank", "priority": "high"})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create: status %d, body %v", resp.StatusCode, created)
+	}
+	id := created["operation_id"].(string)
+
+	if _, ok := api.orchestra.WaitFor(orchestratest.EventReceived, protocol.TypeInvestigate, 2*time.Second); !ok {
+		t.Fatal("orchestra never received the investigation")
+	}
+	operation := api.waitForStatus(id, "completed")
+	results, _ := operation["results"].(map[string]interface{})
+	if results["risk_score"] != 0.4 || operation["progress"] != 100.0 {
+		t.Errorf("completed operation %v", operation)
+	}
+
+	_, list := api.do("GET", "/api/v1/operations?status=completed", nil)
+	if list["total"] != 1.0 {
+		t.Errorf("list total %v, want 1", list["total"])
+	}
+	_, stats := api.do("GET", "/api/v1/operations/stats", nil)
+	if stats["completed_operations"] != 1.0 || stats["success_rate"] != 100.0 {
+		t.Errorf("stats %v", stats)
+	}
+}
+
+func TestOperationCancel(t *testing.T) {
+	api := newTestAPI(t, nil)
+	api.orchestra.Script(protocol.TypeInvestigate, orchestratest.Response{
+		Payload: map[string]interface{}{"status": "completed"},
+		Delay:   time.Second,
+	})
+
+	_, created := api.do("POST", "/api/v1/operations", map[string]interface{}{"target": "grace"})
+	id := created["operation_id"].(string)
+	api.waitForStatus(id, "processing")
+
+	resp, body := api.do("DELETE", "/api/v1/operations/cancel?id="+id, nil)
+	if resp.StatusCode != http.StatusOK || body["status"] != "cancelled" {
+		t.Fatalf("cancel: status %d, body %v", resp.StatusCode, body)
+	}
+
+	// A late reply from orchestra does not resurrect the operation. Calls
+	// share one socket, so the next operation only completes once the
+	// cancelled one has had its reply.
+	api.orchestra.Script(protocol.TypeInvestigate, orchestratest.Response{
+		Payload: map[string]interface{}{"status": "completed"},
+	})
+	_, next := api.do("POST", "/api/v1/operations", map[string]interface{}{"target": "heidi"})
+	api.waitForStatus(next["operation_id"].(string), "completed")
+	if operation := api.waitForStatus(id, "cancelled"); operation["error"] != "Operation cancelled by user" {
+		t.Errorf("cancelled operation %v", operation)
+	}
+}
+
+func TestOperationRetry(t *testing.T) {
+	api := newTestAPI(t, func(cfg *config.Config) {
+		cfg.Orchestra.Timeout = 100 * time.Millisecond
+	})
+	api.orchestra.Script(protocol.TypeInvestigate,
+		orchestratest.Response{Drop: true},
+		orchestratest.Response{Payload: map[string]interface{}{"status": "completed"}},
+	)
+
+	// A timeout is temporary, so the intel request succeeds on the second attempt
+	resp, body := api.do("POST", "/api/v1/intel", map[string]interface{}{"target": "olivia"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, body %v", resp.StatusCode, body)
+	}
+	requests := api.orchestra.Requests(protocol.TypeInvestigate)
+	if len(requests) != 2 {
+		t.Fatalf("orchestra got %d investigate requests, want 2", len(requests))
+	}
+	var first, second protocol.InvestigateRequest
+	requests[0].DecodePayload(&first)
+	requests[1].DecodePayload(&second)
+	if first.OperationID != second.OperationID || first.Attempt != 1 || second.Attempt != 2 {
+		t.Errorf("attempts sent as %+v and %+v", first, second)
+	}
+
+	operation := api.waitForStatus(resp.Header.Get("X-Operation-ID"), "completed")
+	attempts := operation["attempts"].([]interface{})
+	if len(attempts) != 2 || attempts[0].(map[string]interface{})["temporary"] != true {
+		t.Errorf("attempt history %v", attempts)
+	}
+}
+
+func TestManualRetry(t *testing.T) {
+	api := newTestAPI(t, nil)
+	api.orchestra.Script(protocol.TypeInvestigate, orchestratest.Response{Error: "module crashed"})
+
+	_, created := api.do("POST", "/api/v1/operations", map[string]interface{}{"target": "peggy"})
+	id := created["operation_id"].(string)
+
+	// Error replies are final: no automatic retry
+	operation := api.waitForStatus(id, "failed")
+	if attempts := operation["attempts"].([]interface{}); len(attempts) != 1 {
+		t.Fatalf("failed operation has %d attempts, want 1", len(attempts))
+	}
+
+	resp, body := api.do("POST", "/api/v1/operations/"+id+"/retry", nil)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("retry: status %d, body %v", resp.StatusCode, body)
+	}
+	operation = api.waitForStatus(id, "completed")
+	attempts := operation["attempts"].([]interface{})
+	if len(attempts) != 2 || attempts[1].(map[string]interface{})["trigger"] != "manual" || operation["error"] != nil {
+		t.Errorf("retried operation %v", operation)
+	}
+
+	if resp, _ := api.do("POST", "/api/v1/operations/"+id+"/retry", nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("retry of a completed operation: status %d, want 409", resp.StatusCode)
+	}
+	if resp, _ := api.do("POST", "/api/v1/operations/op_missing/retry", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("retry of a missing operation: status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	api := newTestAPI(t, nil)
+	operation := map[string]interface{}{"target": "quentin", "priority": "low"}
+
+	resp, first := api.do("POST", "/api/v1/operations", operation, "Idempotency-Key", "create-quentin")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create: status %d, body %v", resp.StatusCode, first)
+	}
+
+	// The retried request replays the first response instead of creating another operation
+	resp, second := api.do("POST", "/api/v1/operations", operation, "Idempotency-Key", "create-quentin")
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("replay: status %d, headers %v", resp.StatusCode, resp.Header)
+	}
+	if second["operation_id"] != first["operation_id"] {
+		t.Errorf("replay created operation %v, first was %v", second["operation_id"], first["operation_id"])
+	}
+	api.waitForStatus(first["operation_id"].(string), "completed")
+	if _, list := api.do("GET", "/api/v1/operations", nil); list["total"] != 1.0 {
+		t.Errorf("%v operations after a replayed create, want 1", list["total"])
+	}
+
+	resp, body := api.do("POST", "/api/v1/operations", map[string]interface{}{"target": "rupert"}, "Idempotency-Key", "create-quentin")
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("key reused for another body: status %d, body %v", resp.StatusCode, body)
+	}
+
+	// Without a key every request is new
+	resp, _ = api.do("POST", "/api/v1/operations", operation)
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("Idempotent-Replayed") != "" {
+		t.Errorf("request without a key: status %d, headers %v", resp.StatusCode, resp.Header)
+	}
+}
+
+func TestListOperationsPaging(t *testing.T) {
+	api := newTestAPI(t, nil)
+
+	priorities := []string{"low", "critical", "medium", "high", "low"}
+	var created []string
+	for i, priority := range priorities {
+		tags := []string{"batch"}
+		if i%2 == 0 {
+			tags = append(tags, "Even")
+		}
+		_, body := api.do("POST", "/api/v1/operations", map[string]interface{}{
+			"target":   fmt.Sprintf("user%d@example.com", i),
+			"priority": priority,
+			"tags":     tags,
+		})
+		created = append(created, body["operation_id"].(string))
+		time.Sleep(2 * time.Millisecond) // distinct creation times
+	}
+
+	// Walk every page, newest first
+	var seen []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("pagination does not end")
+		}
+		_, page := api.do("GET", "/api/v1/operations?limit=2&cursor="+cursor, nil)
+		if page["total"] != 5.0 {
+			t.Fatalf("total %v, want 5", page["total"])
+		}
+		for _, op := range page["operations"].([]interface{}) {
+			seen = append(seen, op.(map[string]interface{})["id"].(string))
+		}
+		if cursor = page["next_cursor"].(string); cursor == "" {
+			break
+		}
+	}
+	if fmt.Sprint(seen) != fmt.Sprint([]string{created[4], created[3], created[2], created[1], created[0]}) {
+		t.Errorf("pages returned %v, created %v", seen, created)
+	}
+
+	_, byPriority := api.do("GET", "/api/v1/operations?sort=priority&limit=1", nil)
+	if first := byPriority["operations"].([]interface{})[0].(map[string]interface{}); first["priority"] != "critical" {
+		t.Errorf("highest priority first: got %v", first["priority"])
+	}
+
+	for query, want := range map[string]float64{
+		"tag=even":                  3,
+		"tag=even&tag=batch":        3,
+		"target=USER3":              1,
+		"target=example.com":        5,
+		"owner=primary":             5,
+		"owner=someone-else":        0,
+		"priority=low&tag=even":     2,
+		"created_before=2000-01-01": 0,
+	} {
+		if _, page := api.do("GET", "/api/v1/operations?"+query, nil); page["total"] != want {
+			t.Errorf("%s: total %v, want %v", query, page["total"], want)
+		}
+	}
+
+	for _, query := range []string{"cursor=bogus", "sort=target", "limit=0", "created_after=yesterday"} {
+		if resp, _ := api.do("GET", "/api/v1/operations?"+query, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, resp.StatusCode)
+		}
+	}
+}
+
+func TestCasesTagsAndNotes(t *testing.T) {
+	api := newTestAPI(t, nil)
+
+	resp, created := api.do("POST", "/api/v1/cases", map[string]interface{}{
+		"name":    "Phishing wave",
+		"members": []string{"primary", " analyst-2 ", "primary"},
+	})
+	if resp.StatusCode != http.StatusCreated || created["status"] != "open" {
+		t.Fatalf("create case: status %d, body %v", resp.StatusCode, created)
+	}
+	caseID := created["id"].(string)
+	if members := created["members"].([]interface{}); len(members) != 2 {
+		t.Errorf("members %v, want 2 normalized members", members)
+	}
+
+	var operations []string
+	for _, target := range []string{"sybil", "trent"} {
+		_, body := api.do("POST", "/api/v1/operations", map[string]interface{}{"target": target})
+		operations = append(operations, body["operation_id"].(string))
+	}
+
+	if resp, body := api.do("PUT", "/api/v1/operations/"+operations[0]+"/case", map[string]interface{}{"case_id": caseID}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("attach: status %d, body %v", resp.StatusCode, body)
+	}
+	if resp, _ := api.do("PUT", "/api/v1/operations/"+operations[1]+"/case", map[string]interface{}{"case_id": "case_missing"}); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("attach to a missing case: status %d, want 404", resp.StatusCode)
+	}
+	if _, body := api.do("GET", "/api/v1/cases/"+caseID, nil); body["operation_count"] != 1.0 {
+		t.Errorf("case operation_count %v, want 1", body["operation_count"])
+	}
+
+	if _, body := api.do("PUT", "/api/v1/operations/"+operations[1]+"/tags", map[string]interface{}{"tags": []string{"VIP", "vip", "phish"}}); len(body["tags"].([]interface{})) != 2 {
+		t.Errorf("tags %v, want [vip phish]", body["tags"])
+	}
+	resp, note := api.do("POST", "/api/v1/operations/"+operations[1]+"/notes", map[string]interface{}{"text": "Same avatar as the 2024 campaign"})
+	if resp.StatusCode != http.StatusCreated || note["author"] != "primary" {
+		t.Errorf("add note: status %d, body %v", resp.StatusCode, note)
+	}
+	if _, body := api.do("GET", "/api/v1/operations/"+operations[1]+"/notes", nil); body["total"] != 1.0 {
+		t.Errorf("notes %v", body)
+	}
+
+	for query, want := range map[string]string{
+		"case=" + caseID: operations[0],
+		"tag=vip":        operations[1],
+		"note=AVATAR":    operations[1],
+	} {
+		_, page := api.do("GET", "/api/v1/operations?"+query, nil)
+		list := page["operations"].([]interface{})
+		if len(list) != 1 || list[0].(map[string]interface{})["id"] != want {
+			t.Errorf("%s: got %v, want only %s", query, list, want)
+		}
+	}
+
+	if resp, body := api.do("PATCH", "/api/v1/cases/"+caseID, map[string]interface{}{"status": "closed"}); resp.StatusCode != http.StatusOK || body["name"] != "Phishing wave" {
+		t.Errorf("close case: status %d, body %v", resp.StatusCode, body)
+	}
+	if resp, _ := api.do("PATCH", "/api/v1/cases/"+caseID, map[string]interface{}{"status": "done"}); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid case status: status %d, want 400", resp.StatusCode)
+	}
+	if _, body := api.do("GET", "/api/v1/cases?status=closed&member=analyst-2", nil); body["total"] != 1.0 {
+		t.Errorf("case list filter: %v", body)
+	}
+
+	if _, body := api.do("DELETE", "/api/v1/cases/"+caseID, nil); body["detached_operations"] != 1.0 {
+		t.Errorf("delete case: %v", body)
+	}
+	if _, page := api.do("GET", "/api/v1/operations?case="+caseID, nil); page["total"] != 0.0 {
+		t.Errorf("operations still in a deleted case: %v", page["total"])
+	}
+}
+
+func TestSchedules(t *testing.T) {
+	api := newTestAPI(t, nil)
+	api.orchestra.Script(protocol.TypeInvestigate, orchestratest.Response{
+		Payload: map[string]interface{}{"status": "completed", "risk_score": 0.2},
+	})
+
+	for _, body := range []map[string]interface{}{
+		{"target": "victor"},
+		{"target": "victor", "cron": "61 * * * *"},
+		{"target": "victor", "cron": "0 * * * *", "run_at": time.Now()},
+		{"target": "victor", "cron": "0 * * * *", "timezone": "Mars/Olympus"},
+	} {
+		if resp, _ := api.do("POST", "/api/v1/schedules", body); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("create %v: status %d, want 400", body, resp.StatusCode)
+		}
+	}
+
+	// A recurring schedule runs at its next cron match and then moves on
+	resp, recurring := api.do("POST", "/api/v1/schedules", map[string]interface{}{
+		"name": "hourly victor", "target": "victor", "modules": []string{"email"}, "cron": "@hourly", "tags": []string{"Watch"},
+	})
+	if resp.StatusCode != http.StatusCreated || recurring["status"] != "active" {
+		t.Fatalf("create: status %d, body %v", resp.StatusCode, recurring)
+	}
+	recurringID := recurring["id"].(string)
+	nextRun, _ := time.Parse(time.RFC3339, recurring["next_run_at"].(string))
+	if nextRun.Minute() != 0 || !nextRun.After(time.Now()) {
+		t.Errorf("next_run_at %v, want the next full hour", nextRun)
+	}
+
+	if started := api.schedules.RunDue(time.Now()); started != 0 {
+		t.Errorf("%d runs started before any schedule was due", started)
+	}
+	if started := api.schedules.RunDue(nextRun); started != 1 {
+		t.Fatalf("%d runs started at next_run_at, want 1", started)
+	}
+	_, got := api.do("GET", "/api/v1/schedules/"+recurringID, nil)
+	schedule := got["schedule"].(map[string]interface{})
+	if schedule["runs"] != 1.0 || schedule["next_run_at"] == recurring["next_run_at"] || got["operation_count"] != 1.0 {
+		t.Errorf("after a run: %v", got)
+	}
+	operation := api.waitForStatus(schedule["last_operation_id"].(string), "completed")
+	if operation["schedule_id"] != recurringID || operation["target"] != "victor" || operation["owner"] != "primary" {
+		t.Errorf("scheduled operation %v", operation)
+	}
+	if _, page := api.do("GET", "/api/v1/operations?schedule="+recurringID, nil); page["total"] != 1.0 {
+		t.Errorf("operations?schedule= total %v, want 1", page["total"])
+	}
+
+	// Paused schedules do not run; resuming plans the next run again
+	if _, body := api.do("POST", "/api/v1/schedules/"+recurringID+"/pause", nil); body["status"] != "paused" || body["next_run_at"] != nil {
+		t.Errorf("pause: %v", body)
+	}
+	if started := api.schedules.RunDue(time.Now().Add(48 * time.Hour)); started != 0 {
+		t.Errorf("%d runs started while paused", started)
+	}
+	if _, body := api.do("POST", "/api/v1/schedules/"+recurringID+"/resume", nil); body["status"] != "active" || body["next_run_at"] == nil {
+		t.Errorf("resume: %v", body)
+	}
+
+	// A single run completes its schedule
+	_, once := api.do("POST", "/api/v1/schedules", map[string]interface{}{
+		"target": "wendy", "run_at": time.Now().Add(time.Minute), "priority": "high",
+	})
+	onceID := once["id"].(string)
+	if started := api.schedules.RunDue(time.Now().Add(2 * time.Minute)); started != 1 {
+		t.Fatalf("%d runs started, want only the single run", started)
+	}
+	_, got = api.do("GET", "/api/v1/schedules/"+onceID, nil)
+	if schedule := got["schedule"].(map[string]interface{}); schedule["status"] != "completed" || schedule["next_run_at"] != nil {
+		t.Errorf("single run schedule after running: %v", schedule)
+	}
+	if resp, _ := api.do("POST", "/api/v1/schedules/"+onceID+"/pause", nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("pause a completed schedule: status %d, want 409", resp.StatusCode)
+	}
+
+	if _, list := api.do("GET", "/api/v1/schedules?status=active", nil); list["total"] != 1.0 {
+		t.Errorf("active schedules: %v", list)
+	}
+	if resp, _ := api.do("DELETE", "/api/v1/schedules/"+recurringID, nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("delete: status %d", resp.StatusCode)
+	}
+	if resp, _ := api.do("GET", "/api/v1/schedules/"+recurringID, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("deleted schedule: status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestOperationDiff(t *testing.T) {
+	api := newTestAPI(t, nil)
+	report := func(risk float64, pages ...map[string]interface{}) orchestratest.Response {
+		return orchestratest.Response{Payload: map[string]interface{}{
+			"status":      "completed",
+			"scrapy":      map[string]interface{}{"findings": pages},
+			"correlation": map[string]interface{}{"risk_score": risk},
+		}}
+	}
+	api.orchestra.Script(protocol.TypeInvestigate,
+		report(0.4,
+			map[string]interface{}{"url": "https://github.com/mallory", "title": "mallory", "timestamp": "t1"},
+			map[string]interface{}{"url": "https://old.example/mallory", "title": "gone"},
+		),
+		report(0.65,
+			map[string]interface{}{"url": "https://github.com/mallory", "title": "Mallory M.", "timestamp": "t2"},
+			map[string]interface{}{"url": "https://gitlab.com/mallory", "title": "new"},
+		),
+	)
+
+	var runs []string
+	for _, target := range []string{"Mallory", "@mallory "} {
+		_, body := api.do("POST", "/api/v1/operations", map[string]interface{}{"target": target})
+		runs = append(runs, body["operation_id"].(string))
+		api.waitForStatus(runs[len(runs)-1], "completed")
+	}
+
+	// Without against, the latest earlier run of the target is used
+	for _, path := range []string{"/diff", "/diff?against=" + runs[0]} {
+		resp, diff := api.do("GET", "/api/v1/operations/"+runs[1]+path, nil)
+		if resp.StatusCode != http.StatusOK || diff["against"] != runs[0] {
+			t.Fatalf("%s: status %d, body %v", path, resp.StatusCode, diff)
+		}
+		added, removed, changed := diff["added"].([]interface{}), diff["removed"].([]interface{}), diff["changed"].([]interface{})
+		if len(added) != 1 || added[0].(map[string]interface{})["value"] != "https://gitlab.com/mallory" {
+			t.Errorf("%s: added %v", path, added)
+		}
+		if len(removed) != 1 || removed[0].(map[string]interface{})["value"] != "https://old.example/mallory" {
+			t.Errorf("%s: removed %v", path, removed)
+		}
+		if len(changed) != 1 || fmt.Sprint(changed[0].(map[string]interface{})["fields"]) != "[title]" {
+			t.Errorf("%s: changed %v, want only the title of the github page", path, changed)
+		}
+		if risk := diff["risk_score"].(map[string]interface{}); risk["delta"] != 0.25 {
+			t.Errorf("%s: risk_score %v", path, risk)
+		}
+	}
+
+	if resp, _ := api.do("GET", "/api/v1/operations/"+runs[0]+"/diff", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("first run without an earlier one: status %d, want 404", resp.StatusCode)
+	}
+	_, other := api.do("POST", "/api/v1/operations", map[string]interface{}{"target": "trudy"})
+	api.waitForStatus(other["operation_id"].(string), "completed")
+	if resp, _ := api.do("GET", "/api/v1/operations/"+runs[1]+"/diff?against="+other["operation_id"].(string), nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("different targets: status %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestWatchlistAlerts(t *testing.T) {
+	webhook, slack := make(chan map[string]interface{}, 10), make(chan map[string]interface{}, 10)
+	receiver := func(received chan map[string]interface{}) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			received <- body
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	webhookServer, slackServer := receiver(webhook), receiver(slack)
+
+	api := newTestAPI(t, func(cfg *config.Config) {
+		cfg.Watchlists.WebhookURL = webhookServer.URL
+		cfg.Watchlists.SlackWebhookURL = slackServer.URL
+	})
+	report := func(risk float64, found ...string) orchestratest.Response {
+		return orchestratest.Response{Payload: map[string]interface{}{
+			"status":      "completed",
+			"findings":    found,
+			"correlation": map[string]interface{}{"risk_score": risk},
+		}}
+	}
+	api.orchestra.Script(protocol.TypeInvestigate,
+		report(0.3, "paste:oscar"),                // baseline
+		report(0.3, "paste:oscar", "breach:2024"), // new finding
+		report(0.8, "paste:oscar", "breach:2024"), // crosses the threshold
+		report(0.9, "paste:oscar", "breach:2024"), // still above it
+	)
+
+	if resp, _ := api.do("POST", "/api/v1/watchlists", map[string]interface{}{"name": "x", "notifiers": []string{"smtp"}}); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unconfigured notifier: status %d, want 400", resp.StatusCode)
+	}
+	resp, created := api.do("POST", "/api/v1/watchlists", map[string]interface{}{
+		"name": "VIPs", "team": "red", "targets": []string{"@Oscar", "oscar", "peggy"}, "risk_threshold": 0.5,
+	})
+	if resp.StatusCode != http.StatusCreated || len(created["targets"].([]interface{})) != 2 || created["owner"] != "primary" {
+		t.Fatalf("create: status %d, body %v", resp.StatusCode, created)
+	}
+	watchlistID := created["id"].(string)
+
+	alerts := func(want int) []interface{} {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			_, body := api.do("GET", "/api/v1/alerts?watchlist="+watchlistID, nil)
+			list := body["alerts"].([]interface{})
+			if len(list) == want {
+				return list
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%d alerts, want %d: %v", len(list), want, list)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	// investigate waits until the watchlist has evaluated the operation
+	investigate := func() {
+		t.Helper()
+		_, body := api.do("POST", "/api/v1/operations", map[string]interface{}{"target": "Oscar"})
+		id := body["operation_id"].(string)
+		api.waitForStatus(id, "completed")
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			_, got := api.do("GET", "/api/v1/watchlists/"+watchlistID, nil)
+			for _, watched := range got["watchlist"].(map[string]interface{})["targets"].([]interface{}) {
+				if watched.(map[string]interface{})["last_operation_id"] == id {
+					return
+				}
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("operation %s not evaluated: %v", id, got)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	investigate()
+	alerts(0)
+
+	investigate()
+	alert := alerts(1)[0].(map[string]interface{})
+	found := alert["new_findings"].([]interface{})
+	if fmt.Sprint(alert["reasons"]) != "[new_findings]" || len(found) != 1 || found[0].(map[string]interface{})["value"] != "breach:2024" {
+		t.Errorf("new findings alert %v", alert)
+	}
+	select {
+	case body := <-webhook:
+		if body["event"] != "watchlist.alert" || body["alert"].(map[string]interface{})["id"] != alert["id"] {
+			t.Errorf("webhook got %v", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+	select {
+	case body := <-slack:
+		if text, _ := body["text"].(string); !strings.Contains(text, "breach:2024") || !strings.Contains(text, "VIPs") {
+			t.Errorf("slack got %v", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("slack webhook not called")
+	}
+
+	investigate()
+	alert = alerts(2)[0].(map[string]interface{})
+	if fmt.Sprint(alert["reasons"]) != "[risk_threshold]" || alert["previous_risk_score"] != 0.3 || alert["risk_score"] != 0.8 {
+		t.Errorf("risk threshold alert %v", alert)
+	}
+	investigate()
+	alerts(2)
+
+	// Deliveries are recorded on the alert
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, got := api.do("GET", "/api/v1/alerts/"+alert["id"].(string), nil)
+		if deliveries, _ := got["deliveries"].([]interface{}); len(deliveries) == 2 {
+			if deliveries[0].(map[string]interface{})["status"] != "sent" {
+				t.Errorf("deliveries %v", deliveries)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("deliveries not recorded: %v", got)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if _, body := api.do("POST", "/api/v1/alerts/"+alert["id"].(string)+"/ack", nil); body["acknowledged_by"] != "primary" {
+		t.Errorf("ack: %v", body)
+	}
+	if _, body := api.do("GET", "/api/v1/alerts?acknowledged=false&team=red", nil); body["total"] != 1.0 {
+		t.Errorf("unacknowledged alerts: %v", body)
+	}
+	_, got := api.do("GET", "/api/v1/watchlists/"+watchlistID, nil)
+	if got["alert_count"] != 2.0 || got["unacknowledged_count"] != 1.0 {
+		t.Errorf("watchlist counts: %v", got)
+	}
+	if _, body := api.do("GET", "/api/v1/watchlists?target=OSCAR", nil); body["total"] != 1.0 {
+		t.Errorf("watchlists by target: %v", body)
+	}
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	api := newTestAPI(t, nil)
+
+	for _, path := range []string{"/api/v1/live", "/api/v1/ready", "/api/v1/health"} {
+		if resp, body := api.do("GET", path, nil); resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: status %d, body %v", path, resp.StatusCode, body)
+		}
+	}
+
+	_, version := api.do("GET", "/api/v1/version", nil)
+	peers, _ := version["peers"].([]interface{})
+	if len(peers) == 0 || peers[0].(map[string]interface{})["compatible"] != true {
+		t.Errorf("version peers %v", version["peers"])
+	}
+
+	// Orchestra stops answering pings: not ready, but still alive
+	api.orchestra.Handle(protocol.TypePing, func(*protocol.Envelope) orchestratest.Response {
+		return orchestratest.Response{Drop: true}
+	})
+	api.monitor.Refresh(context.Background())
+
+	if resp, body := api.do("GET", "/api/v1/ready", nil); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("ready with orchestra down: status %d, body %v", resp.StatusCode, body)
+	}
+	if resp, _ := api.do("GET", "/api/v1/health", nil); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("health with orchestra down: status %d", resp.StatusCode)
+	}
+	if resp, _ := api.do("GET", "/api/v1/live", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("live with orchestra down: status %d", resp.StatusCode)
+	}
+}
+
+func TestRequiresAPIKey(t *testing.T) {
+	api := newTestAPI(t, nil)
+
+	resp, err := http.Post(api.url+"/api/v1/intel", "application/json", strings.NewReader(`{"target":"heidi"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status %d, want 401", resp.StatusCode)
+	}
+	if n := len(api.orchestra.Requests(protocol.TypeInvestigate)); n != 0 {
+		t.Errorf("orchestra got %d requests from an unauthenticated caller", n)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	api := newTestAPI(t, nil)
+	unmatched := metrics.HTTPRequests.WithLabelValues("unmatched", "GET", "404")
+	before := testutil.ToFloat64(unmatched)
+
+	// Metrics are scraped from their own listener, not the API port
+	if resp, _ := api.do("GET", "/metrics", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("/metrics on the API port: status %d, want 404", resp.StatusCode)
+	}
+	if got := testutil.ToFloat64(unmatched) - before; got != 1 {
+		t.Errorf("counted %v unmatched requests, want 1", got)
+	}
+
+	scrape := httptest.NewServer(newMetricsRouter())
+	defer scrape.Close()
+	resp, err := http.Get(scrape.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body bytes.Buffer
+	body.ReadFrom(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body.String(), "osint_api_http_requests_total") {
+		t.Errorf("scrape: status %d", resp.StatusCode)
+	}
+}
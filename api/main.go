@@ -1,63 +1,469 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"osint-api/cache"
+	"osint-api/certs"
+	"osint-api/config"
 	"osint-api/handlers"
 	"osint-api/handlers/middleware"
-
-	"github.com/gorilla/mux"
-	zmq "github.com/pebbe/zmq4"
+	"osint-api/health"
+	"osint-api/idempotency"
+	"osint-api/metrics"
+	"osint-api/notify"
+	"osint-api/orchestra"
+	"osint-api/scanner"
+	"osint-api/sites"
+	"osint-api/store"
+	"osint-api/tracing"
+	"osint-api/version"
 )
 
 func main() {
-	// Initialize ZMQ socket
-	zmqSocket, err := zmq.NewSocket(zmq.REQ)
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "optional YAML config file")
+	envFile := flag.String("env-file", ".env", "dotenv file read before the environment; skipped if missing")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	flag.Parse()
+
+	cfg, err := config.Load(config.Options{File: *configFile, EnvFiles: []string{*envFile}})
 	if err != nil {
-		log.Fatalf("Failed to create ZMQ socket: %v", err)
+		log.Fatalf("Failed to load configuration: %v", err)
 	}
-	defer zmqSocket.Close()
+	if *printConfig {
+		if err := cfg.Dump(os.Stdout); err != nil {
+			log.Fatalf("Failed to print configuration: %v", err)
+		}
+		return
+	}
+
+	// Structured JSON logs; the standard logger is routed through slog too
+	slog.SetDefault(newLogger(cfg.LogLevel))
 
-	orchestraAddr := os.Getenv("ORCHESTRA_ADDR")
-	if orchestraAddr == "" {
-		orchestraAddr = "tcp://localhost:5558"
+	// Cancelled on SIGINT/SIGTERM; stops background work and starts shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Options{
+		Exporter:     cfg.Tracing.Exporter,
+		OTLPEndpoint: cfg.Tracing.OTLPEndpoint,
+		OTLPHeaders:  cfg.Tracing.OTLPHeaders,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
 	}
 
-	if err := zmqSocket.Connect(orchestraAddr); err != nil {
+	// Connect to orchestra, with CURVE when keys are configured; the socket is
+	// closed by the client on shutdown
+	orchestraCurve, err := newOrchestraCurve(cfg.Orchestra)
+	if err != nil {
+		log.Fatalf("Failed to load orchestra CURVE keys: %v", err)
+	}
+	if orchestraCurve == nil {
+		log.Printf("⚠️ Orchestra link is not encrypted; set ORCHESTRA_CURVE_SERVER_KEY to enable CURVE")
+	}
+	orchestraBreaker := orchestra.NewBreaker(orchestra.BreakerSettings{
+		Failures: cfg.Orchestra.BreakerFailures,
+		Cooldown: cfg.Orchestra.BreakerCooldown,
+		Probes:   cfg.Orchestra.BreakerProbes,
+	})
+	orchestraClient, err := orchestra.Dial(cfg.Orchestra.Addr, cfg.Orchestra.Timeout, orchestraCurve, orchestraBreaker)
+	if err != nil {
 		log.Fatalf("Failed to connect to orchestra: %v", err)
 	}
 
+	// Initialize result cache
+	resultCache, err := newResultCache(cfg.Cache)
+	if err != nil {
+		log.Fatalf("Failed to initialize result cache: %v", err)
+	}
+
 	// Initialize handlers
-	intelHandler := &handlers.IntelHandler{ZmqSocket: zmqSocket}
-	healthHandler := &handlers.HealthHandler{ZmqSocket: zmqSocket}
-	opsHandler := &handlers.OpsHandler{}
-
-	// Setup router
-	router := mux.NewRouter()
-
-	// Apply middleware
-	router.Use(middleware.LoggingMiddleware)
-	router.Use(middleware.CORSMiddleware)
-	router.Use(middleware.AuthMiddleware)
-
-	// API routes
-	api := router.PathPrefix("/api/v1").Subrouter()
-	api.HandleFunc("/intel", intelHandler.HandleIntelRequest).Methods("POST")
-	api.HandleFunc("/intel/batch", intelHandler.HandleBatchIntelRequest).Methods("POST")
-	api.HandleFunc("/health", healthHandler.HealthCheck).Methods("GET")
-	api.HandleFunc("/ready", healthHandler.ReadyCheck).Methods("GET")
-	api.HandleFunc("/stats", healthHandler.StatsHandler).Methods("GET")
-	api.HandleFunc("/operations", opsHandler.ListOperations).Methods("GET")
-	api.HandleFunc("/operations/status", opsHandler.GetOperationStatus).Methods("GET")
+	opsHandler := handlers.NewOpsHandler(orchestraClient)
+	opsHandler.Retry = handlers.RetryPolicy{
+		MaxAttempts: cfg.Operations.MaxAttempts,
+		Backoff:     cfg.Operations.RetryBackoff,
+		MaxBackoff:  cfg.Operations.RetryMaxBackoff,
+	}
+	opsHandler.HashedTargetSearch = cfg.Operations.TargetSearch == "hashed"
+
+	siteRegistry, err := newSiteRegistry(ctx, cfg.Sites)
+	if err != nil {
+		log.Printf("⚠️ Username scanner module disabled: %v", err)
+	} else {
+		opsHandler.Modules[scanner.ModuleName] = newScannerModule(cfg.Scanner, siteRegistry)
+	}
+
+	// Watchlists, checked as operations complete; set before
+	// interrupted operations resume
+	watchlistHandler, err := newWatchlistHandler(cfg.Watchlists)
+	if err != nil {
+		log.Fatalf("Failed to set up watchlists: %v", err)
+	}
+	opsHandler.OnComplete = watchlistHandler.Observe
+
+	// Persist operations and resume the ones interrupted by the last shutdown
+	if cfg.Operations.Dir != "" {
+		operationStore, err := store.Open(cfg.Operations.Dir)
+		if err != nil {
+			log.Fatalf("Failed to open operation store: %v", err)
+		}
+		opsHandler.Store = operationStore
+		requeued, err := opsHandler.Restore()
+		if err != nil {
+			log.Fatalf("Failed to restore operations: %v", err)
+		}
+		if requeued > 0 {
+			log.Printf("🔄 Resumed %d interrupted operations", requeued)
+		}
+	}
+
+	// Cases grouping operations, persisted next to them when configured
+	caseHandler := handlers.NewCaseHandler(opsHandler)
+	if cfg.Cases.Dir != "" {
+		caseStore, err := store.Open(cfg.Cases.Dir)
+		if err != nil {
+			log.Fatalf("Failed to open case store: %v", err)
+		}
+		caseHandler.Store = caseStore
+		if _, err := caseHandler.Restore(); err != nil {
+			log.Fatalf("Failed to restore cases: %v", err)
+		}
+	}
+
+	// Scheduled investigations, checked for due runs every interval
+	scheduleHandler := handlers.NewScheduleHandler(opsHandler)
+	if cfg.Schedules.Dir != "" {
+		scheduleStore, err := store.Open(cfg.Schedules.Dir)
+		if err != nil {
+			log.Fatalf("Failed to open schedule store: %v", err)
+		}
+		scheduleHandler.Store = scheduleStore
+		if _, err := scheduleHandler.Restore(); err != nil {
+			log.Fatalf("Failed to restore schedules: %v", err)
+		}
+	}
+	go scheduleHandler.Watch(ctx, cfg.Schedules.Interval)
+
+	// Responses replayed for repeated Idempotency-Keys
+	idempotencyStore, err := newIdempotencyStore(cfg.Idempotency)
+	if err != nil {
+		log.Fatalf("Failed to open idempotency store: %v", err)
+	}
+	if idempotencyStore != nil {
+		go idempotencyStore.Watch(ctx, time.Minute)
+	}
+
+	// HTTPS certificates, reloaded when rotated on disk
+	var certStore *certs.Store
+	if cfg.TLS.Enabled() {
+		certStore, err = certs.Load(cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.ClientCAFile)
+		if err != nil {
+			log.Fatalf("Failed to load TLS certificate: %v", err)
+		}
+		go certStore.Watch(ctx, cfg.TLS.ReloadInterval)
+	}
+
+	intelHandler := &handlers.IntelHandler{Ops: opsHandler, Cache: resultCache}
+	healthMonitor := newHealthMonitor(ctx, cfg, orchestraCurve, orchestraBreaker, siteRegistry, certStore)
+	healthHandler := &handlers.HealthHandler{Monitor: healthMonitor}
+	cacheHandler := &handlers.CacheHandler{Cache: resultCache}
+
+	// Export metrics
+	metrics.RegisterOperations(opsHandler)
+	metrics.RegisterOrchestraCircuit(func() int { return int(orchestraBreaker.State()) })
+	if resultCache != nil {
+		metrics.RegisterCache(resultCache)
+	}
+
+	var sitesHandler *handlers.SitesHandler
+	if siteRegistry != nil {
+		sitesHandler = &handlers.SitesHandler{Registry: siteRegistry}
+	}
+	router := newRouter(cfg, services{
+		intel:      intelHandler,
+		ops:        opsHandler,
+		cases:      caseHandler,
+		schedules:  scheduleHandler,
+		watchlists: watchlistHandler,
+		health:     healthHandler,
+		cache:      cacheHandler,
+		sites:      sitesHandler,
+
+		idempotency: idempotencyStore,
+	})
 
 	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	server := &http.Server{Addr: cfg.Server.Addr(), Handler: router}
+	serverErr := make(chan error, 3)
+	scheme := "http"
+	if certStore != nil {
+		clientAuth, err := certs.ClientAuthType(cfg.TLS.ClientAuth)
+		if err != nil {
+			log.Fatalf("Invalid TLS client auth: %v", err)
+		}
+		server.TLSConfig = certStore.TLSConfig(clientAuth)
+		scheme = "https"
+	}
+	go func() {
+		if certStore != nil {
+			serverErr <- server.ListenAndServeTLS("", "")
+		} else {
+			serverErr <- server.ListenAndServe()
+		}
+	}()
+	log.Printf("🌐 OSINT API %s (%s) starting on %s://%s", version.Version, version.Commit, scheme, server.Addr)
+
+	// Prometheus scrapes a separate plain HTTP listener
+	var metricsServer *http.Server
+	if cfg.Server.MetricsPort != 0 {
+		metricsServer = &http.Server{Addr: cfg.Server.MetricsAddr(), Handler: newMetricsRouter()}
+		go func() {
+			serverErr <- metricsServer.ListenAndServe()
+		}()
+		log.Printf("📈 Metrics on http://%s/metrics", metricsServer.Addr)
+	}
+
+	// Optional plain HTTP listener that only redirects to HTTPS
+	var redirectServer *http.Server
+	if cfg.TLS.RedirectAddr != "" {
+		redirectServer = &http.Server{Addr: cfg.TLS.RedirectAddr, Handler: newHTTPSRedirect(cfg.Server.Port)}
+		go func() {
+			serverErr <- redirectServer.ListenAndServe()
+		}()
+		log.Printf("🔀 Redirecting http://%s to HTTPS", cfg.TLS.RedirectAddr)
+	}
+
+	select {
+	case err := <-serverErr:
+		log.Fatalf("Server failed: %v", err)
+	case <-ctx.Done():
+	}
+	stop() // a second signal kills the process immediately
+
+	log.Printf("🛑 Shutting down, deadline %s", cfg.Server.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	// Stop accepting connections and drain in-flight requests. Requests
+	// waiting on operations finish once their operation does or is re-queued.
+	serverDone := make(chan error, 1)
+	go func() {
+		serverDone <- server.Shutdown(shutdownCtx)
+	}()
+	if redirectServer != nil {
+		redirectServer.Shutdown(shutdownCtx)
+	}
+	if metricsServer != nil {
+		metricsServer.Shutdown(shutdownCtx)
+	}
+
+	// Operations get most of the deadline; the rest lets waiting handlers
+	// answer before the server deadline
+	opsCtx, cancelOps := context.WithTimeout(shutdownCtx, cfg.Server.ShutdownTimeout*4/5)
+	if stopped := opsHandler.Shutdown(opsCtx); stopped > 0 {
+		log.Printf("⚠️ %d operations did not finish before the deadline", stopped)
 	}
+	cancelOps()
+
+	if err := <-serverDone; err != nil {
+		log.Printf("⚠️ HTTP server did not drain cleanly: %v", err)
+	}
+	if err := orchestraClient.Close(shutdownCtx); err != nil {
+		log.Printf("⚠️ Orchestra socket not closed: %v", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("⚠️ Failed to flush traces: %v", err)
+	}
+	log.Printf("👋 Shutdown complete")
+}
+
+// newSiteRegistry loads the shared muscle site definitions and watches the
+// file for changes
+func newSiteRegistry(ctx context.Context, cfg config.SitesConfig) (*sites.Registry, error) {
+	registry, err := sites.Load(cfg.File)
+	if err != nil {
+		return nil, err
+	}
+	go registry.Watch(ctx, cfg.ReloadInterval)
+
+	return registry, nil
+}
+
+// newHealthMonitor registers dependency checks and runs them in the
+// background, starting immediately. Orchestra is critical for readiness; the
+// rest, including the version handshake and the circuit breaker, only
+// degrade health.
+func newHealthMonitor(ctx context.Context, cfg *config.Config, curve *orchestra.Curve, breaker *orchestra.Breaker, registry *sites.Registry, certStore *certs.Store) *health.Monitor {
+	monitor := health.NewMonitor(cfg.Health.Timeout)
+	monitor.Register(health.ZMQPing("orchestra", cfg.Orchestra.Addr, curve), true)
+	monitor.Register(health.Func("orchestra_version", func(ctx context.Context) error {
+		peer, err := orchestra.Handshake(ctx, cfg.Orchestra.Addr, curve)
+		if err != nil {
+			return err
+		}
+		return version.RecordPeer(peer).Check()
+	}), false)
+	if breaker != nil {
+		monitor.Register(health.CircuitBreaker("orchestra_circuit", breaker), false)
+	}
+	if cfg.Brain.URL != "" {
+		monitor.Register(health.ZMQPing("brain", cfg.Brain.URL, nil), false)
+	}
+	if cfg.Cache.Dir != "" {
+		monitor.Register(health.DirWritable("cache_storage", cfg.Cache.Dir), false)
+	}
+	if cfg.Operations.Dir != "" {
+		monitor.Register(health.DirWritable("operation_storage", cfg.Operations.Dir), true)
+	}
+	if registry != nil {
+		monitor.Register(health.FileReadable("site_definitions", registry.Path()), false)
+	}
+	if certStore != nil {
+		monitor.Register(health.Func("tls_certificate", func(context.Context) error {
+			return certStore.CheckExpiry(certExpiryWarning)
+		}), false)
+	}
+
+	go monitor.Watch(ctx, cfg.Health.Interval)
+
+	return monitor
+}
+
+// newOrchestraCurve resolves the CURVE keys for the orchestra link from
+// inline values or certificate files. It returns nil when CURVE is off.
+func newOrchestraCurve(cfg config.OrchestraConfig) (*orchestra.Curve, error) {
+	if !cfg.CurveEnabled() {
+		return nil, nil
+	}
+
+	curve := &orchestra.Curve{ServerPublic: cfg.CurveServerKey, Public: cfg.CurvePublicKey, Secret: cfg.CurveSecretKey}
+	if cfg.CurveServerKeyFile != "" {
+		public, _, err := orchestra.ReadCertificate(cfg.CurveServerKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		curve.ServerPublic = public
+	}
+	if cfg.CurveKeyFile != "" {
+		public, secret, err := orchestra.ReadCertificate(cfg.CurveKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if secret == "" {
+			return nil, fmt.Errorf("%s has no secret-key; use the .key_secret file", cfg.CurveKeyFile)
+		}
+		curve.Public, curve.Secret = public, secret
+	}
+	return curve, nil
+}
+
+// certExpiryWarning is how long before expiry the TLS certificate starts
+// degrading health
+const certExpiryWarning = 14 * 24 * time.Hour
+
+// newHTTPSRedirect redirects every request to the same URL over HTTPS on
+// port
+func newHTTPSRedirect(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// newScannerModule builds the in-process username scanner over the site
+// registry
+func newScannerModule(cfg config.ScannerConfig, registry *sites.Registry) *scanner.Module {
+	return &scanner.Module{
+		Scanner:     scanner.New(nil, cfg.Concurrency),
+		Sites:       registry.Enabled,
+		IncludeNSFW: cfg.IncludeNSFW,
+	}
+}
+
+// newResultCache builds the investigation result cache. A capacity of 0
+// disables caching entirely.
+func newResultCache(cfg config.CacheConfig) (*cache.Cache, error) {
+	if cfg.Capacity == 0 {
+		return nil, nil
+	}
+	return cache.New(cache.Options{Capacity: cfg.Capacity, TTL: cfg.TTL, Dir: cfg.Dir})
+}
+
+// newWatchlistHandler builds the watchlist handler with the configured
+// notifiers, restoring watchlists and alerts when they are persisted
+func newWatchlistHandler(cfg config.WatchlistsConfig) (*handlers.WatchlistHandler, error) {
+	h := handlers.NewWatchlistHandler()
+	h.Timeout = cfg.NotifyTimeout
+	h.Notifiers = make(map[string]notify.Notifier)
+	if cfg.WebhookURL != "" {
+		h.Notifiers["webhook"] = &notify.Webhook{URL: cfg.WebhookURL}
+	}
+	if cfg.SlackWebhookURL != "" {
+		h.Notifiers["slack"] = &notify.Slack{URL: cfg.SlackWebhookURL}
+	}
+	if cfg.SMTPAddr != "" {
+		var to []string
+		for _, address := range strings.Split(cfg.SMTPTo, ",") {
+			if address = strings.TrimSpace(address); address != "" {
+				to = append(to, address)
+			}
+		}
+		h.Notifiers["smtp"] = &notify.SMTP{Addr: cfg.SMTPAddr, From: cfg.SMTPFrom, To: to}
+	}
+
+	if cfg.Dir == "" {
+		return h, nil
+	}
+	var err error
+	if h.Store, err = store.Open(filepath.Join(cfg.Dir, "watchlists")); err != nil {
+		return nil, err
+	}
+	if h.AlertStore, err = store.Open(filepath.Join(cfg.Dir, "alerts")); err != nil {
+		return nil, err
+	}
+	if _, _, err := h.Restore(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// newIdempotencyStore builds the Idempotency-Key store, persisted when a
+// directory is configured. A TTL of 0 disables it.
+func newIdempotencyStore(cfg config.IdempotencyConfig) (*idempotency.Store, error) {
+	if cfg.TTL == 0 {
+		return nil, nil
+	}
+	var disk *store.Collection
+	if cfg.Dir != "" {
+		var err error
+		if disk, err = store.Open(cfg.Dir); err != nil {
+			return nil, err
+		}
+	}
+	return idempotency.New(cfg.TTL, cfg.Capacity, disk)
+}
 
-	log.Printf("🌐 OSINT API server starting on :%s", port)
-	log.Fatal(http.ListenAndServe(":"+port, router))
+// newLogger builds the JSON logger at the given level
+func newLogger(level string) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: middleware.ParseLogLevel(level)}))
 }
@@ -0,0 +1,180 @@
+// Package metrics defines the Prometheus metrics exported by the API at
+// /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"osint-api/cache"
+)
+
+const namespace = "osint_api"
+
+var (
+	// HTTPRequests counts handled requests by route template, method and status
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by route template, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	// HTTPDuration observes request latency by route template and method
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by route template and method.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"route", "method"})
+
+	// OrchestraDuration observes orchestra round trips by action
+	OrchestraDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "orchestra_request_duration_seconds",
+		Help:      "Orchestra request/reply round-trip latency, by action.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"action"})
+
+	// OrchestraErrors counts failed orchestra round trips by action and stage
+	OrchestraErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orchestra_errors_total",
+		Help:      "Failed orchestra round trips, by action and failing stage (encode, send, receive, decode, correlation, remote).",
+	}, []string{"action", "stage"})
+
+	// OrchestraCircuitTransitions counts circuit breaker state changes
+	OrchestraCircuitTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orchestra_circuit_transitions_total",
+		Help:      "Orchestra circuit breaker state changes, by new state (closed, half-open, open).",
+	}, []string{"state"})
+
+	// OrchestraCircuitRejections counts calls failed fast by the circuit breaker
+	OrchestraCircuitRejections = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orchestra_circuit_rejections_total",
+		Help:      "Orchestra calls failed fast because the circuit breaker was open.",
+	})
+
+	// OperationRetries counts operation retries by trigger
+	OperationRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "operation_retries_total",
+		Help:      "Operation retries, by trigger (retry after a temporary failure, manual).",
+	}, []string{"trigger"})
+
+	// WatchlistAlerts counts watchlist alerts raised by reason
+	WatchlistAlerts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "watchlist_alerts_total",
+		Help:      "Watchlist alerts raised, by reason (new_findings, risk_threshold).",
+	}, []string{"reason"})
+
+	// AlertNotifications counts alert deliveries by notifier and result
+	AlertNotifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alert_notifications_total",
+		Help:      "Alert notifications, by notifier (webhook, slack, smtp) and result (sent, failed).",
+	}, []string{"notifier", "result"})
+
+	// AuthFailures counts rejected requests by reason
+	AuthFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_failures_total",
+		Help:      "Requests rejected by authentication, by reason (missing, malformed, invalid, forbidden).",
+	}, []string{"reason"})
+)
+
+// OperationCount is the number of operations with a status and priority
+type OperationCount struct {
+	Status   string
+	Priority string
+	Count    int
+}
+
+// OperationSource reports the current operation population
+type OperationSource interface {
+	CountOperations() []OperationCount
+}
+
+// RegisterOperations exports gauges of operations by status and priority,
+// and of the pending queue depth, read from source on every scrape
+func RegisterOperations(source OperationSource) {
+	prometheus.MustRegister(&operationCollector{source: source})
+}
+
+var (
+	operationsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "operations"),
+		"Operations currently held, by status and priority.",
+		[]string{"status", "priority"}, nil,
+	)
+	queueDepthDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "operation_queue_depth"),
+		"Operations waiting to start.",
+		nil, nil,
+	)
+)
+
+type operationCollector struct {
+	source OperationSource
+}
+
+func (c *operationCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- operationsDesc
+	ch <- queueDepthDesc
+}
+
+func (c *operationCollector) Collect(ch chan<- prometheus.Metric) {
+	pending := 0
+	for _, count := range c.source.CountOperations() {
+		ch <- prometheus.MustNewConstMetric(operationsDesc, prometheus.GaugeValue, float64(count.Count), count.Status, count.Priority)
+		if count.Status == "pending" {
+			pending += count.Count
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(pending))
+}
+
+// RegisterOrchestraCircuit exports the orchestra circuit breaker state, read
+// from state on every scrape: 0 closed, 1 half-open, 2 open
+func RegisterOrchestraCircuit(state func() int) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "orchestra_circuit_state",
+		Help:      "Orchestra circuit breaker state: 0 closed, 1 half-open, 2 open.",
+	}, func() float64 { return float64(state()) })
+}
+
+// RegisterCache exports result cache hits, misses, hit ratio and size
+func RegisterCache(c *cache.Cache) {
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_hits_total",
+		Help:      "Result cache lookups served from the cache.",
+	}, func() float64 { return float64(c.Stats().Hits) })
+
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_misses_total",
+		Help:      "Result cache lookups that found no usable entry.",
+	}, func() float64 { return float64(c.Stats().Misses) })
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cache_hit_ratio",
+		Help:      "Fraction of result cache lookups served from the cache since startup.",
+	}, func() float64 {
+		stats := c.Stats()
+		if total := stats.Hits + stats.Misses; total > 0 {
+			return float64(stats.Hits) / float64(total)
+		}
+		return 0
+	})
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cache_entries",
+		Help:      "Entries held in the in-memory result cache.",
+	}, func() float64 { return float64(c.Stats().Entries) })
+}
@@ -0,0 +1,154 @@
+// Package notify delivers alerts to webhooks, Slack-compatible incoming
+// webhooks and a local SMTP relay.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Message is an alert rendered for delivery
+type Message struct {
+	Subject string      // one line summary
+	Text    string      // plain text body
+	Payload interface{} // structured alert, posted as JSON by Webhook
+}
+
+// Notifier delivers messages to one destination
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, msg Message) error
+}
+
+// Webhook posts the message payload as JSON to a URL
+type Webhook struct {
+	URL    string
+	Client *http.Client // nil uses http.DefaultClient
+}
+
+// Name implements Notifier
+func (w *Webhook) Name() string { return "webhook" }
+
+// Notify implements Notifier
+func (w *Webhook) Notify(ctx context.Context, msg Message) error {
+	return postJSON(ctx, w.Client, w.URL, msg.Payload)
+}
+
+// Slack posts the message text to a Slack-compatible incoming webhook
+type Slack struct {
+	URL    string
+	Client *http.Client // nil uses http.DefaultClient
+}
+
+// Name implements Notifier
+func (s *Slack) Name() string { return "slack" }
+
+// Notify implements Notifier
+func (s *Slack) Notify(ctx context.Context, msg Message) error {
+	return postJSON(ctx, s.Client, s.URL, map[string]string{"text": "*" + msg.Subject + "*\n" + msg.Text})
+}
+
+// SMTP mails the message through a relay that needs no authentication or
+// TLS, such as a local MTA
+type SMTP struct {
+	Addr string // host:port
+	From string
+	To   []string
+}
+
+// Name implements Notifier
+func (m *SMTP) Name() string { return "smtp" }
+
+// Notify implements Notifier
+func (m *SMTP) Notify(ctx context.Context, msg Message) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.Addr)
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	host, _, _ := net.SplitHostPort(m.Addr)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	defer client.Close()
+
+	if err := client.Mail(m.From); err != nil {
+		return fmt.Errorf("smtp: MAIL FROM: %w", err)
+	}
+	for _, to := range m.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("smtp: RCPT TO %s: %w", to, err)
+		}
+	}
+
+	body, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp: DATA: %w", err)
+	}
+	if _, err := body.Write(m.render(msg)); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	if err := body.Close(); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	return client.Quit()
+}
+
+// render builds the mail headers and body
+func (m *SMTP) render(msg Message) []byte {
+	// Header values must stay on one line
+	oneLine := strings.NewReplacer("\r", " ", "\n", " ")
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", oneLine.Replace(m.From))
+	fmt.Fprintf(&b, "To: %s\r\n", oneLine.Replace(strings.Join(m.To, ", ")))
+	fmt.Fprintf(&b, "Subject: %s\r\n", oneLine.Replace(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Text, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s answered %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
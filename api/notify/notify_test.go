@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRelay accepts one SMTP session and returns the commands and message
+// it received
+func fakeRelay(t *testing.T) (addr string, session <-chan []string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	lines := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var received []string
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 relay ready")
+		inData := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				lines <- received
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			received = append(received, line)
+			switch {
+			case inData && line == ".":
+				inData = false
+				reply("250 queued")
+			case inData:
+			case strings.HasPrefix(line, "EHLO"), strings.HasPrefix(line, "HELO"):
+				reply("250 relay")
+			case line == "DATA":
+				inData = true
+				reply("354 go ahead")
+			case line == "QUIT":
+				reply("221 bye")
+				lines <- received
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return listener.Addr().String(), lines
+}
+
+func TestSMTP(t *testing.T) {
+	addr, session := fakeRelay(t)
+	mailer := &SMTP{Addr: addr, From: "alerts@localhost", To: []string{"a@example.com", "b@example.com"}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := mailer.Notify(ctx, Message{Subject: "New\r\nBcc: x@evil.example", Text: "line one\n.line two"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := strings.Join(<-session, "\n")
+	for _, want := range []string{
+		"MAIL FROM:<alerts@localhost>",
+		"RCPT TO:<a@example.com>",
+		"RCPT TO:<b@example.com>",
+		"Subject: New  Bcc: x@evil.example",
+		"line one\n..line two",
+	} {
+		if !strings.Contains(received, want) {
+			t.Errorf("session lacks %q:\n%s", want, received)
+		}
+	}
+}
+
+func TestSlackAndWebhook(t *testing.T) {
+	var got []map[string]interface{}
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		got = append(got, body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	msg := Message{Subject: "subject", Text: "text", Payload: map[string]string{"alert": "a1"}}
+	if err := (&Slack{URL: server.URL}).Notify(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Webhook{URL: server.URL}).Notify(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0]["text"] != "*subject*\ntext" || got[1]["alert"] != "a1" {
+		t.Errorf("received %v", got)
+	}
+
+	status = http.StatusInternalServerError
+	if err := (&Webhook{URL: server.URL}).Notify(context.Background(), msg); err == nil {
+		t.Error("a 500 answer was not reported")
+	}
+}
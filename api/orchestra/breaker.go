@@ -0,0 +1,195 @@
+package orchestra
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"osint-api/metrics"
+)
+
+// BreakerState is the state of a circuit breaker
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call through
+	BreakerClosed BreakerState = iota
+	// BreakerHalfOpen lets trial calls through one at a time
+	BreakerHalfOpen
+	// BreakerOpen fails every call fast until the cooldown has passed
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	}
+	return "unknown"
+}
+
+// BreakerSettings configures a circuit breaker
+type BreakerSettings struct {
+	Failures int           // consecutive failures that open the circuit
+	Cooldown time.Duration // time open before a trial call is let through
+	Probes   int           // successful trial calls that close the circuit again
+}
+
+// CircuitOpenError is returned instead of calling orchestra while the
+// circuit is open
+type CircuitOpenError struct {
+	RetryAfter time.Duration // until the next trial call
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("orchestra is unavailable (circuit open); retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// Breaker stops calls to orchestra after repeated transport failures, so
+// callers fail fast instead of each waiting out the timeout. After the
+// cooldown, trial calls decide whether the circuit closes or opens again.
+// A nil *Breaker is always closed.
+type Breaker struct {
+	settings BreakerSettings
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int // consecutive failures while closed
+	successes int // successful trial calls while half-open
+	probing   bool
+	openedAt  time.Time
+}
+
+// NewBreaker creates a closed circuit breaker. It returns nil, which never
+// trips, when settings.Failures is zero.
+func NewBreaker(settings BreakerSettings) *Breaker {
+	if settings.Failures <= 0 {
+		return nil
+	}
+	if settings.Probes <= 0 {
+		settings.Probes = 1
+	}
+	return &Breaker{settings: settings}
+}
+
+// State returns the current state. An open circuit whose cooldown has passed
+// is reported as half-open.
+func (b *Breaker) State() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.current()
+}
+
+// Check returns a *CircuitOpenError if a call would be rejected right now,
+// without claiming a trial call
+func (b *Breaker) Check() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.current() == BreakerOpen {
+		return b.openError()
+	}
+	return nil
+}
+
+// allow admits a call, or returns a *CircuitOpenError. In the half-open
+// state only one trial call is admitted at a time. Every admitted call must
+// be followed by record.
+func (b *Breaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.current() {
+	case BreakerOpen:
+		metrics.OrchestraCircuitRejections.Inc()
+		return b.openError()
+	case BreakerHalfOpen:
+		if b.probing {
+			metrics.OrchestraCircuitRejections.Inc()
+			return &CircuitOpenError{}
+		}
+		if b.state != BreakerHalfOpen {
+			b.transition(BreakerHalfOpen)
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record reports the outcome of an admitted call. Only transport failures
+// count: an error reply still shows orchestra is up.
+func (b *Breaker) record(failed bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		if b.failures++; b.failures >= b.settings.Failures {
+			b.transition(BreakerOpen)
+		}
+	case BreakerHalfOpen:
+		b.probing = false
+		if failed {
+			b.transition(BreakerOpen)
+			return
+		}
+		if b.successes++; b.successes >= b.settings.Probes {
+			b.transition(BreakerClosed)
+		}
+	}
+}
+
+// current returns the effective state. Callers must hold b.mu.
+func (b *Breaker) current() BreakerState {
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.settings.Cooldown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// openError reports how long the circuit stays open. Callers must hold b.mu.
+func (b *Breaker) openError() *CircuitOpenError {
+	retryAfter := b.settings.Cooldown - time.Since(b.openedAt)
+	if retryAfter < 0 {
+		retryAfter = 0
+	}
+	return &CircuitOpenError{RetryAfter: retryAfter}
+}
+
+// transition moves to state and resets the counters. Callers must hold b.mu.
+func (b *Breaker) transition(state BreakerState) {
+	switch state {
+	case BreakerOpen:
+		b.openedAt = time.Now()
+		log.Printf("🔌 Orchestra circuit opened; failing fast for %s", b.settings.Cooldown)
+	case BreakerHalfOpen:
+		log.Printf("🔌 Orchestra circuit half-open; sending a trial call")
+	case BreakerClosed:
+		log.Printf("🔌 Orchestra circuit closed")
+	}
+	b.state = state
+	b.failures = 0
+	b.successes = 0
+	metrics.OrchestraCircuitTransitions.WithLabelValues(state.String()).Inc()
+}
@@ -0,0 +1,106 @@
+package orchestra
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// call runs one admitted call through b, as Client.Call does
+func call(b *Breaker, failed bool) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+	b.record(failed)
+	return nil
+}
+
+// cool makes the open circuit's cooldown pass
+func cool(b *Breaker) {
+	b.mu.Lock()
+	b.openedAt = time.Now().Add(-b.settings.Cooldown)
+	b.mu.Unlock()
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	b := NewBreaker(BreakerSettings{Failures: 3, Cooldown: time.Minute, Probes: 2})
+
+	// Failures only open the circuit when consecutive
+	for _, failed := range []bool{true, true, false, true, true} {
+		if err := call(b, failed); err != nil {
+			t.Fatalf("closed circuit rejected a call: %v", err)
+		}
+	}
+	if state := b.State(); state != BreakerClosed {
+		t.Fatalf("state %s after interrupted failures, want closed", state)
+	}
+
+	call(b, true)
+	if state := b.State(); state != BreakerOpen {
+		t.Fatalf("state %s after 3 failures, want open", state)
+	}
+	var open *CircuitOpenError
+	if err := b.Check(); !errors.As(err, &open) || open.RetryAfter <= 0 || open.RetryAfter > time.Minute {
+		t.Fatalf("check on open circuit: %v", err)
+	}
+	if err := call(b, false); !errors.As(err, &open) {
+		t.Fatalf("open circuit admitted a call: %v", err)
+	}
+
+	// After the cooldown one trial call at a time is let through
+	cool(b)
+	if state := b.State(); state != BreakerHalfOpen {
+		t.Fatalf("state %s after the cooldown, want half-open", state)
+	}
+	if err := b.Check(); err != nil {
+		t.Fatalf("check on half-open circuit: %v", err)
+	}
+	if err := b.allow(); err != nil {
+		t.Fatalf("half-open circuit rejected the trial call: %v", err)
+	}
+	if err := b.allow(); !errors.As(err, &open) {
+		t.Fatalf("half-open circuit admitted a second concurrent trial: %v", err)
+	}
+	b.record(false)
+	if state := b.State(); state != BreakerHalfOpen {
+		t.Fatalf("state %s after 1 of 2 probes, want half-open", state)
+	}
+
+	// A failed trial opens the circuit again
+	call(b, true)
+	if state := b.State(); state != BreakerOpen {
+		t.Fatalf("state %s after a failed trial, want open", state)
+	}
+
+	cool(b)
+	for i := 0; i < 2; i++ {
+		if err := call(b, false); err != nil {
+			t.Fatalf("trial %d: %v", i+1, err)
+		}
+	}
+	if state := b.State(); state != BreakerClosed {
+		t.Fatalf("state %s after 2 successful probes, want closed", state)
+	}
+
+	// Counters start over once closed
+	call(b, true)
+	call(b, true)
+	if state := b.State(); state != BreakerClosed {
+		t.Errorf("state %s after 2 new failures, want closed", state)
+	}
+}
+
+func TestNilBreaker(t *testing.T) {
+	b := NewBreaker(BreakerSettings{})
+	if b != nil {
+		t.Fatal("breaker without a failure threshold is not nil")
+	}
+	for i := 0; i < 10; i++ {
+		if err := call(b, true); err != nil {
+			t.Fatalf("nil breaker rejected a call: %v", err)
+		}
+	}
+	if err := b.Check(); err != nil || b.State() != BreakerClosed {
+		t.Errorf("nil breaker: check %v, state %s", err, b.State())
+	}
+}
@@ -0,0 +1,216 @@
+package orchestra
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"syscall"
+	"time"
+
+	"osint-api/metrics"
+	"osint-api/protocol"
+	"osint-api/tracing"
+
+	zmq "github.com/pebbe/zmq4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Client sends requests to the orchestra service over a ZMQ REQ socket.
+// REQ sockets enforce strict send/receive lockstep and are not safe for
+// concurrent use, so calls are serialized.
+type Client struct {
+	endpoint string
+	timeout  time.Duration
+	curve    *Curve
+	breaker  *Breaker
+	socket   *zmq.Socket
+	mu       sync.Mutex
+}
+
+// Dial connects a client to endpoint, encrypted with curve unless it is nil.
+// Calls fail if orchestra has not replied within timeout; zero waits
+// forever. Calls fail fast while breaker, if any, is open.
+func Dial(endpoint string, timeout time.Duration, curve *Curve, breaker *Breaker) (*Client, error) {
+	c := &Client{endpoint: endpoint, timeout: timeout, curve: curve, breaker: breaker}
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) connect() error {
+	socket, err := zmq.NewSocket(zmq.REQ)
+	if err != nil {
+		return fmt.Errorf("failed to create ZMQ socket: %w", err)
+	}
+	socket.SetLinger(0)
+	if c.timeout > 0 {
+		socket.SetRcvtimeo(c.timeout)
+		socket.SetSndtimeo(c.timeout)
+	}
+	if err := c.curve.apply(socket); err != nil {
+		socket.Close()
+		return err
+	}
+	if err := socket.Connect(c.endpoint); err != nil {
+		socket.Close()
+		return fmt.Errorf("failed to connect to orchestra: %w", err)
+	}
+	c.socket = socket
+	return nil
+}
+
+// Breaker returns the client's circuit breaker, nil if it has none
+func (c *Client) Breaker() *Breaker {
+	return c.breaker
+}
+
+// reconnect replaces a socket stuck waiting for a reply that never came:
+// a REQ socket cannot send again until it receives. Callers must hold c.mu.
+func (c *Client) reconnect() {
+	c.socket.Close()
+	if err := c.connect(); err != nil {
+		log.Printf("⚠️ Orchestra reconnect failed: %v", err)
+	}
+}
+
+// Call sends request to orchestra and returns its reply. The request gets a
+// fresh correlation ID, so a reply can only match this call, the trace
+// context of ctx and a deadline from ctx or the client timeout, whichever is
+// sooner. A reply without the same ID is rejected. An error message from
+// orchestra is returned as a *protocol.RemoteError, and a call rejected by
+// the circuit breaker fails with a *CircuitOpenError.
+func (c *Client) Call(ctx context.Context, request *protocol.Envelope) (reply *protocol.Envelope, err error) {
+	action := request.Type
+
+	ctx, span := tracing.Tracer().Start(ctx, "orchestra."+action,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("messaging.system", "zeromq")),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	request.ID = newCorrelationID()
+	request.Trace = tracing.Inject(ctx)
+	if deadline, ok := ctx.Deadline(); ok && (c.timeout <= 0 || time.Until(deadline) < c.timeout) {
+		request.SetDeadline(deadline)
+	} else if c.timeout > 0 {
+		request.SetDeadline(time.Now().Add(c.timeout))
+	}
+	span.SetAttributes(attribute.String("messaging.message.id", request.ID))
+
+	payload, err := protocol.Encode(request)
+	if err != nil {
+		metrics.OrchestraErrors.WithLabelValues(action, "encode").Inc()
+		return nil, err
+	}
+
+	// Fail fast rather than queue behind calls that are timing out
+	if err := c.breaker.Check(); err != nil {
+		metrics.OrchestraCircuitRejections.Inc()
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	span.AddEvent("acquired socket")
+
+	// The circuit may have opened while this call was queued
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+	transportFailed := true
+	defer func() { c.breaker.record(transportFailed) }()
+
+	// Latency covers the round trip only, not time queued behind other calls
+	start := time.Now()
+	defer func() {
+		metrics.OrchestraDuration.WithLabelValues(action).Observe(time.Since(start).Seconds())
+	}()
+
+	if _, err := c.socket.SendBytes(payload, 0); err != nil {
+		metrics.OrchestraErrors.WithLabelValues(action, "send").Inc()
+		c.reconnect()
+		return nil, &TransportError{fmt.Errorf("failed to communicate with orchestra: %w", err)}
+	}
+
+	data, err := c.socket.RecvBytes(0)
+	if err != nil {
+		metrics.OrchestraErrors.WithLabelValues(action, "receive").Inc()
+		c.reconnect()
+		if zmq.AsErrno(err) == zmq.Errno(syscall.EAGAIN) {
+			return nil, &TransportError{fmt.Errorf("orchestra did not reply within %s", c.timeout)}
+		}
+		return nil, &TransportError{fmt.Errorf("failed to receive response from orchestra: %w", err)}
+	}
+
+	reply, err = protocol.Decode(data)
+	if err != nil {
+		metrics.OrchestraErrors.WithLabelValues(action, "decode").Inc()
+		return nil, fmt.Errorf("invalid response from orchestra: %w", err)
+	}
+	// Orchestra answered, even if with an error
+	transportFailed = false
+	if reply.ID != request.ID {
+		metrics.OrchestraErrors.WithLabelValues(action, "correlation").Inc()
+		return nil, fmt.Errorf("orchestra replied to %q instead of %s", reply.ID, request.ID)
+	}
+	if err := reply.Err(); err != nil {
+		metrics.OrchestraErrors.WithLabelValues(action, "remote").Inc()
+		return nil, err
+	}
+	return reply, nil
+}
+
+// TransportError is a failure to exchange messages with orchestra, as opposed
+// to an error orchestra replied with
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string { return e.Err.Error() }
+func (e *TransportError) Unwrap() error { return e.Err }
+
+// Temporary reports whether err may go away by itself: a transport failure
+// or an open circuit, but not an error reply or a protocol mismatch
+func Temporary(err error) bool {
+	var transport *TransportError
+	var open *CircuitOpenError
+	return errors.As(err, &transport) || errors.As(err, &open)
+}
+
+func newCorrelationID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Close waits for any call in progress, up to ctx's deadline, and closes the
+// socket without lingering on unsent messages. If the call does not finish
+// in time the socket is left open rather than closed under a live call.
+func (c *Client) Close(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for !c.mu.TryLock() {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("orchestra call still in progress: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	defer c.mu.Unlock()
+
+	return c.socket.Close()
+}
@@ -0,0 +1,92 @@
+package orchestra
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	zmq "github.com/pebbe/zmq4"
+)
+
+// zapDomain is the ZAP domain CURVE servers started by ServeCurve use
+const zapDomain = "orchestra"
+
+// Curve holds the Z85-encoded keys that encrypt the link to orchestra and
+// authenticate the API to it. A nil *Curve connects in plaintext.
+type Curve struct {
+	ServerPublic string // orchestra's public key
+	Public       string
+	Secret       string
+}
+
+// apply configures socket as a CURVE client. It must run before Connect.
+func (c *Curve) apply(socket *zmq.Socket) error {
+	if c == nil {
+		return nil
+	}
+	if err := socket.ClientAuthCurve(c.ServerPublic, c.Public, c.Secret); err != nil {
+		return fmt.Errorf("failed to configure CURVE: %w", err)
+	}
+	return nil
+}
+
+var zapOnce struct {
+	sync.Mutex
+	started bool
+}
+
+// ServeCurve makes socket a CURVE server with secretKey and, on first use,
+// starts the process-wide ZAP handler. The handler admits clientKeys, or
+// any client that completes the handshake if none are given; keys from
+// every call are admitted. It must run before Bind.
+func ServeCurve(socket *zmq.Socket, secretKey string, clientKeys ...string) error {
+	zapOnce.Lock()
+	defer zapOnce.Unlock()
+	if !zapOnce.started {
+		if err := zmq.AuthStart(); err != nil {
+			return fmt.Errorf("failed to start ZAP handler: %w", err)
+		}
+		zapOnce.started = true
+	}
+	if len(clientKeys) == 0 {
+		clientKeys = []string{zmq.CURVE_ALLOW_ANY}
+	}
+	zmq.AuthCurveAdd(zapDomain, clientKeys...)
+	return socket.ServerAuthCurve(zapDomain, secretKey)
+}
+
+// ReadCertificate reads the public and, if present, secret key from a ZMQ
+// certificate file, as written by czmq or pyzmq's
+// zmq.auth.create_certificates
+func ReadCertificate(path string) (public, secret string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		switch strings.TrimSpace(key) {
+		case "public-key":
+			public = value
+		case "secret-key":
+			secret = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", err
+	}
+	if public == "" {
+		return "", "", errors.New("no public-key in " + path)
+	}
+	return public, secret, nil
+}
@@ -0,0 +1,56 @@
+package orchestra
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadCertificate(t *testing.T) {
+	const public, secret = "rq:rM>}U?@Lns47E1%kR.o@n%FcmmsL/@{H8]yf7", "JTKVSB%%)wK0E.X)V>+}o?pNmC{O&4W4b!Ni{Lh6"
+	tests := []struct {
+		name       string
+		content    string
+		wantSecret string
+		wantErr    string
+	}{
+		{"secret certificate", `#   ****  Generated by pyzmq  ****
+#   ZeroMQ CURVE **Secret** Certificate
+#   DO NOT PROVIDE THIS FILE TO OTHER USERS nor change its permissions.
+
+metadata
+curve
+    public-key = "` + public + `"
+    secret-key = "` + secret + `"
+`, secret, ""},
+		{"public certificate", "curve\n    public-key = \"" + public + "\"\n", "", ""},
+		{"no public key", "curve\n    secret-key = \"" + secret + "\"\n", "", "no public-key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "api.key")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			gotPublic, gotSecret, err := ReadCertificate(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotPublic != public || gotSecret != tt.wantSecret {
+				t.Errorf("keys %q, %q; want %q, %q", gotPublic, gotSecret, public, tt.wantSecret)
+			}
+		})
+	}
+
+	if _, _, err := ReadCertificate(filepath.Join(t.TempDir(), "missing.key")); !os.IsNotExist(err) {
+		t.Errorf("missing file: %v", err)
+	}
+}
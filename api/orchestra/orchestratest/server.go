@@ -0,0 +1,314 @@
+// Package orchestratest provides an in-process stand-in for the orchestra
+// service, for tests that exercise the API end to end without the Python
+// stack. It speaks the protocol package's envelopes over a ZMQ ROUTER socket
+// and replies from scripted responses or per-type handlers.
+package orchestratest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"osint-api/orchestra"
+	"osint-api/protocol"
+
+	zmq "github.com/pebbe/zmq4"
+)
+
+// Response scripts how the server answers one request
+type Response struct {
+	Payload interface{}   // reply payload; ignored when Error or Raw is set
+	Error   string        // answer with an error envelope
+	Raw     []byte        // send these bytes verbatim, e.g. a malformed reply
+	Delay   time.Duration // wait before answering; other requests are served meanwhile
+	Drop    bool          // never answer, so the caller times out
+}
+
+// Handler computes the response to a request
+type Handler func(request *protocol.Envelope) Response
+
+// Event kinds
+const (
+	EventReceived = "received"
+	EventReplied  = "replied"
+	EventDropped  = "dropped"
+)
+
+// Event reports the progress of a request through the server
+type Event struct {
+	Kind    string
+	Request *protocol.Envelope
+	Reply   []byte // what was sent, for EventReplied
+}
+
+// DefaultReport is the investigate reply used when nothing else is scripted
+var DefaultReport = map[string]interface{}{
+	"risk_score": 0.2,
+	"findings":   []interface{}{},
+	"status":     "completed",
+}
+
+var serverCount int64
+
+// Server is a fake orchestra listening on an inproc endpoint
+type Server struct {
+	Endpoint string
+
+	socket  *zmq.Socket
+	replies chan outgoing
+	events  chan Event
+	done    chan struct{}
+	stopped chan struct{}
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	scripts  map[string][]Response
+	requests []*protocol.Envelope
+}
+
+type outgoing struct {
+	identity []byte
+	data     []byte
+}
+
+// NewServer starts a fake orchestra that answers ping, version and
+// investigate like the real one. It panics if the socket cannot be bound.
+func NewServer() *Server {
+	return newServer(nil)
+}
+
+// NewCurveServer starts a fake orchestra that requires CURVE, admitting
+// only clientKeys (any client if none)
+func NewCurveServer(secretKey string, clientKeys ...string) *Server {
+	return newServer(func(socket *zmq.Socket) error {
+		return orchestra.ServeCurve(socket, secretKey, clientKeys...)
+	})
+}
+
+func newServer(secure func(*zmq.Socket) error) *Server {
+	s := &Server{
+		Endpoint: fmt.Sprintf("inproc://orchestratest-%d", atomic.AddInt64(&serverCount, 1)),
+		replies:  make(chan outgoing, 64),
+		events:   make(chan Event, 256),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+		handlers: make(map[string]Handler),
+		scripts:  make(map[string][]Response),
+	}
+	s.handlers[protocol.TypePing] = func(*protocol.Envelope) Response {
+		return Response{Payload: protocol.PingReply{Status: "pong", Service: "orchestra"}}
+	}
+	s.handlers[protocol.TypeVersion] = func(*protocol.Envelope) Response {
+		return Response{Payload: protocol.VersionInfo{Service: "orchestra", Version: "test", Protocol: protocol.Version}}
+	}
+	s.handlers[protocol.TypeInvestigate] = func(*protocol.Envelope) Response {
+		return Response{Payload: DefaultReport}
+	}
+
+	socket, err := zmq.NewSocket(zmq.ROUTER)
+	if err != nil {
+		panic(fmt.Sprintf("orchestratest: %v", err))
+	}
+	socket.SetLinger(0)
+	// The loop alternates between receiving and flushing delayed replies
+	socket.SetRcvtimeo(5 * time.Millisecond)
+	if secure != nil {
+		if err := secure(socket); err != nil {
+			panic(fmt.Sprintf("orchestratest: %v", err))
+		}
+	}
+	if err := socket.Bind(s.Endpoint); err != nil {
+		panic(fmt.Sprintf("orchestratest: %v", err))
+	}
+	s.socket = socket
+
+	go s.serve()
+	return s
+}
+
+// Handle replaces the handler for msgType
+func (s *Server) Handle(msgType string, handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[msgType] = handler
+}
+
+// Script queues responses for the next requests of msgType, in order.
+// Once they are used up the handler answers again.
+func (s *Server) Script(msgType string, responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[msgType] = append(s.scripts[msgType], responses...)
+}
+
+// Requests returns the requests of msgType received so far, or all of them
+// if msgType is empty
+func (s *Server) Requests(msgType string) []*protocol.Envelope {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var requests []*protocol.Envelope
+	for _, request := range s.requests {
+		if msgType == "" || request.Type == msgType {
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+// Events reports every request as it is received and answered. Events are
+// dropped if nobody reads them.
+func (s *Server) Events() <-chan Event {
+	return s.events
+}
+
+// WaitFor returns the next event of kind for msgType, or false after
+// timeout
+func (s *Server) WaitFor(kind, msgType string, timeout time.Duration) (Event, bool) {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case event := <-s.events:
+			if event.Kind == kind && event.Request.Type == msgType {
+				return event, true
+			}
+		case <-deadline:
+			return Event{}, false
+		}
+	}
+}
+
+// Close stops the server and closes its socket. Pending delayed replies are
+// discarded.
+func (s *Server) Close() {
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+	close(s.done)
+	<-s.stopped
+}
+
+// serve owns the socket: ZMQ sockets must not be shared between goroutines,
+// so delayed replies are handed back here to be sent
+func (s *Server) serve() {
+	defer close(s.stopped)
+	defer s.socket.Close()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+
+		for flushed := false; !flushed; {
+			select {
+			case reply := <-s.replies:
+				s.socket.SendMessage(reply.identity, "", reply.data)
+			default:
+				flushed = true
+			}
+		}
+
+		frames, err := s.socket.RecvMessageBytes(0)
+		if err != nil {
+			if zmq.AsErrno(err) == zmq.Errno(syscall.EAGAIN) {
+				continue
+			}
+			return
+		}
+		if len(frames) < 3 {
+			continue
+		}
+		identity, data := frames[0], frames[len(frames)-1]
+		go s.answer(identity, data)
+	}
+}
+
+func (s *Server) answer(identity, data []byte) {
+	request, err := protocol.Decode(data)
+	if err != nil {
+		s.send(identity, mustEncode(protocol.Error(&protocol.Envelope{}, err.Error())))
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, request)
+	response, scripted := s.nextScripted(request.Type)
+	handler := s.handlers[request.Type]
+	s.mu.Unlock()
+	s.emit(Event{Kind: EventReceived, Request: request})
+
+	if !scripted {
+		if handler == nil {
+			response = Response{Error: "unknown message type: " + request.Type}
+		} else {
+			response = handler(request)
+		}
+	}
+
+	if response.Delay > 0 {
+		select {
+		case <-time.After(response.Delay):
+		case <-s.done:
+			return
+		}
+	}
+	if response.Drop {
+		s.emit(Event{Kind: EventDropped, Request: request})
+		return
+	}
+
+	var reply []byte
+	switch {
+	case response.Raw != nil:
+		reply = response.Raw
+	case response.Error != "":
+		reply = mustEncode(protocol.Error(request, response.Error))
+	default:
+		envelope, err := protocol.Reply(request, response.Payload)
+		if err != nil {
+			envelope = protocol.Error(request, err.Error())
+		}
+		reply = mustEncode(envelope)
+	}
+	s.send(identity, reply)
+	s.emit(Event{Kind: EventReplied, Request: request, Reply: reply})
+}
+
+// nextScripted pops the next scripted response. Callers must hold s.mu.
+func (s *Server) nextScripted(msgType string) (Response, bool) {
+	queue := s.scripts[msgType]
+	if len(queue) == 0 {
+		return Response{}, false
+	}
+	s.scripts[msgType] = queue[1:]
+	return queue[0], true
+}
+
+func (s *Server) send(identity, data []byte) {
+	select {
+	case s.replies <- outgoing{identity: identity, data: data}:
+	case <-s.done:
+	}
+}
+
+func (s *Server) emit(event Event) {
+	select {
+	case s.events <- event:
+	default:
+	}
+}
+
+func mustEncode(envelope *protocol.Envelope) []byte {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		panic(fmt.Sprintf("orchestratest: %v", err))
+	}
+	return data
+}
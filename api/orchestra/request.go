@@ -0,0 +1,101 @@
+package orchestra
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"osint-api/protocol"
+	"osint-api/version"
+
+	zmq "github.com/pebbe/zmq4"
+)
+
+// RequestOnce sends request to endpoint on a dedicated REQ socket, encrypted
+// with curve unless it is nil, and returns the reply. The socket is
+// discarded afterwards, so an unanswered request cannot wedge the shared
+// client socket. The wait for a reply is bounded by ctx's deadline, or one
+// second without one. Replies from another protocol revision are returned
+// along with a *protocol.VersionError.
+func RequestOnce(ctx context.Context, endpoint string, curve *Curve, request *protocol.Envelope) (*protocol.Envelope, error) {
+	timeout := time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		// A negative receive timeout would mean waiting forever
+		if timeout = time.Until(deadline); timeout <= 0 {
+			return nil, ctx.Err()
+		}
+	}
+	request.SetDeadline(time.Now().Add(timeout))
+
+	payload, err := protocol.Encode(request)
+	if err != nil {
+		return nil, err
+	}
+
+	socket, err := zmq.NewSocket(zmq.REQ)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create socket: %w", err)
+	}
+	defer socket.Close()
+	socket.SetLinger(0)
+	socket.SetRcvtimeo(timeout)
+	if err := curve.apply(socket); err != nil {
+		return nil, err
+	}
+
+	if err := socket.Connect(endpoint); err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	if _, err := socket.SendBytes(payload, 0); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	data, err := socket.RecvBytes(0)
+	if err != nil {
+		return nil, fmt.Errorf("no reply within %s: %w", timeout.Round(time.Millisecond), err)
+	}
+
+	reply, err := protocol.Decode(data)
+	if reply != nil && err == nil {
+		err = reply.Err()
+	}
+	return reply, err
+}
+
+// Handshake exchanges build versions with the orchestra at endpoint and
+// returns what it reported
+func Handshake(ctx context.Context, endpoint string, curve *Curve) (version.Peer, error) {
+	info := version.Get()
+	request, err := protocol.New(protocol.TypeVersion, "", protocol.VersionInfo{
+		Service:  "api",
+		Version:  info.Version,
+		Commit:   info.Commit,
+		Protocol: info.Protocol,
+	})
+	if err != nil {
+		return version.Peer{}, err
+	}
+
+	// A peer on another protocol revision still reports its version
+	reply, err := RequestOnce(ctx, endpoint, curve, request)
+	var versionErr *protocol.VersionError
+	if err != nil && !errors.As(err, &versionErr) {
+		return version.Peer{}, err
+	}
+
+	var peer protocol.VersionInfo
+	if err := reply.DecodePayload(&peer); err != nil {
+		return version.Peer{}, fmt.Errorf("invalid version reply: %w", err)
+	}
+	if peer.Service == "" {
+		peer.Service = "orchestra"
+	}
+	return version.Peer{
+		Service:   peer.Service,
+		Version:   peer.Version,
+		Commit:    peer.Commit,
+		Protocol:  peer.Protocol,
+		CheckedAt: time.Now(),
+	}, nil
+}
@@ -0,0 +1,170 @@
+// Package protocol defines the wire format of messages between the API and
+// orchestra (and the services orchestra fronts). Every message is a JSON
+// envelope carrying the protocol revision, a message type, a correlation ID,
+// an optional deadline and trace context, and a type-specific payload.
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Version is the protocol revision. Bump it for any change an older peer
+// would misread.
+const Version = 2
+
+// Message types. Requests are named after the action; every request is
+// answered by a reply or an error.
+const (
+	TypePing        = "ping"
+	TypeVersion     = "version"
+	TypeInvestigate = "investigate"
+	TypeReply       = "reply"
+	TypeError       = "error"
+)
+
+// Envelope is a single protocol message
+type Envelope struct {
+	Protocol  int               `json:"protocol"`
+	Type      string            `json:"type"`
+	ID        string            `json:"id,omitempty"`         // correlation ID, unique per message sent; replies echo the request's
+	RequestID string            `json:"request_id,omitempty"` // X-Request-ID of the API call behind the message, for logs
+	Deadline  *time.Time        `json:"deadline,omitempty"`   // the sender stops waiting after this
+	Trace     map[string]string `json:"trace,omitempty"`      // W3C trace context: traceparent, tracestate
+	Payload   json.RawMessage   `json:"payload,omitempty"`
+}
+
+// InvestigateRequest is the payload of an investigate request. The reply
+// payload is orchestra's correlation report.
+type InvestigateRequest struct {
+	OperationID string                 `json:"operation_id"`
+	Target      string                 `json:"target"`
+	Modules     []string               `json:"modules,omitempty"`
+	Priority    string                 `json:"priority,omitempty"`
+	ScanData    map[string]interface{} `json:"scan_data,omitempty"`
+	Attempt     int                    `json:"attempt,omitempty"` // retries resend the same operation_id with a higher attempt
+}
+
+// PingReply is the payload of a reply to ping
+type PingReply struct {
+	Status  string `json:"status"` // "pong"
+	Service string `json:"service,omitempty"`
+}
+
+// VersionInfo is the payload of a version request and of its reply
+type VersionInfo struct {
+	Service  string `json:"service"`
+	Version  string `json:"version,omitempty"`
+	Commit   string `json:"commit,omitempty"`
+	Protocol int    `json:"protocol"`
+}
+
+// ErrorPayload is the payload of an error message
+type ErrorPayload struct {
+	Message string `json:"message"`
+}
+
+// New builds an envelope of msgType with payload encoded as JSON. payload
+// may be nil.
+func New(msgType, id string, payload interface{}) (*Envelope, error) {
+	env := &Envelope{Protocol: Version, Type: msgType, ID: id}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s payload: %w", msgType, err)
+		}
+		env.Payload = data
+	}
+	return env, nil
+}
+
+// Reply builds the reply to request
+func Reply(request *Envelope, payload interface{}) (*Envelope, error) {
+	return New(TypeReply, request.ID, payload)
+}
+
+// Error builds an error message answering request
+func Error(request *Envelope, message string) *Envelope {
+	env, _ := New(TypeError, request.ID, ErrorPayload{Message: message})
+	return env
+}
+
+// SetDeadline records deadline, truncated to milliseconds so every peer can
+// parse it
+func (e *Envelope) SetDeadline(deadline time.Time) {
+	deadline = deadline.UTC().Truncate(time.Millisecond)
+	e.Deadline = &deadline
+}
+
+// Expired reports whether the envelope's deadline has passed
+func (e *Envelope) Expired(now time.Time) bool {
+	return e.Deadline != nil && now.After(*e.Deadline)
+}
+
+// DecodePayload decodes the payload into v
+func (e *Envelope) DecodePayload(v interface{}) error {
+	if len(e.Payload) == 0 {
+		return fmt.Errorf("%s message has no payload", e.Type)
+	}
+	if err := json.Unmarshal(e.Payload, v); err != nil {
+		return fmt.Errorf("invalid %s payload: %w", e.Type, err)
+	}
+	return nil
+}
+
+// Err returns the peer's error for an error message, nil otherwise
+func (e *Envelope) Err() error {
+	if e.Type != TypeError {
+		return nil
+	}
+	var payload ErrorPayload
+	if err := e.DecodePayload(&payload); err != nil || payload.Message == "" {
+		return &RemoteError{Message: "unspecified error"}
+	}
+	return &RemoteError{Message: payload.Message}
+}
+
+// RemoteError is an error reported by the peer
+type RemoteError struct {
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return e.Message
+}
+
+// VersionError reports an envelope from a different protocol revision
+type VersionError struct {
+	Got int
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("peer speaks protocol %d, expected %d", e.Got, Version)
+}
+
+// Encode serializes an envelope for the wire
+func Encode(e *Envelope) ([]byte, error) {
+	if e.Type == "" {
+		return nil, errors.New("envelope has no type")
+	}
+	return json.Marshal(e)
+}
+
+// Decode parses a message from the wire. A message from another protocol
+// revision is returned together with a *VersionError, so callers such as
+// the version handshake can still read it.
+func Decode(data []byte) (*Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("malformed envelope: %w", err)
+	}
+	if env.Protocol == 0 || env.Type == "" {
+		return nil, errors.New("malformed envelope: protocol and type are required")
+	}
+	if env.Protocol != Version {
+		return &env, &VersionError{Got: env.Protocol}
+	}
+	return &env, nil
+}
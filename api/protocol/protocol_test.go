@@ -0,0 +1,184 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var deadline = time.Date(2026, 1, 2, 15, 4, 5, 123456789, time.UTC)
+
+func mustNew(t *testing.T, msgType, id string, payload interface{}) *Envelope {
+	t.Helper()
+	env, err := New(msgType, id, payload)
+	if err != nil {
+		t.Fatalf("New(%s): %v", msgType, err)
+	}
+	return env
+}
+
+func goldenCases(t *testing.T) map[string]*Envelope {
+	ping := mustNew(t, TypePing, "req-ping", nil)
+
+	investigate := mustNew(t, TypeInvestigate, "req-1234", InvestigateRequest{
+		OperationID: "op_1767366245000000000",
+		Target:      "alice@example.com",
+		Modules:     []string{"email", "username"},
+		Priority:    "high",
+		ScanData:    map[string]interface{}{"sites_checked": 3, "found": []string{"github"}},
+	})
+	investigate.RequestID = "case42-run1"
+	investigate.SetDeadline(deadline)
+	investigate.Trace = map[string]string{
+		"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"tracestate":  "vendor=value",
+	}
+
+	version := mustNew(t, TypeVersion, "req-version", VersionInfo{
+		Service: "api", Version: "1.4.0", Commit: "abc1234", Protocol: Version,
+	})
+	versionReply, err := Reply(version, VersionInfo{Service: "orchestra", Version: "1.0.0", Protocol: Version})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pong, err := Reply(ping, PingReply{Status: "pong", Service: "orchestra"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]*Envelope{
+		"ping_request":        ping,
+		"ping_reply":          pong,
+		"investigate_request": investigate,
+		"version_request":     version,
+		"version_reply":       versionReply,
+		"error":               Error(investigate, "deadline exceeded before processing"),
+	}
+}
+
+func TestEncodeGolden(t *testing.T) {
+	for name, env := range goldenCases(t) {
+		t.Run(name, func(t *testing.T) {
+			got, err := Encode(env)
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+
+			path := filepath.Join("testdata", name+".json")
+			if *update {
+				if err := os.WriteFile(path, append(got, '\n'), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("missing golden file (run go test -update): %v", err)
+			}
+			if !bytes.Equal(got, bytes.TrimSpace(want)) {
+				t.Errorf("wire format changed\n got: %s\nwant: %s", got, bytes.TrimSpace(want))
+			}
+		})
+	}
+}
+
+func TestDecodeGolden(t *testing.T) {
+	for name := range goldenCases(t) {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", name+".json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			env, err := Decode(data)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			again, err := Encode(env)
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			if !bytes.Equal(again, bytes.TrimSpace(data)) {
+				t.Errorf("round trip changed the message\n got: %s\nwant: %s", again, bytes.TrimSpace(data))
+			}
+		})
+	}
+}
+
+func TestDecodePayload(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "investigate_request.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req InvestigateRequest
+	if err := env.DecodePayload(&req); err != nil {
+		t.Fatalf("DecodePayload: %v", err)
+	}
+	if req.Target != "alice@example.com" || len(req.Modules) != 2 || req.Priority != "high" {
+		t.Errorf("unexpected payload %+v", req)
+	}
+	if !env.Deadline.Equal(deadline.Truncate(time.Millisecond)) {
+		t.Errorf("deadline = %s, want %s truncated to milliseconds", env.Deadline, deadline)
+	}
+	if !env.Expired(deadline.Add(time.Second)) || env.Expired(deadline.Add(-time.Second)) {
+		t.Error("Expired disagrees with the deadline")
+	}
+}
+
+func TestDecodeRejectsMalformed(t *testing.T) {
+	for name, data := range map[string]string{
+		"not json":         `map[action:investigate target:alice]`,
+		"missing type":     `{"protocol":2,"id":"x"}`,
+		"missing protocol": `{"type":"ping"}`,
+		"legacy message":   `{"action":"ping"}`,
+	} {
+		if _, err := Decode([]byte(data)); err == nil {
+			t.Errorf("%s: Decode accepted %s", name, data)
+		}
+	}
+}
+
+func TestDecodeOtherVersion(t *testing.T) {
+	env, err := Decode([]byte(`{"protocol":1,"type":"reply","payload":{"service":"orchestra","protocol":1}}`))
+
+	var versionErr *VersionError
+	if !errors.As(err, &versionErr) || versionErr.Got != 1 {
+		t.Fatalf("err = %v, want a VersionError for protocol 1", err)
+	}
+	var info VersionInfo
+	if env == nil || env.DecodePayload(&info) != nil || info.Protocol != 1 {
+		t.Errorf("the envelope should still be readable, got %+v", env)
+	}
+}
+
+func TestErr(t *testing.T) {
+	request := mustNew(t, TypePing, "req-1", nil)
+	if err := Error(request, "orchestra overloaded").Err(); err == nil || err.Error() != "orchestra overloaded" {
+		t.Errorf("Err() = %v", err)
+	}
+
+	reply, err := Reply(request, PingReply{Status: "pong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.ID != "req-1" || reply.Err() != nil {
+		t.Errorf("reply = %+v, err %v", reply, reply.Err())
+	}
+}
+
+func TestEncodeRequiresType(t *testing.T) {
+	if _, err := Encode(&Envelope{Protocol: Version}); err == nil {
+		t.Error("Encode accepted an envelope without a type")
+	}
+}
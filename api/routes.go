@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+
+	"osint-api/config"
+	"osint-api/handlers"
+	"osint-api/handlers/middleware"
+	"osint-api/idempotency"
+	"osint-api/tracing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+)
+
+// services are the handlers behind the HTTP routes
+type services struct {
+	intel      *handlers.IntelHandler
+	ops        *handlers.OpsHandler
+	cases      *handlers.CaseHandler
+	schedules  *handlers.ScheduleHandler
+	watchlists *handlers.WatchlistHandler
+	health     *handlers.HealthHandler
+	cache      *handlers.CacheHandler
+	sites      *handlers.SitesHandler // nil when the site definitions did not load
+
+	idempotency *idempotency.Store // nil disables Idempotency-Key handling
+}
+
+// newRouter applies the middleware chain and registers every route. Metrics
+// wrap the whole router so unmatched requests are counted too.
+func newRouter(cfg *config.Config, s services) http.Handler {
+	router := mux.NewRouter()
+
+	// Apply middleware
+	router.Use(otelmux.Middleware(tracing.ServiceName))
+	router.Use(middleware.RequestIDMiddleware)
+	router.Use(middleware.LoggingMiddleware)
+	router.Use(middleware.ConcurrencyLimit(cfg.Server.MaxRequests))
+	router.Use(middleware.CORSMiddleware)
+	router.Use(middleware.ClientCertAuth(cfg.TLS.ClientIdentities))
+	router.Use(middleware.AuthMiddleware)
+
+	// API routes
+	api := router.PathPrefix("/api/v1").Subrouter()
+	idempotent := middleware.Idempotency(s.idempotency)
+	api.Handle("/intel", idempotent(http.HandlerFunc(s.intel.HandleIntelRequest))).Methods("POST")
+	api.HandleFunc("/intel/batch", s.intel.HandleBatchIntelRequest).Methods("POST")
+	api.HandleFunc("/health", s.health.HealthCheck).Methods("GET")
+	api.HandleFunc("/live", s.health.LiveCheck).Methods("GET")
+	api.HandleFunc("/ready", s.health.ReadyCheck).Methods("GET")
+	api.HandleFunc("/stats", s.health.StatsHandler).Methods("GET")
+	api.HandleFunc("/version", s.health.VersionInfo).Methods("GET")
+	api.HandleFunc("/operations", s.ops.ListOperations).Methods("GET")
+	api.Handle("/operations", idempotent(http.HandlerFunc(s.ops.CreateOperation))).Methods("POST")
+	api.HandleFunc("/operations/status", s.ops.GetOperationStatus).Methods("GET")
+	api.HandleFunc("/operations/stats", s.ops.GetOperationsStats).Methods("GET")
+	api.HandleFunc("/operations/cancel", s.ops.CancelOperation).Methods("DELETE")
+	api.HandleFunc("/operations/cleanup", s.ops.CleanupOperations).Methods("POST")
+	api.HandleFunc("/operations/{id}/retry", s.ops.RetryOperation).Methods("POST")
+	api.HandleFunc("/operations/{id}/diff", s.ops.DiffOperation).Methods("GET")
+	api.HandleFunc("/operations/{id}/tags", s.ops.SetTags).Methods("PUT")
+	api.HandleFunc("/operations/{id}/notes", s.ops.ListNotes).Methods("GET")
+	api.HandleFunc("/operations/{id}/notes", s.ops.AddNote).Methods("POST")
+	api.HandleFunc("/operations/{id}/case", s.cases.AttachOperation).Methods("PUT")
+	api.HandleFunc("/operations/{id}/case", s.cases.DetachOperation).Methods("DELETE")
+	api.HandleFunc("/cases", s.cases.ListCases).Methods("GET")
+	api.HandleFunc("/cases", s.cases.CreateCase).Methods("POST")
+	api.HandleFunc("/cases/{id}", s.cases.GetCase).Methods("GET")
+	api.HandleFunc("/cases/{id}", s.cases.UpdateCase).Methods("PATCH")
+	api.HandleFunc("/cases/{id}", s.cases.DeleteCase).Methods("DELETE")
+	api.HandleFunc("/schedules", s.schedules.ListSchedules).Methods("GET")
+	api.HandleFunc("/schedules", s.schedules.CreateSchedule).Methods("POST")
+	api.HandleFunc("/schedules/{id}", s.schedules.GetSchedule).Methods("GET")
+	api.HandleFunc("/schedules/{id}", s.schedules.UpdateSchedule).Methods("PATCH")
+	api.HandleFunc("/schedules/{id}", s.schedules.DeleteSchedule).Methods("DELETE")
+	api.HandleFunc("/schedules/{id}/pause", s.schedules.PauseSchedule).Methods("POST")
+	api.HandleFunc("/schedules/{id}/resume", s.schedules.ResumeSchedule).Methods("POST")
+	api.HandleFunc("/watchlists", s.watchlists.ListWatchlists).Methods("GET")
+	api.HandleFunc("/watchlists", s.watchlists.CreateWatchlist).Methods("POST")
+	api.HandleFunc("/watchlists/{id}", s.watchlists.GetWatchlist).Methods("GET")
+	api.HandleFunc("/watchlists/{id}", s.watchlists.UpdateWatchlist).Methods("PATCH")
+	api.HandleFunc("/watchlists/{id}", s.watchlists.DeleteWatchlist).Methods("DELETE")
+	api.HandleFunc("/alerts", s.watchlists.ListAlerts).Methods("GET")
+	api.HandleFunc("/alerts/{id}", s.watchlists.GetAlert).Methods("GET")
+	api.HandleFunc("/alerts/{id}/ack", s.watchlists.AcknowledgeAlert).Methods("POST")
+
+	// Admin routes
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.RequireAdmin)
+	admin.HandleFunc("/cache", s.cache.GetCacheStats).Methods("GET")
+	admin.HandleFunc("/cache", s.cache.PurgeCache).Methods("DELETE")
+
+	if s.sites != nil {
+		siteRoutes := api.PathPrefix("/sites").Subrouter()
+		siteRoutes.Use(middleware.RequireAdmin)
+		siteRoutes.HandleFunc("", s.sites.ListSites).Methods("GET")
+		siteRoutes.HandleFunc("", s.sites.CreateSite).Methods("POST")
+		siteRoutes.HandleFunc("/{name}", s.sites.GetSite).Methods("GET")
+		siteRoutes.HandleFunc("/{name}", s.sites.UpdateSite).Methods("PUT")
+		siteRoutes.HandleFunc("/{name}", s.sites.DeleteSite).Methods("DELETE")
+	}
+
+	return middleware.Metrics(router)
+}
+
+// newMetricsRouter serves the Prometheus scrape endpoint. It runs on its own
+// listener so it can stay unauthenticated without exposing it on the API port.
+func newMetricsRouter() http.Handler {
+	router := mux.NewRouter()
+	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	return router
+}
@@ -0,0 +1,32 @@
+package scanner
+
+import "context"
+
+// ModuleName is the investigation module name clients request to run a
+// username presence scan
+const ModuleName = "username"
+
+// Module exposes the scanner as an investigation module the API runs
+// in-process, without a round trip through orchestra and muscle
+type Module struct {
+	Scanner     *Scanner
+	Sites       func() []Site // current site definitions, re-read on every run
+	IncludeNSFW bool
+}
+
+// Run scans target across the module's enabled sites that accept it
+func (m *Module) Run(ctx context.Context, target string) (interface{}, error) {
+	var sites []Site
+	for _, site := range m.Sites() {
+		if site.Disabled || (site.NSFW && !m.IncludeNSFW) || !site.Accepts(target) {
+			continue
+		}
+		sites = append(sites, site)
+	}
+
+	results := m.Scanner.Scan(ctx, target, sites)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,245 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultConcurrency = 10
+	defaultTimeout     = 5 * time.Second
+	defaultUserAgent   = "MUSCLE-Scanner/1.0"
+	maxBodyBytes       = 1 << 20 // bodies beyond this are not inspected
+)
+
+var userAgents = []string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36",
+	"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36",
+}
+
+// Result is the outcome of checking one site for a username
+type Result struct {
+	Site           string    `json:"website_name"`
+	URL            string    `json:"url"`
+	Found          bool      `json:"found"`
+	StatusCode     int       `json:"status_code"`
+	ResponseTimeMS int64     `json:"response_time_ms"`
+	Error          string    `json:"error,omitempty"`
+	CheckedAt      time.Time `json:"timestamp"`
+}
+
+// Results summarizes a scan across all sites
+type Results struct {
+	Target       string   `json:"target"`
+	FoundCount   int      `json:"found_count"`
+	TotalChecked int      `json:"total_checked"`
+	Details      []Result `json:"details"`
+	DurationMS   int64    `json:"scan_duration_ms"`
+}
+
+// Scanner checks username presence across sites with bounded concurrency
+type Scanner struct {
+	client      *http.Client
+	concurrency int
+}
+
+// New creates a scanner running at most concurrency checks at once. A nil
+// client uses a default one; tests can inject a client with a custom
+// transport.
+func New(client *http.Client, concurrency int) *Scanner {
+	if client == nil {
+		client = &http.Client{}
+	}
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	return &Scanner{client: client, concurrency: concurrency}
+}
+
+// Scan checks username against every site. Details are ordered by site
+// priority, then name.
+func (s *Scanner) Scan(ctx context.Context, username string, sites []Site) Results {
+	start := time.Now()
+	details := make([]Result, len(sites))
+
+	sem := make(chan struct{}, s.concurrency)
+	var wg sync.WaitGroup
+	for i, site := range sites {
+		wg.Add(1)
+		go func(i int, site Site) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+				details[i] = s.Check(ctx, username, site)
+			case <-ctx.Done():
+				details[i] = Result{
+					Site:      site.Name,
+					URL:       site.ProfileURL(username),
+					Error:     ctx.Err().Error(),
+					CheckedAt: time.Now(),
+				}
+			}
+		}(i, site)
+	}
+	wg.Wait()
+
+	order := make(map[string]int, len(sites))
+	for _, site := range sites {
+		order[site.Name] = site.Priority
+	}
+	sort.SliceStable(details, func(i, j int) bool {
+		if order[details[i].Site] != order[details[j].Site] {
+			return order[details[i].Site] < order[details[j].Site]
+		}
+		return details[i].Site < details[j].Site
+	})
+
+	results := Results{
+		Target:       username,
+		TotalChecked: len(sites),
+		Details:      details,
+		DurationMS:   time.Since(start).Milliseconds(),
+	}
+	for _, result := range details {
+		if result.Found {
+			results.FoundCount++
+		}
+	}
+	return results
+}
+
+// Check tests a single site for username
+func (s *Scanner) Check(ctx context.Context, username string, site Site) Result {
+	result := Result{
+		Site:      site.Name,
+		URL:       site.ProfileURL(username),
+		CheckedAt: time.Now(),
+	}
+
+	timeout := defaultTimeout
+	if site.TimeoutMS > 0 {
+		timeout = time.Duration(site.TimeoutMS) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, result.URL, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req.Header.Set("User-Agent", userAgent(site))
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
+
+	client := s.client
+	if site.CheckType == CheckRedirect {
+		// Inspect the first response rather than following it
+		noFollow := *s.client
+		noFollow.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+		client = &noFollow
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+	result.ResponseTimeMS = time.Since(start).Milliseconds()
+	result.StatusCode = resp.StatusCode
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Found, err = detect(site, resp, string(body))
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// detect applies the site's check_type to a response
+func detect(site Site, resp *http.Response, body string) (bool, error) {
+	switch site.CheckType {
+	case CheckStatusCode, "":
+		codes := site.SuccessCodes
+		if len(codes) == 0 {
+			codes = []int{http.StatusOK}
+		}
+		for _, code := range codes {
+			if resp.StatusCode == code {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case CheckBodyContains:
+		return resp.StatusCode < 400 && strings.Contains(body, site.Match), nil
+
+	case CheckBodyAbsent:
+		return resp.StatusCode < 400 && !strings.Contains(body, site.Match), nil
+
+	case CheckRedirect:
+		if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+			location := resp.Header.Get("Location")
+			return site.Match != "" && !strings.Contains(location, site.Match), nil
+		}
+		return resp.StatusCode < 400, nil
+
+	case CheckJSONField:
+		if resp.StatusCode >= 400 {
+			return false, nil
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(body), &value); err != nil {
+			return false, fmt.Errorf("response is not JSON: %w", err)
+		}
+		for _, key := range strings.Split(site.Match, ".") {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return false, nil
+			}
+			value = object[key]
+		}
+		switch v := value.(type) {
+		case nil:
+			return false, nil
+		case bool:
+			return v, nil
+		case string:
+			return v != "", nil
+		case []interface{}:
+			return len(v) > 0, nil
+		case map[string]interface{}:
+			return len(v) > 0, nil
+		}
+		return true, nil
+	}
+
+	return false, fmt.Errorf("unsupported check_type %q", site.CheckType)
+}
+
+func userAgent(site Site) string {
+	if site.RandomizeUserAgent {
+		return userAgents[rand.Intn(len(userAgents))]
+	}
+	return defaultUserAgent
+}
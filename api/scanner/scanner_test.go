@@ -0,0 +1,100 @@
+package scanner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// profile is the response served for one username
+type profile struct {
+	status   int
+	body     string
+	location string
+}
+
+func TestCheck(t *testing.T) {
+	profiles := map[string]profile{
+		"ok":        {status: http.StatusOK, body: "<h1>ok</h1>"},
+		"missing":   {status: http.StatusNotFound, body: "Page not found"},
+		"soft404":   {status: http.StatusOK, body: "<p>Page not found</p>"},
+		"gone":      {status: http.StatusGone},
+		"moved":     {status: http.StatusFound, location: "/login?next=moved"},
+		"renamed":   {status: http.StatusMovedPermanently, location: "/renamed-user"},
+		"user":      {status: http.StatusOK, body: `{"data":{"user":{"id":7,"name":"user"}}}`},
+		"nouser":    {status: http.StatusOK, body: `{"data":{"user":null}}`},
+		"emptyuser": {status: http.StatusOK, body: `{"data":{"user":{}}}`},
+		"scalar":    {status: http.StatusOK, body: `{"data":"user"}`},
+		"notjson":   {status: http.StatusOK, body: "<html>"},
+		"jsonerror": {status: http.StatusInternalServerError, body: `{"data":{"user":{"id":1}}}`},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := profiles[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if p.location != "" {
+			w.Header().Set("Location", p.location)
+		}
+		w.WriteHeader(p.status)
+		w.Write([]byte(p.body))
+	}))
+	defer server.Close()
+
+	site := func(checkType, match string, codes ...int) Site {
+		return Site{Name: "Example", URL: server.URL + "/{}", CheckType: checkType, Match: match, SuccessCodes: codes}
+	}
+	tests := []struct {
+		name     string
+		site     Site
+		username string
+		found    bool
+		err      bool
+	}{
+		{"status 200", site(CheckStatusCode, ""), "ok", true, false},
+		{"status 404", site(CheckStatusCode, ""), "missing", false, false},
+		{"status default type", site("", ""), "ok", true, false},
+		{"status success codes", site(CheckStatusCode, "", http.StatusGone), "gone", true, false},
+		{"status not a success code", site(CheckStatusCode, "", http.StatusGone), "ok", false, false},
+		{"status follows redirects", site(CheckStatusCode, ""), "renamed", false, false},
+
+		{"contains match", site(CheckBodyContains, "<h1>"), "ok", true, false},
+		{"contains no match", site(CheckBodyContains, "<h1>"), "soft404", false, false},
+		{"contains error status", site(CheckBodyContains, "Page"), "missing", false, false},
+		{"absent match", site(CheckBodyAbsent, "Page not found"), "ok", true, false},
+		{"absent soft 404", site(CheckBodyAbsent, "Page not found"), "soft404", false, false},
+		{"absent error status", site(CheckBodyAbsent, "nothing"), "missing", false, false},
+
+		{"redirect none", site(CheckRedirect, ""), "ok", true, false},
+		{"redirect any", site(CheckRedirect, ""), "renamed", false, false},
+		{"redirect to match", site(CheckRedirect, "/login"), "moved", false, false},
+		{"redirect elsewhere", site(CheckRedirect, "/login"), "renamed", true, false},
+		{"redirect error status", site(CheckRedirect, ""), "missing", false, false},
+
+		{"json field", site(CheckJSONField, "data.user"), "user", true, false},
+		{"json nested field", site(CheckJSONField, "data.user.id"), "user", true, false},
+		{"json null", site(CheckJSONField, "data.user"), "nouser", false, false},
+		{"json empty object", site(CheckJSONField, "data.user"), "emptyuser", false, false},
+		{"json missing field", site(CheckJSONField, "data.account"), "user", false, false},
+		{"json path through a scalar", site(CheckJSONField, "data.user"), "scalar", false, false},
+		{"json error status", site(CheckJSONField, "data.user"), "jsonerror", false, false},
+		{"json not json", site(CheckJSONField, "data.user"), "notjson", false, true},
+
+		{"unknown type", site("regex", ""), "ok", false, true},
+	}
+	s := New(server.Client(), 1)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := s.Check(context.Background(), tt.username, tt.site)
+			if result.Found != tt.found || (result.Error != "") != tt.err {
+				t.Errorf("found %v, error %q; want found %v, error %v", result.Found, result.Error, tt.found, tt.err)
+			}
+			if result.URL != server.URL+"/"+tt.username || result.StatusCode == 0 {
+				t.Errorf("result %+v", result)
+			}
+		})
+	}
+}
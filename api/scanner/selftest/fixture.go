@@ -0,0 +1,118 @@
+package selftest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"osint-api/scanner"
+)
+
+// maxFixtureBody caps recorded bodies; the scanner inspects no more than this
+const maxFixtureBody = 1 << 20
+
+// Fixture is a recorded HTTP response for one site and username
+type Fixture struct {
+	URL        string            `json:"url"`
+	StatusCode int               `json:"status_code"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body"`
+	RecordedAt time.Time         `json:"recorded_at"`
+	Note       string            `json:"note,omitempty"` // how a fixture not recorded from the live site was made
+}
+
+// FixturePath returns where the fixture for site and username lives in dir
+func FixturePath(dir string, site scanner.Site, username string) string {
+	return filepath.Join(dir, slug(site.Name), slug(username)+".json")
+}
+
+// LoadFixture reads a recorded fixture
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+	return &fixture, nil
+}
+
+// SaveFixture writes a fixture, creating its directory
+func SaveFixture(path string, fixture *Fixture) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Record fetches the live profile page of username on site and returns it
+// as a fixture. Redirects are followed except for redirect-based checks,
+// which need the first response, matching what the scanner sees.
+func Record(ctx context.Context, client *http.Client, site scanner.Site, username string) (*Fixture, error) {
+	profileURL := site.ProfileURL(username)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, profileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "MUSCLE-Scanner/1.0")
+
+	if site.CheckType == scanner.CheckRedirect {
+		noFollow := *client
+		noFollow.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+		client = &noFollow
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFixtureBody))
+	if err != nil {
+		return nil, err
+	}
+
+	fixture := &Fixture{
+		URL:        profileURL,
+		StatusCode: resp.StatusCode,
+		Headers:    make(map[string]string),
+		Body:       string(body),
+		RecordedAt: time.Now().UTC(),
+	}
+	for _, name := range []string{"Content-Type", "Location"} {
+		if value := resp.Header.Get(name); value != "" {
+			fixture.Headers[name] = value
+		}
+	}
+	return fixture, nil
+}
+
+// slug makes a name safe to use as a path component
+func slug(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		}
+		return '_'
+	}, name)
+}
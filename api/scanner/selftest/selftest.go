@@ -0,0 +1,146 @@
+// Package selftest replays recorded HTTP fixtures through the username
+// scanner to find site definitions that no longer tell a claimed username
+// from an unclaimed one.
+package selftest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"osint-api/scanner"
+)
+
+// Outcome of self-testing one site definition
+const (
+	StatusOK              = "ok"               // claimed found, unclaimed absent
+	StatusBroken          = "broken"           // the definition misreports at least one username
+	StatusMissingFixtures = "missing_fixtures" // usernames declared but not recorded yet
+	StatusUntested        = "untested"         // no username_claimed/username_unclaimed declared
+)
+
+// SiteReport is the self-test result for one site
+type SiteReport struct {
+	Site     string   `json:"site"`
+	Status   string   `json:"status"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+// Report is the self-test result for a set of sites
+type Report struct {
+	Sites []SiteReport `json:"sites"`
+}
+
+// Broken returns the reports of sites whose definitions are broken
+func (r Report) Broken() []SiteReport {
+	return r.withStatus(StatusBroken)
+}
+
+// Missing returns the reports of sites that could not be tested
+func (r Report) Missing() []SiteReport {
+	return append(r.withStatus(StatusMissingFixtures), r.withStatus(StatusUntested)...)
+}
+
+func (r Report) withStatus(status string) []SiteReport {
+	var matched []SiteReport
+	for _, site := range r.Sites {
+		if site.Status == status {
+			matched = append(matched, site)
+		}
+	}
+	return matched
+}
+
+// Run replays the fixtures in dir for every site, entirely offline
+func Run(ctx context.Context, sites []scanner.Site, dir string) Report {
+	var report Report
+	for _, site := range sites {
+		report.Sites = append(report.Sites, runSite(ctx, site, dir))
+	}
+	return report
+}
+
+func runSite(ctx context.Context, site scanner.Site, dir string) SiteReport {
+	report := SiteReport{Site: site.Name, Status: StatusOK}
+	if site.UsernameClaimed == "" || site.UsernameUnclaimed == "" {
+		report.Status = StatusUntested
+		return report
+	}
+
+	replayer := &Replayer{fixtures: make(map[string]*Fixture)}
+	for _, username := range []string{site.UsernameClaimed, site.UsernameUnclaimed} {
+		fixture, err := LoadFixture(FixturePath(dir, site, username))
+		if errors.Is(err, os.ErrNotExist) {
+			report.Status = StatusMissingFixtures
+			report.Problems = append(report.Problems, fmt.Sprintf("no fixture recorded for %q", username))
+			continue
+		}
+		if err != nil {
+			report.Status = StatusMissingFixtures
+			report.Problems = append(report.Problems, err.Error())
+			continue
+		}
+		replayer.fixtures[site.ProfileURL(username)] = fixture
+	}
+	if report.Status != StatusOK {
+		return report
+	}
+
+	s := scanner.New(&http.Client{Transport: replayer}, 1)
+
+	claimed := s.Check(ctx, site.UsernameClaimed, site)
+	switch {
+	case claimed.Error != "":
+		report.Problems = append(report.Problems, fmt.Sprintf("claimed username %q: %s", site.UsernameClaimed, claimed.Error))
+	case !claimed.Found:
+		report.Problems = append(report.Problems, fmt.Sprintf("claimed username %q reported absent (status %d)", site.UsernameClaimed, claimed.StatusCode))
+	}
+
+	unclaimed := s.Check(ctx, site.UsernameUnclaimed, site)
+	switch {
+	case unclaimed.Error != "":
+		report.Problems = append(report.Problems, fmt.Sprintf("unclaimed username %q: %s", site.UsernameUnclaimed, unclaimed.Error))
+	case unclaimed.Found:
+		report.Problems = append(report.Problems, fmt.Sprintf("unclaimed username %q reported present (status %d): false positive", site.UsernameUnclaimed, unclaimed.StatusCode))
+	}
+
+	if len(report.Problems) > 0 {
+		report.Status = StatusBroken
+	}
+	return report
+}
+
+// Replayer is an http.RoundTripper serving recorded fixtures by URL. It
+// never touches the network.
+type Replayer struct {
+	fixtures map[string]*Fixture
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	fixture, ok := r.fixtures[req.URL.String()]
+	if !ok {
+		return nil, fmt.Errorf("no fixture recorded for %s", req.URL)
+	}
+
+	header := make(http.Header)
+	for name, value := range fixture.Headers {
+		header.Set(name, value)
+	}
+
+	return &http.Response{
+		Status:        http.StatusText(fixture.StatusCode),
+		StatusCode:    fixture.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewBufferString(fixture.Body)),
+		ContentLength: int64(len(fixture.Body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,62 @@
+package selftest
+
+import (
+	"context"
+	"testing"
+
+	"osint-api/scanner"
+)
+
+func TestRunReportsBrokenDefinitions(t *testing.T) {
+	sites, err := scanner.LoadSites("testdata/websites.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := Run(context.Background(), sites, "testdata/fixtures")
+
+	want := map[string]string{
+		"Reliable":     StatusOK,
+		"CatchAll":     StatusBroken,
+		"NotFoundPage": StatusOK,
+		"LoginWall":    StatusOK,
+		"Unrecorded":   StatusMissingFixtures,
+		"NoUsernames":  StatusUntested,
+	}
+	if len(report.Sites) != len(want) {
+		t.Fatalf("got %d site reports, want %d", len(report.Sites), len(want))
+	}
+	for _, site := range report.Sites {
+		if site.Status != want[site.Site] {
+			t.Errorf("%s: status %q, want %q (problems: %v)", site.Site, site.Status, want[site.Site], site.Problems)
+		}
+	}
+
+	broken := report.Broken()
+	if len(broken) != 1 || broken[0].Site != "CatchAll" {
+		t.Fatalf("Broken() = %+v, want only CatchAll", broken)
+	}
+	if len(broken[0].Problems) != 1 {
+		t.Errorf("CatchAll problems = %v, want the unclaimed false positive only", broken[0].Problems)
+	}
+	if len(report.Missing()) != 2 {
+		t.Errorf("Missing() = %+v, want Unrecorded and NoUsernames", report.Missing())
+	}
+}
+
+// TestWebsitesDefinitions replays the fixtures recorded with
+// `go run ./cmd/sitecheck -record` against the shared muscle definitions.
+func TestWebsitesDefinitions(t *testing.T) {
+	sites, err := scanner.LoadSites("../../../muscle/websites.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := Run(context.Background(), sites, "../testdata/fixtures")
+	for _, site := range report.Broken() {
+		t.Errorf("%s: broken site definition: %v", site.Site, site.Problems)
+	}
+	for _, site := range report.Missing() {
+		t.Errorf("%s: not self-tested (%s); record its fixtures with `make record-sites`", site.Site, site.Status)
+	}
+}
@@ -0,0 +1,85 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Detection strategies supported in a site's check_type
+const (
+	CheckStatusCode   = "status_code"   // found when the status is one of success_codes (default 200)
+	CheckBodyContains = "body_contains" // found when the body contains match
+	CheckBodyAbsent   = "body_absent"   // found when the body does not contain match (e.g. "Page not found")
+	CheckRedirect     = "redirect"      // found unless the profile URL redirects (to a location containing match, if set)
+	CheckJSONField    = "json_field"    // found when the JSON body has a non-empty value at match, a dotted path such as "data.user"
+)
+
+// Site is one platform definition from websites.json. The schema is shared
+// with the muscle ScannerEngine.
+type Site struct {
+	Name               string `json:"name"`
+	URL                string `json:"url"` // profile URL template; {} is replaced by the username
+	CheckType          string `json:"check_type"`
+	Priority           int    `json:"priority"`
+	TimeoutMS          int    `json:"timeout_ms"`
+	SuccessCodes       []int  `json:"success_codes,omitempty"`
+	Match              string `json:"match,omitempty"`
+	RandomizeUserAgent bool   `json:"randomize_user_agent,omitempty"`
+	Category           string `json:"category,omitempty"`           // e.g. social, coding, gaming
+	NSFW               bool   `json:"nsfw,omitempty"`               // adult content; skipped unless explicitly included
+	UsernamePattern    string `json:"username_pattern,omitempty"`   // regex usernames must match to exist on the site
+	Disabled           bool   `json:"disabled,omitempty"`           // kept in the file but never scanned
+	UsernameClaimed    string `json:"username_claimed,omitempty"`   // known-existing account, used by the self-test harness
+	UsernameUnclaimed  string `json:"username_unclaimed,omitempty"` // known-free username, used by the self-test harness
+}
+
+// ProfileURL renders the site's URL template for username
+func (s Site) ProfileURL(username string) string {
+	return strings.Replace(s.URL, "{}", url.PathEscape(username), 1)
+}
+
+// Accepts reports whether username can exist on the site. Usernames that
+// violate the site's pattern are skipped rather than reported as absent.
+func (s Site) Accepts(username string) bool {
+	if s.UsernamePattern == "" {
+		return true
+	}
+	matched, err := regexp.MatchString(s.UsernamePattern, username)
+	return err == nil && matched
+}
+
+// siteFile is the top-level layout of websites.json
+type siteFile struct {
+	Websites []Site `json:"websites"`
+}
+
+// LoadSites reads site definitions from a websites.json file
+func LoadSites(path string) ([]Site, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read site definitions: %w", err)
+	}
+	return ParseSites(data)
+}
+
+// ParseSites decodes site definitions in websites.json format
+func ParseSites(data []byte) ([]Site, error) {
+	var file siteFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid site definitions: %w", err)
+	}
+	return file.Websites, nil
+}
+
+// MarshalSites encodes site definitions in websites.json format
+func MarshalSites(sites []Site) ([]byte, error) {
+	data, err := json.MarshalIndent(siteFile{Websites: sites}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,241 @@
+package sites
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"osint-api/scanner"
+)
+
+var (
+	// ErrNotFound is returned when no site has the requested name
+	ErrNotFound = errors.New("site not found")
+	// ErrExists is returned when creating a site whose name is taken
+	ErrExists = errors.New("site already exists")
+)
+
+// Registry holds the validated site definitions backed by a websites.json
+// file. Changes made through the registry are written back to the file, and
+// edits made to the file directly are picked up by Watch.
+type Registry struct {
+	path string
+
+	mu      sync.RWMutex
+	sites   []scanner.Site
+	modTime time.Time
+}
+
+// Load reads and validates the definitions in path
+func Load(path string) (*Registry, error) {
+	r := &Registry{path: path}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload re-reads the file. An invalid file leaves the current definitions
+// in place.
+func (r *Registry) Reload() error {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return fmt.Errorf("failed to read site definitions: %w", err)
+	}
+
+	sites, err := scanner.LoadSites(r.path)
+	if err != nil {
+		return err
+	}
+	if err := ValidateAll(sites); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.sites = sites
+	r.modTime = info.ModTime()
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Watch polls the file every interval and reloads it when it changes, until
+// ctx is done
+func (r *Registry) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var rejected time.Time // modification time of the last invalid file, reported once
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(r.path)
+		if err != nil {
+			continue
+		}
+
+		r.mu.RLock()
+		changed := !info.ModTime().Equal(r.modTime) && !info.ModTime().Equal(rejected)
+		r.mu.RUnlock()
+
+		if changed {
+			if err := r.Reload(); err != nil {
+				log.Printf("⚠️ Keeping previous site definitions: %v", err)
+				rejected = info.ModTime()
+				continue
+			}
+			log.Printf("🔄 Reloaded site definitions from %s", r.path)
+		}
+	}
+}
+
+// Path returns the definitions file backing the registry
+func (r *Registry) Path() string {
+	return r.path
+}
+
+// All returns every definition, including disabled ones, ordered by
+// priority then name
+func (r *Registry) All() []scanner.Site {
+	r.mu.RLock()
+	sites := append([]scanner.Site(nil), r.sites...)
+	r.mu.RUnlock()
+
+	sort.SliceStable(sites, func(i, j int) bool {
+		if sites[i].Priority != sites[j].Priority {
+			return sites[i].Priority < sites[j].Priority
+		}
+		return sites[i].Name < sites[j].Name
+	})
+	return sites
+}
+
+// Enabled returns the definitions that should be scanned
+func (r *Registry) Enabled() []scanner.Site {
+	var enabled []scanner.Site
+	for _, site := range r.All() {
+		if !site.Disabled {
+			enabled = append(enabled, site)
+		}
+	}
+	return enabled
+}
+
+// Get returns the site with name, case-insensitively
+func (r *Registry) Get(name string) (scanner.Site, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if i := r.index(name); i >= 0 {
+		return r.sites[i], true
+	}
+	return scanner.Site{}, false
+}
+
+// Create adds a new site definition
+func (r *Registry) Create(site scanner.Site) error {
+	if err := Validate(site); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.index(site.Name) >= 0 {
+		return ErrExists
+	}
+	return r.save(append(append([]scanner.Site(nil), r.sites...), site))
+}
+
+// Update replaces the site named name. Renaming is allowed as long as the new
+// name is free.
+func (r *Registry) Update(name string, site scanner.Site) error {
+	if err := Validate(site); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(name)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if j := r.index(site.Name); j >= 0 && j != i {
+		return ErrExists
+	}
+
+	sites := append([]scanner.Site(nil), r.sites...)
+	sites[i] = site
+	return r.save(sites)
+}
+
+// Delete removes the site named name
+func (r *Registry) Delete(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(name)
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	sites := append(append([]scanner.Site(nil), r.sites[:i]...), r.sites[i+1:]...)
+	return r.save(sites)
+}
+
+// index finds name in r.sites. Callers must hold r.mu.
+func (r *Registry) index(name string) int {
+	for i, site := range r.sites {
+		if strings.EqualFold(site.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// save atomically writes sites to the file and makes them current. Callers
+// must hold r.mu for writing.
+func (r *Registry) save(sites []scanner.Site) error {
+	data, err := scanner.MarshalSites(sites)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), ".websites-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write site definitions: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if info, statErr := os.Stat(r.path); err == nil && statErr == nil {
+		// Keep the original permissions; muscle reads the same file
+		err = os.Chmod(tmp.Name(), info.Mode().Perm())
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), r.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write site definitions: %w", err)
+	}
+
+	r.sites = sites
+	if info, err := os.Stat(r.path); err == nil {
+		r.modTime = info.ModTime()
+	}
+	return nil
+}
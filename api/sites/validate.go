@@ -0,0 +1,98 @@
+package sites
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"osint-api/scanner"
+)
+
+const (
+	minTimeoutMS = 100
+	maxTimeoutMS = 60000
+)
+
+// ValidationError lists every problem found in a site definition
+type ValidationError struct {
+	Site     string   `json:"site"`
+	Problems []string `json:"problems"`
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid site %q: %s", e.Site, strings.Join(e.Problems, "; "))
+}
+
+// Validate checks a single site definition
+func Validate(site scanner.Site) error {
+	var problems []string
+
+	if strings.TrimSpace(site.Name) == "" {
+		problems = append(problems, "name is required")
+	}
+
+	if strings.Count(site.URL, "{}") != 1 {
+		problems = append(problems, "url must contain exactly one {} placeholder")
+	} else if u, err := url.Parse(strings.Replace(site.URL, "{}", "username", 1)); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		problems = append(problems, "url must be an absolute http(s) URL")
+	}
+
+	switch site.CheckType {
+	case scanner.CheckStatusCode, scanner.CheckRedirect:
+	case scanner.CheckBodyContains, scanner.CheckBodyAbsent, scanner.CheckJSONField:
+		if site.Match == "" {
+			problems = append(problems, fmt.Sprintf("check_type %s requires match", site.CheckType))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown check_type %q", site.CheckType))
+	}
+
+	// Zero means the scanner default
+	if site.TimeoutMS != 0 && (site.TimeoutMS < minTimeoutMS || site.TimeoutMS > maxTimeoutMS) {
+		problems = append(problems, fmt.Sprintf("timeout_ms must be between %d and %d", minTimeoutMS, maxTimeoutMS))
+	}
+
+	if site.Priority < 0 {
+		problems = append(problems, "priority must not be negative")
+	}
+
+	for _, code := range site.SuccessCodes {
+		if code < 100 || code > 599 {
+			problems = append(problems, fmt.Sprintf("invalid success code %d", code))
+		}
+	}
+
+	if site.UsernamePattern != "" {
+		if _, err := regexp.Compile(site.UsernamePattern); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid username_pattern: %v", err))
+		} else {
+			for _, username := range []string{site.UsernameClaimed, site.UsernameUnclaimed} {
+				if username != "" && !site.Accepts(username) {
+					problems = append(problems, fmt.Sprintf("self-test username %q does not match username_pattern", username))
+				}
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Site: site.Name, Problems: problems}
+	}
+	return nil
+}
+
+// ValidateAll checks every definition and that names are unique
+func ValidateAll(sites []scanner.Site) error {
+	seen := make(map[string]bool, len(sites))
+	for _, site := range sites {
+		if err := Validate(site); err != nil {
+			return err
+		}
+		key := strings.ToLower(site.Name)
+		if seen[key] {
+			return &ValidationError{Site: site.Name, Problems: []string{"duplicate site name"}}
+		}
+		seen[key] = true
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+// Package store persists JSON records on disk, one file per record, so API
+// state such as operations survives restarts. Records are plaintext JSON;
+// directories are created 0700 and files 0600 so only the API's user can
+// read them, and anything stronger is left to disk encryption.
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned when a record does not exist
+var ErrNotFound = errors.New("record not found")
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]{0,127}$`)
+
+// Collection is a directory of JSON records keyed by ID
+type Collection struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// Open returns the collection stored in dir, creating it if needed
+func Open(dir string) (*Collection, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+	// MkdirAll leaves an existing directory's mode alone
+	if err := os.Chmod(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to restrict store directory: %w", err)
+	}
+	return &Collection{dir: dir}, nil
+}
+
+// Dir returns the directory backing the collection
+func (c *Collection) Dir() string {
+	return c.dir
+}
+
+// Put stores v as the record id, replacing any previous version. The write
+// is atomic: readers see either the old or the new record.
+func (c *Collection) Put(id string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode record %s: %w", id, err)
+	}
+	return c.PutRaw(id, data)
+}
+
+// PutRaw stores already encoded JSON as the record id, atomically like Put
+func (c *Collection) PutRaw(id string, data []byte) error {
+	path, err := c.path(id)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// CreateTemp opens the file 0600
+	tmp, err := os.CreateTemp(c.dir, id+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write record %s: %w", id, err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write record %s: %w", id, err)
+	}
+	return nil
+}
+
+// Get decodes the record id into v
+func (c *Collection) Get(id string, v interface{}) error {
+	path, err := c.path(id)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Delete removes the record id. Deleting a missing record is not an error.
+func (c *Collection) Delete(id string) error {
+	path, err := c.path(id)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Each calls fn with the raw JSON of every record. Iteration stops at the
+// first error fn returns.
+func (c *Collection) Each(fn func(id string, data []byte) error) error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(c.dir, name))
+		if err != nil {
+			return err
+		}
+		if err := fn(strings.TrimSuffix(name, ".json"), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Collection) path(id string) (string, error) {
+	if !validID.MatchString(id) {
+		return "", fmt.Errorf("invalid record id %q", id)
+	}
+	return filepath.Join(c.dir, id+".json"), nil
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPermissions(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "records")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put("op_1", map[string]string{"target": "eve"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]os.FileMode{dir: 0o700, filepath.Join(dir, "op_1.json"): 0o600} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode := info.Mode().Perm(); mode != want {
+			t.Errorf("%s: mode %o, want %o", path, mode, want)
+		}
+	}
+}
+
+func TestWriterKeepsLatestVersion(t *testing.T) {
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWriter(c)
+
+	for i := 0; i < 100; i++ {
+		if err := w.Put("op_1", map[string]int{"version": i}); err != nil {
+			t.Fatal(err)
+		}
+		w.Put(fmt.Sprintf("op_tmp_%d", i), map[string]int{"version": i})
+		w.Delete(fmt.Sprintf("op_tmp_%d", i))
+	}
+	w.Flush()
+
+	var got map[string]int
+	if err := c.Get("op_1", &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["version"] != 99 {
+		t.Errorf("stored version %d, want 99", got["version"])
+	}
+	for i := 0; i < 100; i++ {
+		if err := c.Get(fmt.Sprintf("op_tmp_%d", i), &got); !errors.Is(err, ErrNotFound) {
+			t.Errorf("op_tmp_%d: %v, want deleted", i, err)
+		}
+	}
+}
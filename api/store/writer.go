@@ -0,0 +1,98 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// Writer applies puts and deletes to a Collection in the background, so
+// callers can record changes while holding their own locks without doing
+// file I/O there. Values are encoded when queued; only the latest queued
+// change to each record is written.
+type Writer struct {
+	c *Collection
+
+	mu      sync.Mutex
+	pending map[string][]byte // nil data deletes the record
+	wake    chan struct{}
+	idle    *sync.Cond // signalled when pending drains
+	busy    bool
+}
+
+// NewWriter starts a background writer for c
+func NewWriter(c *Collection) *Writer {
+	w := &Writer{
+		c:       c,
+		pending: make(map[string][]byte),
+		wake:    make(chan struct{}, 1),
+	}
+	w.idle = sync.NewCond(&w.mu)
+	go w.run()
+	return w
+}
+
+// Put queues v as the new version of record id
+func (w *Writer) Put(id string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode record %s: %w", id, err)
+	}
+	w.queue(id, data)
+	return nil
+}
+
+// Delete queues the removal of record id
+func (w *Writer) Delete(id string) {
+	w.queue(id, nil)
+}
+
+// Flush waits until every queued change is on disk
+func (w *Writer) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for len(w.pending) > 0 || w.busy {
+		w.idle.Wait()
+	}
+}
+
+func (w *Writer) queue(id string, data []byte) {
+	w.mu.Lock()
+	w.pending[id] = data
+	w.mu.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Writer) run() {
+	for range w.wake {
+		w.mu.Lock()
+		batch := w.pending
+		w.pending = make(map[string][]byte)
+		w.busy = true
+		w.mu.Unlock()
+
+		for id, data := range batch {
+			var err error
+			if data == nil {
+				err = w.c.Delete(id)
+			} else {
+				err = w.c.PutRaw(id, data)
+			}
+			if err != nil {
+				log.Printf("⚠️ Failed to store record %s: %v", id, err)
+			}
+		}
+
+		w.mu.Lock()
+		w.busy = false
+		if len(w.pending) == 0 {
+			w.idle.Broadcast()
+		}
+		w.mu.Unlock()
+	}
+}
@@ -0,0 +1,113 @@
+// Package tracing configures OpenTelemetry tracing for the API and carries
+// trace context across the ZMQ boundary to orchestra.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName identifies the API in traces unless OTEL_SERVICE_NAME is set
+const ServiceName = "osint-api"
+
+// Options selects and configures the trace exporter
+type Options struct {
+	Exporter     string // "otlp", "stdout" or "none"
+	OTLPEndpoint string // collector URL; empty uses the OTLP exporter's default
+	OTLPHeaders  string // extra export headers as key=value,key=value
+}
+
+// Setup installs the global tracer provider and W3C propagator. The exporter
+// is "otlp", "stdout" for local debugging, or "none". Spans are created even
+// with no exporter so every request still gets a trace ID to hand to
+// orchestra. The returned function flushes and stops the provider.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName())),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	options := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+
+	switch strings.ToLower(strings.TrimSpace(opts.Exporter)) {
+	case "", "none":
+	case "stdout", "console":
+		exp, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stdout exporter: %w", err)
+		}
+		options = append(options, sdktrace.WithBatcher(exp))
+	case "otlp":
+		exp, err := otlptracehttp.New(ctx, otlpOptions(opts)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		options = append(options, sdktrace.WithBatcher(exp))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", opts.Exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return provider.Shutdown, nil
+}
+
+func otlpOptions(opts Options) []otlptracehttp.Option {
+	var options []otlptracehttp.Option
+	if opts.OTLPEndpoint != "" {
+		options = append(options, otlptracehttp.WithEndpointURL(opts.OTLPEndpoint))
+	}
+	if opts.OTLPHeaders != "" {
+		headers := make(map[string]string)
+		for _, pair := range strings.Split(opts.OTLPHeaders, ",") {
+			if key, value, ok := strings.Cut(pair, "="); ok {
+				headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+		options = append(options, otlptracehttp.WithHeaders(headers))
+	}
+	return options
+}
+
+// Tracer returns the API's tracer
+func Tracer() trace.Tracer {
+	return otel.Tracer("osint-api")
+}
+
+// Inject returns the trace context of ctx (traceparent, tracestate) for a
+// protocol envelope so orchestra can continue the trace. It returns nil
+// when ctx carries no trace.
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+func serviceName() string {
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		return name
+	}
+	return ServiceName
+}
@@ -0,0 +1,104 @@
+// Package version describes the running build and checks that peer services
+// speak a compatible protocol.
+//
+// Build metadata is injected with ldflags:
+//
+//	go build -ldflags "-X osint-api/version.Version=1.2.0 \
+//	  -X osint-api/version.Commit=$(git rev-parse --short HEAD) \
+//	  -X osint-api/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package version
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"osint-api/protocol"
+)
+
+// Set at build time via -ldflags "-X"
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// Protocol is the revision of the API <-> orchestra message protocol. Peers
+// must report the same revision to be considered compatible.
+const Protocol = protocol.Version
+
+// Info describes a build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+	Protocol  int    `json:"protocol"`
+}
+
+// Get returns the running build's metadata
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Protocol:  Protocol,
+	}
+}
+
+// Peer is the version a peer service reported in the latest handshake
+type Peer struct {
+	Service    string    `json:"service"`
+	Version    string    `json:"version,omitempty"`
+	Commit     string    `json:"commit,omitempty"`
+	Protocol   int       `json:"protocol"`
+	Compatible bool      `json:"compatible"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// Check reports whether a peer's protocol revision matches ours
+func (p Peer) Check() error {
+	if p.Protocol != Protocol {
+		return fmt.Errorf("%s speaks protocol %d, API speaks %d", p.Service, p.Protocol, Protocol)
+	}
+	return nil
+}
+
+var (
+	peersMu sync.RWMutex
+	peers   = make(map[string]Peer)
+)
+
+// RecordPeer stores the outcome of a handshake with a peer service, marking
+// it compatible or not, and logs when a peer becomes incompatible
+func RecordPeer(peer Peer) Peer {
+	err := peer.Check()
+	peer.Compatible = err == nil
+
+	peersMu.Lock()
+	previous, seen := peers[peer.Service]
+	peers[peer.Service] = peer
+	peersMu.Unlock()
+
+	if err != nil && (!seen || previous.Compatible) {
+		log.Printf("⚠️ Incompatible deployment: %v (peer version %s)", err, peer.Version)
+	}
+	return peer
+}
+
+// Peers returns the latest handshake with every peer, by service name
+func Peers() []Peer {
+	peersMu.RLock()
+	defer peersMu.RUnlock()
+
+	result := make([]Peer, 0, len(peers))
+	for _, peer := range peers {
+		result = append(result, peer)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Service < result[j].Service })
+	return result
+}
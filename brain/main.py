@@ -43,10 +43,21 @@ class SecureAIBrain:
 
     def process_request(self, message: dict) -> dict:
         """Process request with OPSEC measures"""
+        # Health checks from the API use the protocol envelope (api/protocol)
+        if message.get('type') == 'ping':
+            reply = {'protocol': message.get('protocol'), 'type': 'reply',
+                     'payload': {'status': 'pong', 'service': 'brain'}}
+            if message.get('id'):
+                reply['id'] = message['id']
+            return reply
+        if message.get('action') == 'ping':
+            return {'status': 'pong', 'service': 'brain'}
+        
         operation_id = message.get('operation_id', 'unknown')
+        request_id = message.get('request_id', 'unknown')
         target_hash = self.hash_target(message.get('target', ''))
         
-        logging.info(f"Processing request for target hash: {target_hash}")
+        logging.info(f"Processing request {request_id} for target hash: {target_hash}")
         
         action = message.get('action')
         if action == 'analyze_pattern':
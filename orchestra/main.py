@@ -4,14 +4,26 @@ ORCHESTRA - Coordination Layer
 Coordinates Brain, Muscle, and external components
 """
 
+import os
 import zmq
 import json
 import asyncio
+from collections import OrderedDict
 from typing import Dict, Any
+from opentelemetry.trace import SpanKind, Status, StatusCode
 from orchestrator import InvestigationOrchestrator
+import protocol
+import tracing
+from protocol import PROTOCOL_VERSION
+
+ORCHESTRA_VERSION = os.getenv('ORCHESTRA_VERSION', '1.0.0')
+
+# Reports kept for retried operations whose first reply never reached the API
+REPORT_CACHE_SIZE = 256
 
 class OrchestraCoordinator:
     def __init__(self):
+        tracing.configure()
         self.context = zmq.Context()
         
         # Connect to Brain
@@ -24,45 +36,128 @@ class OrchestraCoordinator:
         
         # Setup server for external connections
         self.server_socket = self.context.socket(zmq.REP)
+        self.authenticator = self.configure_curve(self.server_socket)
         self.server_socket.bind("tcp://*:5558")
         
         self.orchestrator = InvestigationOrchestrator()
+        # operation_id -> report payload. Only payloads are kept: each reply
+        # is a fresh envelope carrying the current request's correlation ID.
+        self.reports = OrderedDict()
         
         print("🎻 ORCHESTRA layer initialized and listening on port 5558")
     
+    def configure_curve(self, socket):
+        """Enable CURVE on the server socket when a secret certificate is configured.
+
+        ORCHESTRA_CURVE_SECRET_KEY_FILE is orchestra's .key_secret certificate;
+        ORCHESTRA_CURVE_CLIENTS_DIR holds the public certificates of allowed
+        clients (any client may connect when unset).
+        """
+        secret_file = os.getenv('ORCHESTRA_CURVE_SECRET_KEY_FILE')
+        if not secret_file:
+            if os.getenv('ENVIRONMENT') == 'production':
+                raise RuntimeError('ORCHESTRA_CURVE_SECRET_KEY_FILE is required in production')
+            print("⚠️ API link is not encrypted; set ORCHESTRA_CURVE_SECRET_KEY_FILE to enable CURVE")
+            return None
+
+        import zmq.auth
+        from zmq.auth.thread import ThreadAuthenticator
+
+        authenticator = ThreadAuthenticator(self.context)
+        authenticator.start()
+        clients_dir = os.getenv('ORCHESTRA_CURVE_CLIENTS_DIR')
+        authenticator.configure_curve(domain='*', location=clients_dir or zmq.auth.CURVE_ALLOW_ANY)
+
+        public_key, secret_key = zmq.auth.load_certificate(secret_file)
+        socket.curve_publickey = public_key
+        socket.curve_secretkey = secret_key
+        socket.curve_server = True
+        print(f"🔐 CURVE enabled; clients from {clients_dir or 'any key'}")
+        return authenticator
+    
     async def coordinate_investigation(self, target: str) -> Dict[str, Any]:
         """Coordinate a complete investigation"""
         return await self.orchestrator.orchestrate(target)
     
+    def handle(self, request: Dict[str, Any]) -> Dict[str, Any]:
+        """Answer one protocol envelope"""
+        msg_type = request['type']
+        payload = request.get('payload') or {}
+        
+        if request['protocol'] != PROTOCOL_VERSION and msg_type != protocol.TYPE_VERSION:
+            return protocol.error(request, f"orchestra speaks protocol {PROTOCOL_VERSION}, "
+                                           f"got {request['protocol']}")
+        
+        if protocol.expired(request):
+            return protocol.error(request, 'deadline exceeded before processing')
+        
+        if msg_type == protocol.TYPE_PING:
+            return protocol.reply(request, {'status': 'pong', 'service': 'orchestra'})
+        
+        if msg_type == protocol.TYPE_VERSION:
+            if payload.get('protocol') != PROTOCOL_VERSION:
+                print(f"⚠️ Incompatible {payload.get('service')} {payload.get('version')}: "
+                      f"protocol {payload.get('protocol')}, orchestra speaks {PROTOCOL_VERSION}")
+            return protocol.reply(request, {
+                'service': 'orchestra',
+                'version': ORCHESTRA_VERSION,
+                'protocol': PROTOCOL_VERSION,
+            })
+        
+        if msg_type == protocol.TYPE_INVESTIGATE:
+            operation_id = payload.get('operation_id')
+            if operation_id in self.reports:
+                print(f"♻️ Replaying report for operation {operation_id} "
+                      f"(attempt {payload.get('attempt', 1)})")
+                return protocol.reply(request, self.reports[operation_id])
+            
+            # Run investigation asynchronously
+            loop = asyncio.new_event_loop()
+            asyncio.set_event_loop(loop)
+            result = loop.run_until_complete(self.coordinate_investigation(payload.get('target')))
+            loop.close()
+            self.remember_report(operation_id, result)
+            return protocol.reply(request, result)
+        
+        return protocol.error(request, f'Unknown message type: {msg_type}')
+    
+    def remember_report(self, operation_id, report):
+        """Keep a successful report so a retry of the operation is not rerun"""
+        if not operation_id or not isinstance(report, dict) or 'error' in report:
+            return
+        self.reports[operation_id] = report
+        while len(self.reports) > REPORT_CACHE_SIZE:
+            self.reports.popitem(last=False)
+    
     def run(self):
         """Main coordination loop"""
         while True:
+            request = None
             try:
                 # Receive request
-                message = self.server_socket.recv_json()
-                print(f"🎻 Received request: {message.get('action')}")
+                request = protocol.decode(self.server_socket.recv_json())
                 
-                # Process request based on action
-                if message.get('action') == 'investigate':
-                    target = message.get('target')
-                    
-                    # Run investigation asynchronously
-                    loop = asyncio.new_event_loop()
-                    asyncio.set_event_loop(loop)
-                    result = loop.run_until_complete(self.coordinate_investigation(target))
-                    loop.close()
+                # Continue the API's trace; the workers run inside this span
+                with tracing.tracer().start_as_current_span(
+                        f"orchestra.{request['type']}",
+                        context=tracing.extract(request.get('trace')),
+                        kind=SpanKind.SERVER) as span:
+                    span.set_attribute('messaging.system', 'zeromq')
+                    span.set_attribute('messaging.message.id', request.get('id', ''))
+                    traceparent = tracing.inject().get('traceparent', '-')
+                    print(f"🎻 Received request: {request['type']} "
+                          f"(id={request.get('id', 'unknown')}, request_id={request.get('request_id', '-')}, "
+                          f"traceparent={traceparent})")
                     
-                    response = result
-                
-                else:
-                    response = {'error': 'Unknown action'}
-                
-                # Send response
-                self.server_socket.send_json(response)
+                    response = self.handle(request)
+                    if response['type'] == protocol.TYPE_ERROR:
+                        span.set_status(Status(StatusCode.ERROR, response['payload']['message']))
                 
             except Exception as e:
-                error_response = {'error': f'Orchestration error: {str(e)}'}
-                self.server_socket.send_json(error_response)
+                response = protocol.error(request, f'Orchestration error: {str(e)}')
+            
+            # Send response
+            self.server_socket.send_json(response)
 
 if __name__ == "__main__":
     coordinator = OrchestraCoordinator()
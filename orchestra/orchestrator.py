@@ -1,6 +1,7 @@
 import asyncio
 from scrapy_manager import ScrapyManager
 from spiderfoot_manager import SpiderfootManager
+import tracing
 
 class Orchestrator:
     def __init__(self):
@@ -11,12 +12,14 @@ class Orchestrator:
     async def investigate_target(self, target: str, operation_id: str) -> Dict[str, Any]:
         """Complete investigation using all integrated tools"""
         
-        # Run all tools in parallel
-        scrapy_task = asyncio.to_thread(
+        # Run all tools in parallel, each in a child span of the request's
+        scrapy_task = self.traced('orchestra.scrapy', asyncio.to_thread(
             self.scrapy_manager.crawl_target, target, operation_id
-        )
+        ))
         
-        spiderfoot_task = self.spiderfoot_manager.scan_target(target, operation_id)
+        spiderfoot_task = self.traced(
+            'orchestra.spiderfoot', self.spiderfoot_manager.scan_target(target, operation_id)
+        )
         
         # Wait for both to complete
         scrapy_results, spiderfoot_results = await asyncio.gather(
@@ -30,6 +33,11 @@ class Orchestrator:
         
         return final_report
     
+    async def traced(self, name, work):
+        """Await work in a span named name"""
+        with tracing.tracer().start_as_current_span(name):
+            return await work
+    
     def correlate_intelligence(self, target, operation_id, scrapy_data, spiderfoot_data):
         """Correlate data from all sources"""
         return {
@@ -37,10 +45,11 @@ class Orchestrator:
             'target': target,
             'scrapy': {
                 'pages_crawled': len(scrapy_data),
-                'findings': scrapy_data[:5]  # First 5 results
+                'findings': scrapy_data  # All of them, so runs can be diffed
             },
             'spiderfoot': {
                 'scan_id': spiderfoot_data.get('scan_id'),
+                # SpiderFoot only reports a count; the API cannot diff it
                 'findings_count': spiderfoot_data.get('findings_count', 0),
                 'status': spiderfoot_data.get('status')
             },
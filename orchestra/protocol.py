@@ -0,0 +1,69 @@
+"""
+API <-> orchestra wire protocol (mirrors api/protocol/protocol.go)
+
+Every message is a JSON envelope:
+    {"protocol": 2, "type": "investigate", "id": "<correlation id>",
+     "request_id": "<X-Request-ID of the API call>",
+     "deadline": "2026-01-02T15:04:05.123Z", "trace": {"traceparent": "..."},
+     "payload": {...}}
+Requests are answered with a "reply" or an "error" envelope echoing the id;
+the id is unique per message, request_id only ties it to the API's logs.
+"""
+
+from datetime import datetime, timezone
+from typing import Any, Dict, Optional
+
+PROTOCOL_VERSION = 2
+
+TYPE_PING = 'ping'
+TYPE_VERSION = 'version'
+TYPE_INVESTIGATE = 'investigate'
+TYPE_REPLY = 'reply'
+TYPE_ERROR = 'error'
+
+
+class ProtocolError(Exception):
+    """Raised for messages that are not valid envelopes"""
+
+
+def decode(message: Any) -> Dict[str, Any]:
+    """Validate a received envelope"""
+    if not isinstance(message, dict) or not message.get('protocol') or not message.get('type'):
+        raise ProtocolError('malformed envelope: protocol and type are required')
+    return message
+
+
+def reply(request: Dict[str, Any], payload: Any) -> Dict[str, Any]:
+    """Build the reply to request"""
+    return _envelope(TYPE_REPLY, request.get('id'), payload)
+
+
+def error(request: Optional[Dict[str, Any]], message: str) -> Dict[str, Any]:
+    """Build an error answering request"""
+    return _envelope(TYPE_ERROR, (request or {}).get('id'), {'message': message})
+
+
+def expired(request: Dict[str, Any]) -> bool:
+    """Whether the sender has already stopped waiting for a reply"""
+    deadline = request.get('deadline')
+    if not deadline:
+        return False
+    return datetime.now(timezone.utc) > _parse_time(deadline)
+
+
+def _envelope(msg_type: str, msg_id: Optional[str], payload: Any) -> Dict[str, Any]:
+    envelope = {'protocol': PROTOCOL_VERSION, 'type': msg_type, 'payload': payload}
+    if msg_id:
+        envelope['id'] = msg_id
+    return envelope
+
+
+def _parse_time(value: str) -> datetime:
+    # Go writes RFC 3339 with up to nanosecond precision; fromisoformat
+    # accepts at most microseconds and, before 3.11, no "Z"
+    value = value.replace('Z', '+00:00')
+    if '.' in value:
+        head, rest = value.split('.', 1)
+        digits = len(rest) - len(rest.lstrip('0123456789'))
+        value = f"{head}.{rest[:digits][:6].ljust(6, '0')}{rest[digits:]}"
+    return datetime.fromisoformat(value)
@@ -0,0 +1,56 @@
+"""
+OpenTelemetry tracing for orchestra (mirrors api/tracing)
+
+The API sends W3C trace context in each envelope's "trace" field. Orchestra
+continues that trace with a span per message, and the investigation workers
+run inside it. OTEL_TRACES_EXPORTER selects otlp (OTEL_EXPORTER_OTLP_ENDPOINT),
+stdout or none; with none the incoming trace context is still propagated.
+"""
+
+import os
+from typing import Any, Dict, Optional
+
+from opentelemetry import trace
+from opentelemetry.sdk.resources import Resource
+from opentelemetry.sdk.trace import TracerProvider
+from opentelemetry.sdk.trace.export import BatchSpanProcessor, ConsoleSpanExporter
+from opentelemetry.trace.propagation.tracecontext import TraceContextTextMapPropagator
+
+SERVICE_NAME = 'osint-orchestra'
+
+_propagator = TraceContextTextMapPropagator()
+
+
+def configure() -> None:
+    """Install the span exporter chosen by OTEL_TRACES_EXPORTER"""
+    exporter = os.getenv('OTEL_TRACES_EXPORTER', 'none').lower()
+    if exporter in ('', 'none'):
+        return
+
+    provider = TracerProvider(resource=Resource.create({'service.name': SERVICE_NAME}))
+    if exporter == 'otlp':
+        from opentelemetry.exporter.otlp.proto.http.trace_exporter import OTLPSpanExporter
+        provider.add_span_processor(BatchSpanProcessor(OTLPSpanExporter()))
+    elif exporter in ('stdout', 'console'):
+        provider.add_span_processor(BatchSpanProcessor(ConsoleSpanExporter()))
+    else:
+        print(f"⚠️ Unknown OTEL_TRACES_EXPORTER {exporter!r}; spans are not exported")
+        return
+    trace.set_tracer_provider(provider)
+    print(f"🔭 Tracing to {exporter}")
+
+
+def tracer() -> trace.Tracer:
+    return trace.get_tracer('orchestra')
+
+
+def extract(carrier: Optional[Dict[str, Any]]):
+    """Context continuing the trace described by an envelope's trace field"""
+    return _propagator.extract(carrier=carrier or {})
+
+
+def inject() -> Dict[str, str]:
+    """Trace field for a message sent from within the current span"""
+    carrier: Dict[str, str] = {}
+    _propagator.inject(carrier)
+    return carrier